@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nareix/joy4/av"
+	"github.com/nareix/joy4/av/avutil"
+	"github.com/nareix/joy4/format/flv/flvio"
+)
+
+const (
+	defaultAdBreakDuration = 30 * time.Second // Длительность рекламной паузы по умолчанию
+	adBreakAtEnd           = "end"            // Значение "at" для паузы после окончания файла
+)
+
+// AdBreakMarker описывает точку рекламной паузы для конкретного файла
+type AdBreakMarker struct {
+	File     string `json:"file"`     // Имя файла в каталоге видео
+	At       string `json:"at"`       // Таймкод внутри файла (ЧЧ:ММ:СС, ММ:СС или секунды), пусто или "end" = после файла
+	Duration int    `json:"duration"` // Длительность паузы в секундах, 0 = значение по умолчанию
+}
+
+// Offset возвращает позицию паузы внутри файла; ok=false для паузы на границе файла
+func (m AdBreakMarker) Offset() (offset time.Duration, ok bool, err error) {
+	at := strings.TrimSpace(m.At)
+	if at == "" || strings.EqualFold(at, adBreakAtEnd) {
+		return 0, false, nil
+	}
+	offset, err = parseTimecode(at)
+	if err != nil {
+		return 0, false, err
+	}
+	return offset, true, nil
+}
+
+// parseTimecode разбирает таймкод вида ЧЧ:ММ:СС[.ммм], ММ:СС или количество секунд
+func parseTimecode(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("%s: %s", T("err.timecode"), value)
+	}
+
+	var total float64
+	for _, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, fmt.Errorf("%s: %s", T("err.timecode"), value)
+		}
+		total = total*60 + n
+	}
+	return time.Duration(total * float64(time.Second)), nil
+}
+
+// AdBreak - запланированная рекламная пауза внутри файла
+type AdBreak struct {
+	At       time.Duration // Позиция внутри файла
+	Duration time.Duration // Длительность паузы
+}
+
+// AdScheduler отвечает за рекламные паузы: точки паузы и выбор рекламных роликов
+type AdScheduler struct {
+	Enabled     bool
+	Directory   string
+	InsertAds   bool
+	EveryNFiles int
+	Duration    time.Duration
+	Markers     []AdBreakMarker
+
+	OnClip func(path string, started time.Time, played time.Duration, err error) // Вызывается после каждого ролика, например для журнала эфира
+
+	nextAd          int // Индекс следующего ролика в каталоге рекламы
+	filesSinceBreak int // Сколько файлов проиграно с последней паузы на границе
+	log             *Logger
+}
+
+// NewAdScheduler создает планировщик рекламных пауз из конфигурации
+func NewAdScheduler(config *Config, log *Logger) *AdScheduler {
+	ads := &AdScheduler{
+		Enabled:     config.AdBreaks.Enabled,
+		Directory:   config.AdBreaks.Directory,
+		InsertAds:   config.AdBreaks.InsertAds,
+		EveryNFiles: config.AdBreaks.EveryNFiles,
+		Duration:    time.Duration(config.AdBreaks.Duration) * time.Second,
+		Markers:     config.AdBreaks.Markers,
+		log:         log,
+	}
+	if ads.Duration <= 0 {
+		ads.Duration = defaultAdBreakDuration
+	}
+	return ads
+}
+
+// markerDuration возвращает длительность паузы с учетом значения по умолчанию
+func (a *AdScheduler) markerDuration(m AdBreakMarker) time.Duration {
+	if m.Duration > 0 {
+		return time.Duration(m.Duration) * time.Second
+	}
+	return a.Duration
+}
+
+// BreaksInFile возвращает отсортированные паузы внутри указанного файла
+func (a *AdScheduler) BreaksInFile(fileName string) []AdBreak {
+	if a == nil || !a.Enabled {
+		return nil
+	}
+
+	var breaks []AdBreak
+	for _, m := range a.Markers {
+		if m.File != fileName {
+			continue
+		}
+		offset, inside, err := m.Offset()
+		if err != nil {
+			a.log.Warn("ads.marker_invalid", "file", fileName, "error", err)
+			continue
+		}
+		if inside {
+			breaks = append(breaks, AdBreak{At: offset, Duration: a.markerDuration(m)})
+		}
+	}
+
+	sort.Slice(breaks, func(i, j int) bool {
+		return breaks[i].At < breaks[j].At
+	})
+	return breaks
+}
+
+// BreakAfterFile сообщает, нужна ли пауза на границе после файла, и ее длительность
+func (a *AdScheduler) BreakAfterFile(fileName string) (time.Duration, bool) {
+	if a == nil || !a.Enabled {
+		return 0, false
+	}
+
+	for _, m := range a.Markers {
+		if m.File != fileName {
+			continue
+		}
+		if _, inside, err := m.Offset(); err == nil && !inside {
+			a.filesSinceBreak = 0
+			return a.markerDuration(m), true
+		}
+	}
+
+	if a.EveryNFiles > 0 {
+		a.filesSinceBreak++
+		if a.filesSinceBreak >= a.EveryNFiles {
+			a.filesSinceBreak = 0
+			return a.Duration, true
+		}
+	}
+	return 0, false
+}
+
+// nextAdFile возвращает путь к следующему рекламному ролику по кругу
+func (a *AdScheduler) nextAdFile() (string, bool) {
+	if a.Directory == "" {
+		return "", false
+	}
+
+	entries, err := os.ReadDir(a.Directory)
+	if err != nil {
+		a.log.Warn("ads.dir_failed", "dir", a.Directory, "error", err)
+		return "", false
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".mp4") {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", false
+	}
+	sort.Strings(names)
+
+	if a.nextAd >= len(names) {
+		a.nextAd = 0
+	}
+	name := names[a.nextAd]
+	a.nextAd++
+	return filepath.Join(a.Directory, name), true
+}
+
+// RunBreak выполняет рекламную паузу: отправляет cue point начала паузы и, если включено,
+// воспроизводит локальные ролики, после чего отправляет cue point возврата к контенту.
+// Возвращает время, потраченное на вставку роликов.
+func (a *AdScheduler) RunBreak(pub *Publisher, duration time.Duration, sessionBitrate *BitrateCalculator) (time.Duration, error) {
+	eventID := pub.NextCueEventID()
+	a.log.Info("ads.break_start", "event_id", eventID, "duration", duration)
+
+	// Незакрытая предыдущая пауза закрывается перед началом новой
+	if params, ok := pub.CancelScheduledCuePoint(); ok {
+		if err := pub.WriteCuePoint("splice_insert", params); err != nil {
+			return 0, err
+		}
+	}
+
+	outParams := flvio.AMFMap{
+		"type":                  "out",
+		"spliceEventId":         eventID,
+		"duration":              duration.Seconds(),
+		"outOfNetworkIndicator": true,
+	}
+	inParams := flvio.AMFMap{
+		"type":                  "in",
+		"spliceEventId":         eventID,
+		"duration":              duration.Seconds(),
+		"outOfNetworkIndicator": false,
+	}
+
+	if err := pub.WriteCuePoint("splice_insert", outParams); err != nil {
+		return 0, err
+	}
+
+	// Без локальных роликов контент продолжается, а платформа подменяет его сама
+	if !a.InsertAds {
+		pub.ScheduleCuePoint(duration, inParams)
+		return 0, nil
+	}
+
+	start := time.Now()
+	played := time.Duration(0)
+	for played < duration {
+		adPath, ok := a.nextAdFile()
+		if !ok {
+			a.log.Warn("ads.no_clips", "event_id", eventID, "dir", a.Directory)
+			pub.ScheduleCuePoint(duration-played, inParams)
+			return time.Since(start), nil
+		}
+
+		a.log.Info("ads.clip", "event_id", eventID, "file", filepath.Base(adPath))
+		clipStart := time.Now()
+		clipTime, err := streamClip(adPath, pub, sessionBitrate, duration-played)
+		if a.OnClip != nil {
+			a.OnClip(adPath, clipStart, clipTime, err)
+		}
+		played += clipTime
+		if err != nil {
+			return time.Since(start), err
+		}
+		if clipTime == 0 {
+			// Пустой ролик, чтобы не зациклиться
+			break
+		}
+	}
+
+	if err := pub.WriteCuePoint("splice_insert", inParams); err != nil {
+		return time.Since(start), err
+	}
+	a.log.Info("ads.break_end", "event_id", eventID)
+	return time.Since(start), nil
+}
+
+// streamClip отправляет короткий ролик целиком (или до maxDuration) через публикатор
+// в реальном времени. Возвращает длительность отправленного фрагмента.
+func streamClip(clipPath string, pub *Publisher, sessionBitrate *BitrateCalculator, maxDuration time.Duration) (time.Duration, error) {
+	file, err := avutil.Open(clipPath)
+	if err != nil {
+		return 0, fmt.Errorf("%s %s: %w", T("err.open_clip"), clipPath, demuxError(err))
+	}
+	defer file.Close()
+
+	streams, err := file.Streams()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", T("err.clip_streams"), demuxError(err))
+	}
+
+	if err := pub.WriteHeader(streams); err != nil {
+		return 0, err
+	}
+
+	return streamClipPackets(file, pub, sessionBitrate, maxDuration)
+}
+
+// streamClipPackets отправляет пакеты открытого ролика с синхронизацией по реальному времени
+func streamClipPackets(file av.Demuxer, pub *Publisher, sessionBitrate *BitrateCalculator, maxDuration time.Duration) (time.Duration, error) {
+	var firstTS time.Duration = -1
+	var clipPos time.Duration
+	baseRealTime := time.Now()
+
+	for {
+		pkt, err := file.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				return clipPos, nil
+			}
+			return clipPos, fmt.Errorf("%s: %w", T("err.read_clip_packet"), demuxError(err))
+		}
+
+		if firstTS < 0 {
+			firstTS = pkt.Time
+		}
+		pos := pkt.Time - firstTS
+		if maxDuration > 0 && pos >= maxDuration {
+			return clipPos, nil
+		}
+		if pos > clipPos {
+			clipPos = pos
+		}
+
+		if waitTime := time.Until(baseRealTime.Add(pos)); waitTime > 0 && waitTime < 500*time.Millisecond {
+			time.Sleep(waitTime)
+		} else if waitTime > 500*time.Millisecond {
+			baseRealTime = time.Now().Add(-pos)
+		}
+
+		if err := pub.WritePacket(pkt); err != nil {
+			return clipPos, fmt.Errorf("%s: %w", T("err.write_clip_packet"), err)
+		}
+		sessionBitrate.AddBytes(int64(len(pkt.Data)))
+	}
+}
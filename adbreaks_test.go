@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimecode(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "0", want: 0},
+		{value: "90", want: 90 * time.Second},
+		{value: "12.5", want: 12500 * time.Millisecond},
+		{value: "01:30", want: 90 * time.Second},
+		{value: "1:02:03", want: time.Hour + 2*time.Minute + 3*time.Second},
+		{value: "00:00:01.250", want: 1250 * time.Millisecond},
+		{value: "0:75", want: 75 * time.Second}, // Секунды сверх 59 допускаются
+		{value: "", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "1:2:3:4", wantErr: true},
+		{value: "-5", wantErr: true},
+		{value: "1:-5", wantErr: true},
+		{value: "1::2", wantErr: true},
+		{value: "NaN", wantErr: true},
+		{value: "Inf", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTimecode(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTimecode(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseTimecode(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestAdBreakMarkerOffset(t *testing.T) {
+	tests := []struct {
+		at      string
+		want    time.Duration
+		wantOK  bool
+		wantErr bool
+	}{
+		{at: "", wantOK: false},
+		{at: "end", wantOK: false},
+		{at: " END ", wantOK: false},
+		{at: "10:00", want: 10 * time.Minute, wantOK: true},
+		{at: " 45 ", want: 45 * time.Second, wantOK: true},
+		{at: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, ok, err := AdBreakMarker{At: tt.at}.Offset()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Offset(%q) error = %v, wantErr %v", tt.at, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Offset(%q) = %v, %v, want %v, %v", tt.at, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAsRunDirectory = "asrun"      // Каталог журнала эфира по умолчанию
+	asRunFilePrefix       = "asrun-"     // Файл журнала за сутки: asrun-ГГГГ-ММ-ДД.jsonl
+	asRunFileExt          = ".jsonl"     // Формат JSON Lines: одна запись в строке
+	asRunDateLayout       = "2006-01-02" // Формат даты в имени файла и флагах экспорта
+)
+
+// Виды элементов журнала эфира, кроме роликов из clip.played
+const (
+	AsRunFile     = "file"     // Файл каталога
+	AsRunOverride = "override" // Экстренная вставка
+)
+
+// Результаты элементов журнала эфира
+const (
+	AsRunCompleted   = "completed"   // Отправлен до конца
+	AsRunEarlyEnd    = "early_end"   // Завершен раньше конца для подготовки следующего файла
+	AsRunInterrupted = "interrupted" // Прерван командой оператора, окном вещания или перезапуском
+	AsRunFailed      = "failed"      // Не удалось отправить
+)
+
+// AsRunInterruption - то, что случилось во время эфира элемента: повтор, переподключение,
+// раннее завершение, экстренная вставка или ролик внутри файла. Event - тип события.
+type AsRunInterruption struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Position float64   `json:"position"`       // Позиция в файле, секунды
+	File     string    `json:"file,omitempty"` // Вставка или ролик, прервавшие файл
+	Error    string    `json:"error,omitempty"`
+}
+
+// AsRunRecord - запись журнала эфира: что, когда и куда фактически отправлено.
+// Позиции In и Out - в секундах от начала файла.
+type AsRunRecord struct {
+	Channel       string              `json:"channel"`
+	Kind          string              `json:"kind"` // file, override, interstitial, ad или slate
+	File          string              `json:"file"`
+	Title         string              `json:"title,omitempty"` // Название из метаданных файла
+	Tags          []string            `json:"tags,omitempty"`  // Метки из метаданных файла
+	SHA256        string              `json:"sha256,omitempty"`
+	Start         time.Time           `json:"start"`
+	End           time.Time           `json:"end"`
+	In            float64             `json:"in"`
+	Out           float64             `json:"out"`
+	Destinations  []string            `json:"destinations"` // Адреса серверов без ключа потока
+	Result        string              `json:"result"`
+	Error         string              `json:"error,omitempty"`
+	Interruptions []AsRunInterruption `json:"interruptions,omitempty"`
+}
+
+// asRunHash - кэш хэша файла; пересчитывается при изменении размера или времени изменения
+type asRunHash struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// AsRunLog ведет журнал эфира по событиям каналов. Записи только дописываются,
+// по одному файлу JSON Lines на сутки начала элемента. Файл канала записывается
+// после своего окончания, ролики и вставки - сразу после эфира.
+type AsRunLog struct {
+	Directory string
+
+	mu       sync.Mutex
+	channels []*Channel
+
+	// Используются только горутиной журнала
+	playing   map[string]*AsRunRecord // Играющий файл по каналам
+	overrides map[string]*AsRunRecord // Играющая экстренная вставка по каналам
+	hashes    map[string]asRunHash
+}
+
+// NewAsRunLog создает журнал эфира из конфигурации процесса
+func NewAsRunLog(config *Config) *AsRunLog {
+	return &AsRunLog{
+		Directory: asRunDirectory(config),
+		playing:   make(map[string]*AsRunRecord),
+		overrides: make(map[string]*AsRunRecord),
+		hashes:    make(map[string]asRunHash),
+	}
+}
+
+// asRunDirectory возвращает каталог журнала эфира
+func asRunDirectory(config *Config) string {
+	if config.AsRun.Directory != "" {
+		return config.AsRun.Directory
+	}
+	return defaultAsRunDirectory
+}
+
+// Add добавляет канал в журнал
+func (l *AsRunLog) Add(ch *Channel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.channels = append(l.channels, ch)
+}
+
+// Start подписывается на события каналов. Подписка оформляется сразу,
+// чтобы первый файл каждого канала попал в журнал.
+func (l *AsRunLog) Start(bus *EventBus) {
+	logInfo("asrun.started", "dir", l.Directory)
+	go l.run(bus, bus.Subscribe(0))
+}
+
+// run записывает события по порядку. При переполнении подписки пропущенное
+// берется из буфера повторной отправки шины.
+func (l *AsRunLog) run(bus *EventBus, sub *EventSubscription) {
+	var lastID uint64
+	for {
+		for event := range sub.C {
+			if lastID > 0 && event.ID > lastID+1 {
+				logError("asrun.events_lost", "count", event.ID-lastID-1)
+			}
+			lastID = event.ID
+			l.handle(event)
+		}
+		logWarn("asrun.lagging")
+		sub = bus.Subscribe(lastID)
+	}
+}
+
+// channel ищет канал по имени
+func (l *AsRunLog) channel(name string) *Channel {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ch := range l.channels {
+		if ch.Name == name {
+			return ch
+		}
+	}
+	return nil
+}
+
+// handle учитывает событие канала
+func (l *AsRunLog) handle(event Event) {
+	ch := l.channel(event.Channel)
+	if ch == nil {
+		return
+	}
+
+	playing := l.playing[ch.Name]
+	switch data := event.Data.(type) {
+	case FileStartedEvent:
+		// Предыдущий файл не завершился событием, например канал перезапущен после сбоя
+		if playing != nil {
+			l.finish(playing, event.Time, AsRunInterrupted, "")
+		}
+		l.playing[ch.Name] = &AsRunRecord{
+			Channel:      ch.Name,
+			Kind:         AsRunFile,
+			File:         data.File,
+			Title:        data.Title,
+			Tags:         data.Tags,
+			SHA256:       l.hash(filepath.Join(ch.Config().Video.Directory, data.File)),
+			Start:        event.Time,
+			In:           data.Position,
+			Out:          data.Position,
+			Destinations: []string{ch.Config().RTMP.URL},
+		}
+
+	case StateSavedEvent:
+		if playing != nil && playing.File == data.File {
+			playing.Out = data.Position
+		}
+
+	case FileFinishedEvent:
+		if playing == nil || playing.File != data.File {
+			return
+		}
+		playing.Out = data.Position
+		switch {
+		case data.Error != "":
+			l.finish(playing, event.Time, AsRunFailed, data.Error)
+		case data.Status.Interrupted:
+			l.finish(playing, event.Time, AsRunInterrupted, "")
+		case data.Status.PrepareNext:
+			l.finish(playing, event.Time, AsRunEarlyEnd, "")
+		default:
+			l.finish(playing, event.Time, AsRunCompleted, "")
+		}
+
+	case EarlyEndEvent:
+		l.interrupt(playing, AsRunInterruption{Time: event.Time, Event: event.Type, Position: data.Position})
+
+	case RetryEvent:
+		l.interrupt(playing, AsRunInterruption{Time: event.Time, Event: event.Type})
+
+	case ReconnectEvent:
+		l.interrupt(playing, AsRunInterruption{Time: event.Time, Event: event.Type, Position: data.Position, Error: data.Error})
+
+	case OverrideEvent:
+		if event.Type == EventOverrideStarted {
+			l.interrupt(playing, AsRunInterruption{Time: event.Time, Event: event.Type, Position: data.Position, File: data.File})
+			l.overrides[ch.Name] = &AsRunRecord{
+				Channel:      ch.Name,
+				Kind:         AsRunOverride,
+				File:         data.File,
+				SHA256:       l.hash(data.Path),
+				Start:        event.Time,
+				Destinations: []string{ch.Config().RTMP.URL},
+			}
+			return
+		}
+		record := l.overrides[ch.Name]
+		if record == nil || record.File != data.File {
+			return
+		}
+		delete(l.overrides, ch.Name)
+		record.Out = data.Duration
+		if data.Error != "" {
+			l.finish(record, event.Time, AsRunFailed, data.Error)
+		} else {
+			l.finish(record, event.Time, AsRunCompleted, "")
+		}
+
+	case ClipPlayedEvent:
+		// Ролик во время файла: рекламная пауза внутри файла или заставка между попытками
+		l.interrupt(playing, AsRunInterruption{Time: data.Started, Event: event.Type, Position: playing.position(), File: data.File})
+		record := &AsRunRecord{
+			Channel:      ch.Name,
+			Kind:         data.Kind,
+			File:         data.File,
+			SHA256:       l.hash(data.Path),
+			Start:        data.Started,
+			Out:          data.Duration,
+			Destinations: []string{ch.Config().RTMP.URL},
+		}
+		if data.Error != "" {
+			l.finish(record, event.Time, AsRunFailed, data.Error)
+		} else {
+			l.finish(record, event.Time, AsRunCompleted, "")
+		}
+	}
+}
+
+// position возвращает последнюю известную позицию файла
+func (r *AsRunRecord) position() float64 {
+	if r == nil {
+		return 0
+	}
+	return r.Out
+}
+
+// interrupt отмечает событие во время эфира файла
+func (l *AsRunLog) interrupt(record *AsRunRecord, interruption AsRunInterruption) {
+	if record == nil {
+		return
+	}
+	record.Interruptions = append(record.Interruptions, interruption)
+}
+
+// finish завершает запись и дописывает ее в файл суток начала элемента
+func (l *AsRunLog) finish(record *AsRunRecord, end time.Time, result, errText string) {
+	record.End, record.Result, record.Error = end, result, errText
+	if l.playing[record.Channel] == record {
+		delete(l.playing, record.Channel)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		logError("asrun.write_failed", "path", l.Directory, "error", err)
+		return
+	}
+	path := filepath.Join(l.Directory, asRunFilePrefix+record.Start.Format(asRunDateLayout)+asRunFileExt)
+	if err := os.MkdirAll(l.Directory, 0755); err != nil {
+		logError("asrun.write_failed", "path", path, "error", err)
+		return
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logError("asrun.write_failed", "path", path, "error", err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		logError("asrun.write_failed", "path", path, "error", err)
+	}
+}
+
+// hash возвращает SHA-256 содержимого файла. Результат кэшируется, пока не изменились
+// размер и время изменения файла. Пустая строка - файл не прочитан.
+func (l *AsRunLog) hash(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		logWarn("asrun.hash_failed", "file", path, "error", err)
+		return ""
+	}
+	if cached, ok := l.hashes[path]; ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.sum
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		logWarn("asrun.hash_failed", "file", path, "error", err)
+		return ""
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		logWarn("asrun.hash_failed", "file", path, "error", err)
+		return ""
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	l.hashes[path] = asRunHash{size: info.Size(), modTime: info.ModTime(), sum: sum}
+	return sum
+}
+
+// readAsRun читает записи журнала за даты from..to включительно. Отсутствующие сутки
+// пропускаются, нечитаемые строки выводятся предупреждением в stderr.
+func readAsRun(dir string, from, to time.Time, channel string) ([]AsRunRecord, error) {
+	var records []AsRunRecord
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		path := filepath.Join(dir, asRunFilePrefix+day.Format(asRunDateLayout)+asRunFileExt)
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			var record AsRunRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				fmt.Fprintln(os.Stderr, T("asrun.bad_line", "path", path, "line", line))
+				continue
+			}
+			if channel == "" || record.Channel == channel {
+				records = append(records, record)
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// writeAsRunCSV выводит записи таблицей CSV. Метки, получатели и события во время эфира
+// собираются в одну ячейку: события как тип@позиция[:ролик].
+func writeAsRunCSV(w io.Writer, records []AsRunRecord) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"channel", "kind", "file", "title", "tags", "sha256", "start", "end", "in", "out", "destinations", "result", "error", "interruptions"})
+	for _, r := range records {
+		var interruptions []string
+		for _, i := range r.Interruptions {
+			text := i.Event + "@" + strconv.FormatFloat(i.Position, 'f', 3, 64)
+			if i.File != "" {
+				text += ":" + i.File
+			}
+			interruptions = append(interruptions, text)
+		}
+		out.Write([]string{
+			r.Channel, r.Kind, r.File, r.Title, strings.Join(r.Tags, ", "), r.SHA256,
+			r.Start.Format(time.RFC3339Nano), r.End.Format(time.RFC3339Nano),
+			strconv.FormatFloat(r.In, 'f', 3, 64), strconv.FormatFloat(r.Out, 'f', 3, 64),
+			strings.Join(r.Destinations, " "), r.Result, r.Error, strings.Join(interruptions, "; "),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// runAsRun выполняет подкоманду asrun - выгрузку журнала эфира - и возвращает код завершения.
+//
+//	rtmp-streamer asrun [--from ГГГГ-ММ-ДД] [--to ГГГГ-ММ-ДД] [--channel имя] [--format csv|json]
+func runAsRun(args []string) int {
+	config, err := loadConfig(configFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", T("config.load_failed"), err)
+		return 1
+	}
+	setupLocale(config)
+
+	fs := flag.NewFlagSet("asrun", flag.ContinueOnError)
+	today := time.Now().Format(asRunDateLayout)
+	fromText := fs.String("from", today, T("flag.asrun_from"))
+	toText := fs.String("to", "", T("flag.asrun_to"))
+	channel := fs.String("channel", "", T("flag.asrun_channel"))
+	format := fs.String("format", "csv", T("flag.asrun_format"))
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), T("asrun.usage"))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	if *toText == "" {
+		*toText = *fromText
+	}
+
+	from, err := time.ParseInLocation(asRunDateLayout, *fromText, time.Local)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", T("err.asrun_date"), *fromText)
+		return 2
+	}
+	to, err := time.ParseInLocation(asRunDateLayout, *toText, time.Local)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", T("err.asrun_date"), *toText)
+		return 2
+	}
+	if *format != "csv" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", T("err.asrun_format"), *format)
+		return 2
+	}
+
+	records, err := readAsRun(asRunDirectory(config), from, to, *channel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", T("asrun.read_failed"), err)
+		return 1
+	}
+	if *format == "json" {
+		if records == nil {
+			records = []AsRunRecord{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(records); err != nil {
+			return 1
+		}
+		return 0
+	}
+	if err := writeAsRunCSV(os.Stdout, records); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", T("asrun.read_failed"), err)
+		return 1
+	}
+	return 0
+}
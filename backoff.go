@@ -0,0 +1,186 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Значения по умолчанию для переподключения к RTMP
+const (
+	defaultReconnectInitialDelay = 1 * time.Second  // Первая задержка
+	defaultReconnectMaxDelay     = 60 * time.Second // Максимальная задержка
+	defaultReconnectMultiplier   = 2.0              // Множитель задержки
+	defaultReconnectJitter       = 0.2              // Случайное отклонение задержки (доля)
+	defaultBreakerThreshold      = 3                // Ошибок соединения подряд до размыкания автомата
+)
+
+// Состояния автомата защиты соединения
+const (
+	BreakerClosed   = "closed"    // Соединение работает
+	BreakerOpen     = "open"      // Соединение недоступно, ждем перед следующей попыткой
+	BreakerHalfOpen = "half-open" // Пробная попытка соединения после паузы
+)
+
+// errBreakerOpen возвращается из Connect, пока автомат разомкнут: попытка соединения не выполняется
+var errBreakerOpen = newClassError("err.breaker_open", ErrDial)
+
+// Backoff вычисляет экспоненциально растущую задержку со случайным отклонением
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+
+	current time.Duration
+}
+
+// Next возвращает следующую задержку и увеличивает базовую
+func (b *Backoff) Next() time.Duration {
+	if b.current <= 0 {
+		b.current = b.Initial
+	}
+
+	delay := b.current
+	if b.Jitter > 0 {
+		delta := float64(delay) * b.Jitter
+		delay += time.Duration(delta * (2*rand.Float64() - 1))
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+
+	b.current = time.Duration(float64(b.current) * b.Multiplier)
+	if b.current > b.Max {
+		b.current = b.Max
+	}
+	return delay
+}
+
+// Reset сбрасывает задержку к начальной
+func (b *Backoff) Reset() {
+	b.current = 0
+}
+
+// BreakerSnapshot - состояние автомата для статуса
+type BreakerSnapshot struct {
+	State       string    `json:"state"`
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastFailure time.Time `json:"lastFailure,omitempty"`
+	NextAttempt time.Time `json:"nextAttempt,omitempty"`
+}
+
+// CircuitBreaker отслеживает ошибки соединения с RTMP сервером отдельно от ошибок файлов.
+// После Threshold ошибок подряд автомат размыкается: попытки соединения отклоняются до конца
+// паузы, затем пропускается одна пробная попытка. Пауза растет экспоненциально.
+type CircuitBreaker struct {
+	Threshold int
+
+	mu       sync.Mutex
+	backoff  Backoff
+	state    string
+	failures int
+	lastErr  string
+	lastFail time.Time
+	next     time.Time
+}
+
+// NewCircuitBreaker создает автомат из конфигурации
+func NewCircuitBreaker(config *Config) *CircuitBreaker {
+	r := config.Reconnect
+	b := &CircuitBreaker{
+		Threshold: r.FailureThreshold,
+		state:     BreakerClosed,
+		backoff: Backoff{
+			Initial:    time.Duration(r.InitialDelay * float64(time.Second)),
+			Max:        time.Duration(r.MaxDelay * float64(time.Second)),
+			Multiplier: r.Multiplier,
+			Jitter:     r.Jitter,
+		},
+	}
+	if b.Threshold <= 0 {
+		b.Threshold = defaultBreakerThreshold
+	}
+	if b.backoff.Initial <= 0 {
+		b.backoff.Initial = defaultReconnectInitialDelay
+	}
+	if b.backoff.Max <= 0 {
+		b.backoff.Max = defaultReconnectMaxDelay
+	}
+	if b.backoff.Multiplier < 1 {
+		b.backoff.Multiplier = defaultReconnectMultiplier
+	}
+	if b.backoff.Jitter < 0 || b.backoff.Jitter >= 1 {
+		b.backoff.Jitter = defaultReconnectJitter
+	}
+	return b
+}
+
+// Allow разрешает попытку соединения. В состоянии open попытки отклоняются до конца паузы,
+// после нее автомат переходит в half-open и пропускает одну пробную попытку; пока ее результат
+// не отмечен через Success или Failure, остальные попытки тоже отклоняются.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Now().Before(b.next) {
+			return errBreakerOpen
+		}
+		b.state = BreakerHalfOpen
+	case BreakerHalfOpen:
+		return errBreakerOpen
+	}
+	return nil
+}
+
+// Success отмечает успешное соединение и замыкает автомат
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.next = time.Time{}
+	b.backoff.Reset()
+}
+
+// Abort отменяет пробную попытку, результат которой не стал известен, например если соединение
+// закрыто до публикации: автомат возвращается в open, и следующая попытка снова будет пробной
+func (b *CircuitBreaker) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+// Failure отмечает ошибку соединения и возвращает задержку до следующей попытки
+func (b *CircuitBreaker) Failure(err error) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.lastErr = err.Error()
+	b.lastFail = time.Now()
+	if b.failures >= b.Threshold {
+		b.state = BreakerOpen
+	}
+
+	delay := b.backoff.Next()
+	b.next = time.Now().Add(delay)
+	return delay
+}
+
+// Snapshot возвращает текущее состояние автомата
+func (b *CircuitBreaker) Snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerSnapshot{
+		State:       b.state,
+		Failures:    b.failures,
+		LastError:   b.lastErr,
+		LastFailure: b.lastFail,
+		NextAttempt: b.next,
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	failure := errors.New("refused")
+	tests := []struct {
+		name      string
+		steps     func(b *CircuitBreaker) // Действия перед проверкой
+		wantState string
+		wantAllow bool
+	}{
+		{
+			name:      "closed below threshold",
+			steps:     func(b *CircuitBreaker) { b.Failure(failure) },
+			wantState: BreakerClosed,
+			wantAllow: true,
+		},
+		{
+			name:      "open rejects until delay passes",
+			steps:     func(b *CircuitBreaker) { b.Failure(failure); b.Failure(failure) },
+			wantState: BreakerOpen,
+		},
+		{
+			name: "one trial after delay",
+			steps: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				b.Failure(failure)
+				b.next = time.Now().Add(-time.Millisecond)
+				if err := b.Allow(); err != nil {
+					t.Errorf("trial attempt rejected: %v", err)
+				}
+			},
+			wantState: BreakerHalfOpen,
+		},
+		{
+			name: "failed trial opens again",
+			steps: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				b.Failure(failure)
+				b.next = time.Now().Add(-time.Millisecond)
+				b.Allow()
+				b.Failure(failure)
+			},
+			wantState: BreakerOpen,
+		},
+		{
+			name: "successful trial closes",
+			steps: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				b.Failure(failure)
+				b.next = time.Now().Add(-time.Millisecond)
+				b.Allow()
+				b.Success()
+			},
+			wantState: BreakerClosed,
+			wantAllow: true,
+		},
+		{
+			name: "aborted trial allows a new trial",
+			steps: func(b *CircuitBreaker) {
+				b.Failure(failure)
+				b.Failure(failure)
+				b.next = time.Now().Add(-time.Millisecond)
+				b.Allow()
+				b.Abort()
+			},
+			wantState: BreakerOpen,
+			wantAllow: true,
+		},
+		{
+			name:      "abort without trial keeps closed",
+			steps:     func(b *CircuitBreaker) { b.Abort() },
+			wantState: BreakerClosed,
+			wantAllow: true,
+		},
+	}
+	for _, tt := range tests {
+		config := &Config{}
+		config.Reconnect.FailureThreshold = 2
+		config.Reconnect.InitialDelay = 60
+		b := NewCircuitBreaker(config)
+		tt.steps(b)
+		if state := b.Snapshot().State; state != tt.wantState {
+			t.Errorf("%s: state = %s, want %s", tt.name, state, tt.wantState)
+		}
+		if err := b.Allow(); (err == nil) != tt.wantAllow {
+			t.Errorf("%s: Allow() = %v, want allowed %v", tt.name, err, tt.wantAllow)
+		} else if err != nil && !errors.Is(err, errBreakerOpen) {
+			t.Errorf("%s: Allow() = %v, want errBreakerOpen", tt.name, err)
+		}
+	}
+}
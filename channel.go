@@ -0,0 +1,891 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultChannelName   = "main"           // Имя канала, если в конфигурации нет списка channels
+	channelRestartMax    = 5 * time.Minute  // Максимальная задержка перезапуска канала после сбоя
+	channelStableRunTime = 10 * time.Minute // Время работы, после которого задержка перезапуска сбрасывается
+)
+
+// errReload возвращается из Run, когда оператор запросил перезагрузку канала
+var errReload = newCodedError("err.channel_reload")
+
+// Channel - независимый канал: свой каталог видео, RTMP назначение, настройки и файлы состояния.
+// Каждый канал работает в своей горутине и не влияет на остальные.
+type Channel struct {
+	Name    string
+	Log     *Logger
+	Monitor *ChannelMonitor // Живое состояние для панели оператора
+	Events  *EventBus       // Шина событий процесса, может отсутствовать
+	Queue   *UpNextQueue    // Очередь оператора перед обычным порядком
+	Media   *MediaCatalog   // Каталог медиафайлов процесса, может отсутствовать
+
+	mu      sync.Mutex
+	config  *Config        // Заменяется при перезагрузке, читается через Config
+	control channelControl // Команды оператора
+}
+
+// NewChannel создает канал из конфигурации канала; события публикуются в events
+func NewChannel(config *Config, events *EventBus) *Channel {
+	monitor := NewChannelMonitor(config.Name)
+	log := NewLogger("channel", config.Name)
+	log.onEvent = monitor.RecordEvent
+
+	return &Channel{
+		Name:    config.Name,
+		Log:     log,
+		Monitor: monitor,
+		Events:  events,
+		Queue:   NewUpNextQueue(),
+		config:  config,
+	}
+}
+
+// Config возвращает текущую конфигурацию канала. Конфигурация не меняется,
+// перезагрузка заменяет ее целиком, поэтому результат можно читать без блокировки.
+func (ch *Channel) Config() *Config {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.config
+}
+
+// StateFile возвращает файл состояния канала
+func (ch *Channel) StateFile() string {
+	return ch.Config().Settings.StateFile
+}
+
+// StatusFile возвращает файл статуса канала, пусто = не записывать
+func (ch *Channel) StatusFile() string {
+	return ch.Config().Settings.StatusFile
+}
+
+// Status возвращает живое состояние канала для панели оператора
+func (ch *Channel) Status() MonitorSnapshot {
+	status := ch.Monitor.Snapshot()
+	status.Paused = ch.Paused()
+	status.OffAir = ch.OffAir()
+	status.Enqueued = ch.Queue.Items()
+	return status
+}
+
+// Supervise запускает канал и перезапускает его после паники или ошибки с экспоненциальной задержкой.
+// Штатное завершение и неустранимая ошибка останавливают только этот канал.
+func (ch *Channel) Supervise() error {
+	backoff := &Backoff{
+		Initial:    time.Duration(retryDelay) * time.Second,
+		Max:        channelRestartMax,
+		Multiplier: defaultReconnectMultiplier,
+		Jitter:     defaultReconnectJitter,
+	}
+
+	for {
+		started := time.Now()
+		err := ch.runSafe()
+		if err == nil {
+			ch.Log.Info("channel.stopped")
+			return nil
+		}
+		if errors.Is(err, errReload) {
+			// Перезагрузка по команде оператора выполняется сразу, без задержки
+			if err := ch.reloadConfig(); err != nil {
+				ch.Log.Error("control.reload_failed", "error", err)
+			}
+			backoff.Reset()
+			continue
+		}
+		if classifyError(err) == ActionFatal {
+			ch.Log.Error("channel.fatal", "error", err)
+			return err
+		}
+
+		if time.Since(started) > channelStableRunTime {
+			backoff.Reset()
+		}
+		delay := backoff.Next()
+		ch.Log.Error("channel.restart", "error", err, "retry_in", delay)
+		time.Sleep(delay)
+	}
+}
+
+// reloadConfig перечитывает файл конфигурации и берет из него настройки этого канала
+func (ch *Channel) reloadConfig() error {
+	config, err := loadConfig(configFilePath)
+	if err != nil {
+		return err
+	}
+	configs, err := channelConfigs(config)
+	if err != nil {
+		return err
+	}
+	for _, channelConfig := range configs {
+		if channelConfig.Name == ch.Name {
+			ch.mu.Lock()
+			ch.config = channelConfig
+			ch.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %s", T("err.channel_missing"), ch.Name)
+}
+
+// runSafe запускает канал, превращая панику в ошибку, чтобы она не завершила процесс
+func (ch *Channel) runSafe() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrChannelPanic, r)
+		}
+	}()
+	return ch.Run()
+}
+
+// Run транслирует каталог канала. Возвращает nil после однократного проигрывания
+// с действием exit или ошибку, из-за которой канал нужно перезапустить или остановить.
+func (ch *Channel) Run() error {
+	config := ch.Config()
+
+	// Устанавливаем минимальное время воспроизведения, если оно указано в конфигурации
+	minFilePlayTime := minPlayTime
+	if config.Settings.MinPlayTime > 0 {
+		minFilePlayTime = time.Duration(config.Settings.MinPlayTime) * time.Second
+		ch.Log.Info("config.min_play_time", "min_play_time", minFilePlayTime)
+	}
+
+	videoDir := config.Video.Directory
+	rtmpURL := config.RTMP.URL + config.RTMP.Key
+
+	ch.Log.Info("channel.start", "destination", rtmpURL, "video_dir", videoDir)
+
+	// Информация о настройках битрейта
+	if config.Settings.ForceBitrate > 0 {
+		ch.Log.Info("config.force_bitrate", "bitrate_kbps", config.Settings.ForceBitrate/1000)
+	} else {
+		ch.Log.Info("config.min_bitrate", "bitrate_kbps", minBitrate/1000)
+	}
+	if config.Settings.ForceKeyframe {
+		ch.Log.Info("config.force_keyframe", "interval_sec", config.Settings.KeyframeSeconds)
+	}
+	if config.Settings.DisableEarlyEnd {
+		ch.Log.Info("config.early_end_disabled")
+	}
+	if config.Settings.RestoreState {
+		ch.Log.Info("config.restore_state")
+	}
+	if !config.Video.LoopMode {
+		ch.Log.Info("config.play_once", "end_action", config.Video.EndAction)
+	}
+	if config.AdBreaks.Enabled {
+		ch.Log.Info("config.ad_breaks", "dir", config.AdBreaks.Directory)
+	}
+
+	// Прием новых файлов через входящий каталог с проверкой и карантином
+	stager := NewStager(config, ch.Log)
+	if config.Staging.Enabled {
+		go stager.Run()
+		defer stager.Stop()
+	}
+
+	// Часы вещания: вне окна канал отключается или показывает заставку вне эфира
+	schedule, err := NewOnAirSchedule(config)
+	if err != nil {
+		return err
+	}
+	if schedule != nil {
+		ch.setOffAir(!schedule.OnAir(time.Now()))
+		defer ch.setOffAir(false)
+		onAir := NewOnAirWatcher(schedule, ch)
+		go onAir.Run()
+		defer onAir.Stop()
+	}
+
+	// Экстренные вставки из каталога; через панель и ctl они доступны всегда
+	if config.Override.Enabled {
+		overrides := NewOverrideWatcher(ch)
+		go overrides.Run()
+		defer overrides.Stop()
+	}
+
+	// Первоначальное сканирование директории и наблюдение за изменениями
+	catalog := NewDirCatalog(videoDir, ch.Log)
+	defer catalog.Close()
+	mp4Files := catalog.Files()
+	ch.Media.Refresh(videoDir, mp4Files)
+	if len(mp4Files) == 0 && !config.Staging.Enabled && config.Slate.File == "" {
+		// Без заставки держать эфир нечем: супервизор перезапустит канал с задержкой, пока файлы не появятся.
+		// С заставкой канал остается в эфире и ждет файлы в основном цикле.
+		return fmt.Errorf("%w: %s", ErrNoVideoFiles, videoDir)
+	}
+
+	// Создаем общий калькулятор битрейта для всей сессии
+	sessionBitrate := NewBitrateCalculator(10)
+
+	// Публикатор держит RTMP-соединение между файлами и вставками
+	publisher := NewPublisher(rtmpURL, ch.Log)
+	defer publisher.Close()
+
+	// Автомат защиты соединения: ошибки соединения не расходуют попытки файла
+	breaker := NewCircuitBreaker(config)
+	publisher.Breaker = breaker
+
+	// Панель оператора видит битрейт, соединение и автомат защиты; ключ потока в нее не попадает
+	ch.Monitor.SetDestination(config.RTMP.URL)
+	ch.Monitor.SetBreaker(breaker)
+	sessionBitrate.OnSample = ch.Monitor.AddBitrateSample
+	publisher.OnConnection = ch.Monitor.SetConnected
+
+	// Планировщик рекламных пауз
+	ads := NewAdScheduler(config, ch.Log)
+	interstitials := NewInterstitials(config, ch.Log)
+	// Отправленные ролики публикуются событием clip.played, например для журнала эфира
+	ads.OnClip = func(path string, started time.Time, played time.Duration, err error) {
+		ch.emitClip(ClipAd, path, started, played, err)
+	}
+	interstitials.OnClip = func(path string, started time.Time, played time.Duration, err error) {
+		ch.emitClip(ClipInterstitial, path, started, played, err)
+	}
+
+	// Проверяем существование и загружаем состояние, если необходимо
+	var state *StreamState
+	if config.Settings.RestoreState {
+		var err error
+		state, err = loadStreamState(ch)
+		if err != nil {
+			ch.Log.Warn("state.load_failed", "error", err)
+		}
+		restoreStateFile(ch, state, videoDir, mp4Files)
+	}
+
+	// Цикл непрерывного стриминга
+	streamCount := 0
+	consecutiveErrors := 0
+
+	// Плейлист определяет порядок файлов; при восстановлении продолжаем ту же последовательность
+	playlist := NewPlaylist(config, ch.Log)
+	ch.Log.Info("playlist.mode", "mode", playlist.Mode)
+	if state != nil && playlist.Restore(state.Playlist) {
+		ch.Log.Info("playlist.restored", "loop", state.Playlist.Loop+1,
+			"index", state.Playlist.Index+1, "total", len(state.Playlist.Order))
+	}
+
+	// Файлы, уже проигранные в режиме однократного проигрывания
+	played := make(map[string]bool)
+	if !config.Video.LoopMode {
+		for _, name := range playlist.Played() {
+			played[name] = true
+		}
+		mp4Files = excludePlayed(mp4Files, played)
+	}
+	mp4Files = excludeUnavailable(mp4Files, catalog.Meta, time.Now())
+	playlist.FileWeights = metaWeights(mp4Files, catalog.Meta)
+	playlist.Refresh(fileNames(mp4Files))
+
+	// Очередь оператора продолжается с того же места
+	if state != nil && ch.Queue.Restore(state.Queue, state.QueueCurrent) {
+		files := len(state.Queue)
+		if state.QueueCurrent != "" {
+			files++
+		}
+		ch.Log.Info("queue.restored", "files", files)
+	}
+
+	// Восстанавливаем позицию в плейлисте, если есть сохраненное состояние.
+	// Прерванный файл из очереди продолжается без сдвига обычного порядка.
+	if state != nil && state.CurrentFile != "" && state.CurrentFile != state.QueueCurrent {
+		if name, ok := playlist.Current(); (ok && name == state.CurrentFile) || playlist.Seek(state.CurrentFile) {
+			ch.Log.Info("state.resume", "index", playlist.Index()+1, "file", state.CurrentFile, "position", state.Position)
+		} else {
+			ch.Log.Warn("state.file_missing", "file", state.CurrentFile)
+			state = nil // Сбрасываем состояние, если файл не найден
+		}
+	}
+
+	// Текущее состояние для сохранения
+	currentState := &StreamState{
+		FileIndex: playlist.Index(),
+		Playlist:  playlist.State(),
+	}
+
+	// Соединение восстановлено, когда сервер снова принял публикацию, а не когда открылось TCP-соединение
+	publisher.OnRestored = func() {
+		ch.Log.Info("conn.restored", "destination", rtmpURL)
+		ch.emit(EventReconnected, ReconnectEvent{Destination: config.RTMP.URL, File: currentState.CurrentFile,
+			Position: currentState.Position.Seconds(), Breaker: BreakerClosed})
+	}
+
+	// Создаем таймер для периодического сохранения состояния
+	saveStateTicker := time.NewTicker(saveStateInterval)
+	defer saveStateTicker.Stop()
+	done := make(chan struct{})
+	defer close(done)
+
+	// Запускаем горутину для сохранения состояния; она завершается вместе с каналом
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-saveStateTicker.C:
+			case <-ch.Queue.Changed():
+				// Изменения очереди сохраняются сразу, чтобы не потеряться при перезапуске
+			}
+
+			// Проверяем, что есть какая-то информация для сохранения
+			if currentState.CurrentFile != "" {
+				err := saveStreamState(ch, *currentState)
+				if err != nil {
+					ch.Log.Error("state.save_failed", "error", err)
+				}
+			}
+			updateStatusFile(ch, currentState, sessionBitrate, breaker)
+		}
+	}()
+
+	// Непроигранные файлы, которые можно выпустить в эфир, или файлы в очереди оператора;
+	// прерывают удержание на заставке после конца каталога
+	hasFiles := func() bool {
+		if ch.Queue.Pending() {
+			return true
+		}
+		files := catalog.Files()
+		if !config.Video.LoopMode {
+			files = excludePlayed(files, played)
+		}
+		return len(excludeUnavailable(files, catalog.Meta, time.Now())) > 0
+	}
+
+	// finishFile завершает проигранный или пропущенный файл и переходит к следующему; файл из очереди
+	// не сдвигает обычный порядок. Возвращает true, если круг закончен и нужно начать новый.
+	finishFile := func(name string, fromQueue bool) bool {
+		if fromQueue {
+			ch.Queue.Done()
+			return false
+		}
+		played[name] = true
+		playlist.Advance()
+		currentState.Playlist = playlist.State()
+		if !playlist.LoopDone() {
+			return false
+		}
+		if !config.Video.LoopMode {
+			ch.Log.Info("loop.all_played")
+			return true
+		}
+		ch.Log.Info("loop.restart")
+		// Перед новым циклом делаем небольшую паузу для стабильности
+		time.Sleep(1 * time.Second)
+		return true
+	}
+
+	for {
+		// Перезагрузка выполняется и тогда, когда файлов нет и канал держит заставку
+		if ch.takeReload() {
+			return ch.reload(currentState)
+		}
+		if ch.OffAir() {
+			holdOffAir(publisher, ch, schedule, sessionBitrate)
+			continue
+		}
+
+		streamCount++
+		ch.Log.Info("loop.start", "loop", streamCount)
+
+		// Актуальный список файлов каталога перед каждым циклом
+		catalogVersion := catalog.Version()
+		mp4Files = catalog.Files()
+		ch.Media.Refresh(videoDir, mp4Files)
+
+		// В режиме однократного проигрывания оставляем только непроигранные файлы
+		if !config.Video.LoopMode && len(played) > 0 {
+			mp4Files = excludePlayed(mp4Files, played)
+			// Очередь оператора выходит в эфир и после того, как каталог проигран
+			if len(mp4Files) == 0 && playlist.LoopDone() && !ch.Queue.Pending() {
+				ch.setLoop(nil)
+				if ch.overridePending() {
+					playOverrides(publisher, ch, sessionBitrate)
+					continue
+				}
+				if !handleEndAction(ch, publisher, sessionBitrate, currentState, hasFiles) {
+					return nil
+				}
+				if !hasFiles() && !ch.reloadPending() && !ch.OffAir() {
+					time.Sleep(waitForFilesInterval)
+				}
+				continue
+			}
+		}
+
+		// Файлы вне дат из их метаданных в круг не попадают
+		mp4Files = excludeUnavailable(mp4Files, catalog.Meta, time.Now())
+		playlist.FileWeights = metaWeights(mp4Files, catalog.Meta)
+
+		if len(mp4Files) == 0 && !ch.Queue.Pending() {
+			ch.setLoop(nil)
+			if ch.overridePending() {
+				playOverrides(publisher, ch, sessionBitrate)
+				continue
+			}
+			// Пока нет контента, эфир удерживается на заставке
+			if playSlate(publisher, ch, sessionBitrate, 0) {
+				continue
+			}
+			ch.Log.Warn("catalog.waiting", "dir", videoDir, "retry_in", 5*time.Second)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		if len(mp4Files) > 0 {
+			playlist.Refresh(fileNames(mp4Files))
+		}
+
+		attempted := false // В этом круге хотя бы один файл дошел до попытки эфира
+		for {
+			// Команды оператора выполняются на границе файла
+			if ch.takeReload() {
+				return ch.reload(currentState)
+			}
+			if name := ch.takeJump(); name != "" {
+				if playlist.Seek(name) {
+					ch.Log.Info("control.jump", "file", name)
+					state = nil
+				} else {
+					ch.Log.Warn("control.jump_missing", "file", name)
+				}
+			}
+			if ch.takeReconnect() {
+				ch.Log.Info("control.reconnect", "destination", config.RTMP.URL)
+				publisher.Close()
+			}
+			ch.takeSkip() // Пропуск вне файла ничего не делает
+			if ch.OffAir() {
+				// Прерванный файл продолжится с сохраненной позиции после открытия окна
+				if err := saveStreamState(ch, *currentState); err != nil {
+					ch.Log.Error("state.save_failed", "error", err)
+				}
+				holdOffAir(publisher, ch, schedule, sessionBitrate)
+				continue
+			}
+			if ch.overridePending() {
+				playOverrides(publisher, ch, sessionBitrate)
+				continue
+			}
+			if ch.Paused() {
+				holdWhilePaused(publisher, ch, sessionBitrate)
+				continue
+			}
+
+			// Изменения каталога применяются к оставшейся части круга
+			if version := catalog.Version(); version != catalogVersion {
+				catalogVersion = version
+				mp4Files = catalog.Files()
+				ch.Media.Refresh(videoDir, mp4Files)
+				if !config.Video.LoopMode {
+					mp4Files = excludePlayed(mp4Files, played)
+				}
+				mp4Files = excludeUnavailable(mp4Files, catalog.Meta, time.Now())
+				playlist.FileWeights = metaWeights(mp4Files, catalog.Meta)
+				playlist.Update(fileNames(mp4Files))
+				ch.Log.Info("catalog.changed", "files", len(mp4Files), "queued", playlist.Len()-playlist.Index())
+				ch.emit(EventDirectoryChanged, DirectoryChangedEvent{Directory: videoDir, Files: len(mp4Files),
+					Queued: playlist.Len() - playlist.Index()})
+			}
+			// Команда jump принимается только для файлов этого порядка
+			ch.setLoop(playlist.State().Order)
+
+			// Файлы из очереди оператора идут раньше обычного порядка, в том числе после конца круга
+			name, ok := playlist.Current()
+			next := name
+			queued, fromQueue := ch.Queue.Next()
+			if !ok && !fromQueue {
+				break
+			}
+			files := mp4Files
+			if fromQueue {
+				name = queued
+				files = catalog.Files()
+			}
+
+			// Файл мог быть удален после построения порядка
+			file := findDirEntry(files, name)
+			if file == nil {
+				ch.Log.Warn("file.missing", "file", name)
+				if finishFile(name, fromQueue) {
+					break
+				}
+				continue
+			}
+
+			fileIndex := playlist.Index()
+			videoPath := filepath.Join(videoDir, file.Name())
+
+			// Файл, который по результату пробы не демультиплексируется, пропускается без попыток
+			media := ch.Media.Lookup(videoPath)
+			if !media.Playable() {
+				ch.Log.Warn("media.skip_invalid", "file", file.Name(), "reason", media.Reason, "error", media.Error)
+				if finishFile(file.Name(), fromQueue) {
+					break
+				}
+				continue
+			}
+
+			// Срок показа из метаданных мог закончиться после построения порядка
+			meta := catalog.Meta(file.Name())
+			if !meta.Available(time.Now()) {
+				ch.Log.Warn("meta.not_available", "file", file.Name(), "not_before", meta.NotBefore, "not_after", meta.NotAfter)
+				if finishFile(file.Name(), fromQueue) {
+					break
+				}
+				continue
+			}
+			attempted = true
+
+			ch.Log.Info("file.start", "index", fileIndex+1, "total", playlist.Len(), "file", file.Name(),
+				"destination", rtmpURL, "bitrate_kbps", sessionBitrate.GetBitrate()/1000)
+			fileDuration := media.Duration()
+			if fileDuration == 0 {
+				fileDuration = mp4Duration(videoPath)
+			}
+			if meta != nil && meta.OutPoint > 0 && (fileDuration == 0 || meta.OutPoint < fileDuration) {
+				fileDuration = meta.OutPoint
+			}
+			ch.Monitor.SetNowPlaying(file.Name(), fileIndex, playlist.Len(), fileDuration)
+			ch.Monitor.SetPlaylist(upNext(ch.Queue.Items(), fromQueue, next, playlist), fileNames(mp4Files))
+
+			// Отпечаток содержимого позволяет после перезапуска найти файл и не перематывать измененный
+			fingerprint, fingerprintErr := ch.Media.Fingerprint(videoPath)
+			if fingerprintErr != nil {
+				ch.Log.Debug("state.fingerprint_failed", "file", file.Name(), "error", fingerprintErr)
+			}
+
+			// Обновляем информацию о текущем файле в состоянии
+			currentState.CurrentFile = file.Name()
+			currentState.Fingerprint = fingerprint
+			currentState.LastSaveTime = time.Now()
+			currentState.FileIndex = fileIndex
+			currentState.Playlist = playlist.State()
+
+			// Определяем, нужно ли использовать начальную позицию для продолжения
+			var startPosition time.Duration = 0
+			if state != nil && state.CurrentFile == file.Name() && state.Fingerprint != "" && state.Fingerprint != fingerprint {
+				// Файл заменен другим содержимым, пока канал был вне эфира или на паузе
+				ch.Log.Warn("state.file_changed", "file", file.Name(), "position", state.Position)
+				state = nil
+			} else if state != nil && state.CurrentFile == file.Name() {
+				startPosition = state.Position
+				ch.Log.Info("file.resume", "file", file.Name(), "position", startPosition)
+				// Сбрасываем состояние, чтобы больше не использовать его
+				state = nil
+			}
+
+			// Файл начинается с точки начала из метаданных; продолжение раньше нее тоже переносится на нее
+			resumed := startPosition > 0
+			if meta != nil && startPosition < meta.InPoint {
+				startPosition = meta.InPoint
+				ch.Log.Info("meta.in_point", "file", file.Name(), "position", startPosition)
+			}
+
+			// Минимальное время воспроизведения может быть задано для файла
+			fileMinPlayTime := minFilePlayTime
+			if meta != nil && meta.MinPlayTime > 0 {
+				fileMinPlayTime = time.Duration(meta.MinPlayTime) * time.Second
+			}
+
+			// Служебные ролики перед файлом; продолжение прерванного файла их не получает
+			if !resumed {
+				if clips := interstitials.Before(file.Name()); len(clips) > 0 {
+					if err := ensureConnected(publisher, breaker, ch, currentState, sessionBitrate); err != nil {
+						return err
+					}
+					interstitials.Play(clips, publisher, sessionBitrate)
+				}
+			}
+			ch.emit(EventFileStarted, FileStartedEvent{File: file.Name(), Index: fileIndex + 1, Total: playlist.Len(),
+				Position: startPosition.Seconds(), Duration: fileDuration.Seconds(), Title: meta.TitleOr(""), Tags: meta.TagList()})
+
+			// Попытки трансляции с повторами при ошибках
+			var streamStatus StreamStatus
+			var streamErr error
+
+			startTime := time.Now()
+
+			// Определяем, нужно ли использовать принудительный битрейт
+			targetBitrate := minBitrate
+			if config.Settings.ForceBitrate > 0 {
+				targetBitrate = config.Settings.ForceBitrate
+			}
+
+			corrupt := false
+		attempts:
+			for attempt := 1; attempt <= maxRetries; {
+				// Соединение восстанавливается с экспоненциальной задержкой, позиция в плейлисте сохраняется
+				if err := ensureConnected(publisher, breaker, ch, currentState, sessionBitrate); err != nil {
+					return err
+				}
+				updateStatusFile(ch, currentState, sessionBitrate, breaker)
+
+				if attempt > 1 {
+					ch.Log.Warn("file.retry", "file", file.Name(), "attempt", attempt, "max_attempts", maxRetries)
+					ch.emit(EventRetry, RetryEvent{File: file.Name(), Attempt: attempt, MaxAttempts: maxRetries})
+					// Паузу между попытками заполняем заставкой, чтобы канал не уходил из эфира
+					if !playSlate(publisher, ch, sessionBitrate, time.Duration(retryDelay)*time.Second) {
+						time.Sleep(time.Duration(retryDelay) * time.Second)
+					}
+				}
+
+				// Передаем информацию о желаемом битрейте, калькулятор и начальную позицию
+				streamStatus, streamErr = streamFileToRTMP(videoPath, publisher, sessionBitrate,
+					targetBitrate, ch, fileMinPlayTime, startPosition, currentState, ads.BreaksInFile(file.Name()), ads, media, meta)
+				duration := time.Since(startTime)
+
+				if streamErr == nil {
+					// Если streamStatus.PrepareNext = true, значит мы заранее вышли для подготовки следующего файла
+					if streamStatus.Interrupted && ch.OffAir() {
+						ch.Log.Info("onair.interrupted", "file", file.Name(), "position", currentState.Position)
+					} else if streamStatus.Interrupted {
+						ch.Log.Info("control.interrupted", "file", file.Name(), "position", currentState.Position)
+					} else if streamStatus.PrepareNext {
+						ch.Log.Info("file.prepare_next", "file", file.Name(), "duration", duration)
+					} else {
+						ch.Log.Info("file.done", "file", file.Name(), "duration", duration)
+					}
+					ch.emit(EventFileFinished, FileFinishedEvent{File: file.Name(), Status: streamStatus,
+						Position: currentState.Position.Seconds()})
+					// Сбрасываем счетчик ошибок при успешной передаче
+					consecutiveErrors = 0
+					break
+				}
+
+				switch classifyError(streamErr) {
+				case ActionFatal:
+					return streamErr
+
+				case ActionRetryConnection:
+					// Соединение потеряно - файл не виноват, продолжаем его с той же позиции
+					publisher.Close()
+					// Отказ в рукопожатии или публикации публикатор уже отметил в автомате защиты
+					delay := time.Until(breaker.Snapshot().NextAttempt)
+					if !errors.Is(streamErr, ErrHandshake) {
+						delay = breaker.Failure(streamErr)
+					}
+					ch.Log.Error("conn.lost", "file", file.Name(), "position", currentState.Position, "destination", rtmpURL,
+						"error", streamErr, "retry_in", delay, "breaker", breaker.Snapshot().State)
+					ch.emit(EventReconnect, ReconnectEvent{Destination: config.RTMP.URL, File: file.Name(),
+						Position: currentState.Position.Seconds(), Error: streamErr.Error(), RetryIn: delay.Seconds(),
+						Breaker: breaker.Snapshot().State})
+					startPosition = currentState.Position
+					updateStatusFile(ch, currentState, sessionBitrate, breaker)
+					time.Sleep(delay)
+
+				case ActionQuarantine:
+					// Повторять поврежденный файл бессмысленно
+					ch.Log.Error("file.corrupt", "file", file.Name(), "error", streamErr)
+					consecutiveErrors++
+					corrupt = true
+					break attempts
+
+				default:
+					ch.Log.Error("file.attempt_failed", "file", file.Name(), "attempt", attempt, "error", streamErr)
+					consecutiveErrors++
+					attempt++
+				}
+			}
+
+			// Файл прерван паузой, переходом, переподключением или перезагрузкой:
+			// позже он продолжится с той же позиции.
+			// Пропуск завершает файл как обычно.
+			if streamErr == nil && streamStatus.Interrupted {
+				if !ch.takeSkip() {
+					state = &StreamState{CurrentFile: file.Name(), Position: currentState.Position, Fingerprint: fingerprint}
+					continue
+				}
+				ch.Log.Info("control.skip", "file", file.Name(), "position", currentState.Position)
+			}
+
+			// Если слишком много ошибок подряд, делаем паузу и считаем, что нужно переподключиться
+			if consecutiveErrors >= maxConsecutiveErrors {
+				ch.Log.Error("errors.too_many", "errors", consecutiveErrors, "pause", reconnectTimeout)
+				time.Sleep(reconnectTimeout)
+				consecutiveErrors = 0
+			}
+
+			if streamErr != nil {
+				if corrupt {
+					ch.Log.Error("file.skipped", "file", file.Name())
+				} else {
+					ch.Log.Error("file.failed", "file", file.Name(), "attempts", maxRetries)
+				}
+				ch.emit(EventFileFinished, FileFinishedEvent{File: file.Name(), Status: streamStatus,
+					Position: currentState.Position.Seconds(), Error: streamErr.Error()})
+
+				// Поврежденный файл убираем в карантин, чтобы не повторять его на каждом круге
+				if config.Staging.Enabled {
+					reason := streamErr
+					var probe *ProbeResult
+					if !corrupt {
+						probe, reason = probeVideoFile(videoPath)
+					}
+					if reason != nil {
+						if qerr := quarantineFile(videoPath, stager.QuarantineDir, reason, probe, ch.Log); qerr != nil {
+							ch.Log.Error("quarantine.failed", "file", file.Name(), "error", qerr)
+						}
+					}
+				}
+				playSlate(publisher, ch, sessionBitrate, 0)
+			} else {
+				// Выводим информацию о битрейте после успешной передачи
+				ch.Log.Info("file.bitrate", "file", file.Name(), "bitrate_kbps", streamStatus.Bitrate/1000,
+					"sent_mb", float64(sessionBitrate.GetTotalBytes())/(1024*1024))
+
+				// Аутро файла и рекламная пауза на границе файла
+				interstitials.Play(interstitials.After(file.Name()), publisher, sessionBitrate)
+				if breakDuration, ok := ads.BreakAfterFile(file.Name()); ok && publisher.Connected() {
+					if _, err := ads.RunBreak(publisher, breakDuration, sessionBitrate); err != nil {
+						ch.Log.Error("ads.break_failed", "error", err)
+					}
+				}
+			}
+
+			// Переподключаемся для каждого нового файла, если это настроено
+			if config.Settings.ReconnectOnNewFile {
+				publisher.Close()
+			}
+
+			// Сохраняем состояние после завершения файла
+			err := saveStreamState(ch, *currentState)
+			if err != nil {
+				ch.Log.Error("state.save_failed", "error", err)
+			}
+
+			// Сбрасываем текущую позицию, так как будет новый файл
+			currentState.Position = 0
+
+			// Переходим к следующему файлу; в конце круга завершаем внутренний цикл,
+			// чтобы начать новый с обновленным списком файлов
+			if finishFile(file.Name(), fromQueue) {
+				break
+			}
+		}
+
+		// Ни один файл круга не дошел до эфира: все не прошли пробу, вышли из срока показа или удалены.
+		// Пока каталог не изменится, эфир удерживается на заставке, чтобы не перебирать круги без пауз.
+		// В однократном режиме такие файлы отмечены проигранными, и дальше выполняется действие по окончании.
+		if !attempted && config.Video.LoopMode {
+			ch.Log.Warn("loop.nothing_playable", "dir", videoDir)
+			if !playSlate(publisher, ch, sessionBitrate, 0) {
+				time.Sleep(5 * time.Second)
+			}
+		}
+	}
+}
+
+// ensureConnected устанавливает соединение с RTMP сервером, повторяя попытки
+// с экспоненциальной задержкой и случайным отклонением, пока соединение не появится.
+// Ошибки и паузы учитывает автомат защиты публикатора. Возвращает ошибку, только если продолжать бессмысленно.
+func ensureConnected(pub *Publisher, breaker *CircuitBreaker, ch *Channel, state *StreamState, sessionBitrate *BitrateCalculator) error {
+	for !pub.Connected() {
+		err := pub.Connect()
+		if err == nil {
+			return nil
+		}
+
+		if classifyError(err) == ActionFatal {
+			return err
+		}
+
+		// Автомат разомкнут другой попыткой, например заставкой: ждем конца паузы молча
+		delay := time.Until(breaker.Snapshot().NextAttempt)
+		if errors.Is(err, errBreakerOpen) {
+			time.Sleep(delay)
+			continue
+		}
+		ch.Log.Error("conn.failed", "destination", pub.URL, "position", state.Position, "error", err,
+			"retry_in", delay, "breaker", breaker.Snapshot().State)
+		ch.emit(EventReconnect, ReconnectEvent{Destination: ch.Config().RTMP.URL, File: state.CurrentFile,
+			Position: state.Position.Seconds(), Error: err.Error(), RetryIn: delay.Seconds(), Breaker: breaker.Snapshot().State})
+		updateStatusFile(ch, state, sessionBitrate, breaker)
+		time.Sleep(delay)
+	}
+	return nil
+}
+
+// updateStatusFile записывает текущий статус трансляции в файл статуса
+func updateStatusFile(ch *Channel, state *StreamState, sessionBitrate *BitrateCalculator, breaker *CircuitBreaker) {
+	if ch.StatusFile() == "" {
+		return
+	}
+
+	status := StatusSnapshot{
+		Channel:     ch.Name,
+		CurrentFile: state.CurrentFile,
+		Position:    state.Position,
+		FileIndex:   state.FileIndex,
+		Bitrate:     sessionBitrate.GetBitrate(),
+		Breaker:     breaker.Snapshot(),
+	}
+	if err := writeStatusFile(ch.StatusFile(), status); err != nil {
+		ch.Log.Error("status.save_failed", "error", err)
+	}
+}
+
+// reload сохраняет состояние перед перезагрузкой канала по команде оператора
+func (ch *Channel) reload(state *StreamState) error {
+	ch.Log.Info("control.reload")
+	if err := saveStreamState(ch, *state); err != nil {
+		ch.Log.Error("state.save_failed", "error", err)
+	}
+	return errReload
+}
+
+// handleEndAction выполняет действие после однократного проигрывания каталога.
+// hasFiles сообщает о новых непроигранных файлах. Возвращает false, если канал должен завершиться.
+func handleEndAction(ch *Channel, pub *Publisher, sessionBitrate *BitrateCalculator, state *StreamState, hasFiles func() bool) bool {
+	switch ch.Config().Video.EndAction {
+	case EndActionSlate:
+		if ch.Config().Slate.File != "" {
+			holdOnSlate(pub, ch, sessionBitrate, hasFiles)
+			return true
+		}
+		ch.Log.Warn("end.no_slate")
+		fallthrough
+
+	case EndActionWait:
+		if pub.Connected() {
+			ch.Log.Info("end.wait")
+			pub.Close()
+		}
+		return true
+
+	default:
+		ch.Log.Info("end.exit")
+		pub.Close()
+		if err := saveStreamState(ch, *state); err != nil {
+			ch.Log.Error("state.save_failed", "error", err)
+		}
+		return false
+	}
+}
+
+// channelFilePath возвращает путь к файлу канала: имя канала добавляется перед расширением
+func channelFilePath(path, name string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "_" + name + ext
+}
+
+// upNext возвращает следующие файлы для панели: сначала очередь оператора, затем обычный порядок.
+// Если сейчас играет файл из очереди, следующим в обычном порядке остается текущий файл плейлиста.
+func upNext(queue []string, fromQueue bool, current string, playlist *Playlist) []string {
+	upcoming := queue
+	if fromQueue && current != "" {
+		upcoming = append(upcoming, current)
+	}
+	upcoming = append(upcoming, playlist.Upcoming(monitorQueueLength)...)
+	if len(upcoming) > monitorQueueLength {
+		upcoming = upcoming[:monitorQueueLength]
+	}
+	return upcoming
+}
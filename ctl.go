@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultControlSocket = "streamer.sock"  // Сокет управления по умолчанию
+	controlSocketMode    = 0660             // Доступ к сокету: владелец и группа процесса
+	controlTimeout       = 10 * time.Second // Таймаут одного запроса к сокету
+)
+
+// Ошибки управления через сокет
+var (
+	errControlSocketInUse   = newCodedError("err.ctl_socket_in_use")
+	errControlSocketNotSock = newCodedError("err.ctl_not_socket")
+	errControlChannel       = newCodedError("err.ctl_channel_required")
+)
+
+// controlRequest - запрос к работающему процессу через сокет управления
+type controlRequest struct {
+	OperatorCommand        // status или команда оператора
+	Channel         string `json:"channel,omitempty"` // Имя канала, можно не указывать при одном канале
+}
+
+// controlResponse - ответ процесса: состояние затронутых каналов или ошибка
+type controlResponse struct {
+	Error    string            `json:"error,omitempty"`
+	Channels []MonitorSnapshot `json:"channels,omitempty"`
+}
+
+// ControlServer принимает команды rtmp-streamer ctl через локальный Unix сокет.
+// Доступ ограничивается правами на файл сокета, сетевой порт не открывается.
+type ControlServer struct {
+	channels []*Channel
+}
+
+// NewControlServer создает сервер управления для каналов процесса
+func NewControlServer(channels []*Channel) *ControlServer {
+	return &ControlServer{channels: channels}
+}
+
+// ListenAndServe открывает сокет и обслуживает запросы. Сокет, оставшийся от
+// завершенного процесса, заменяется; сокет работающего процесса не трогается.
+func (s *ControlServer) ListenAndServe(path string) error {
+	listener, err := listenControlSocket(path)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.serve(conn)
+	}
+}
+
+// listenControlSocket создает сокет управления с ограниченными правами
+func listenControlSocket(path string) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%w: %s", errControlSocketNotSock, path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %s", errControlSocketInUse, path)
+		}
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, controlSocketMode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// serve выполняет один запрос: строка JSON в ответ на строку JSON
+func (s *ControlServer) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	var request controlRequest
+	var response controlResponse
+	if err := json.NewDecoder(conn).Decode(&request); err != nil {
+		response.Error = err.Error()
+	} else if channels, err := s.execute(request); err != nil {
+		response.Error = err.Error()
+	} else {
+		response.Channels = channels
+	}
+	json.NewEncoder(conn).Encode(response)
+}
+
+// execute выполняет запрос и возвращает состояние затронутых каналов
+func (s *ControlServer) execute(request controlRequest) ([]MonitorSnapshot, error) {
+	if request.Name == "status" {
+		var channels []MonitorSnapshot
+		for _, ch := range s.channels {
+			if request.Channel == "" || ch.Name == request.Channel {
+				channels = append(channels, ch.Status())
+			}
+		}
+		if len(channels) == 0 {
+			return nil, fmt.Errorf("%s: %s", T("err.channel_unknown"), request.Channel)
+		}
+		return channels, nil
+	}
+
+	ch, err := s.channel(request.Channel)
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Command(request.OperatorCommand); err != nil {
+		return nil, err
+	}
+	ch.Log.Info("control.command", "command", request.Name, "file", request.File, "remote", "ctl")
+	return []MonitorSnapshot{ch.Status()}, nil
+}
+
+// channel ищет канал по имени; без имени подходит единственный канал процесса
+func (s *ControlServer) channel(name string) (*Channel, error) {
+	if name == "" {
+		if len(s.channels) != 1 {
+			return nil, errControlChannel
+		}
+		return s.channels[0], nil
+	}
+	for _, ch := range s.channels {
+		if ch.Name == name {
+			return ch, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %s", T("err.channel_unknown"), name)
+}
+
+// runCtl выполняет подкоманду ctl и возвращает код завершения процесса.
+//
+//	rtmp-streamer ctl [--socket путь] [--channel имя] [--json] <команда> [аргументы]
+//
+// Команды: status, skip, goto <файл>, enqueue <файл>, play-next <файл>, override <файл>,
+// remove <позиция>, move <позиция> <новая позиция>, pause, resume, reload.
+func runCtl(args []string) int {
+	// Сокет и язык берутся из конфигурации, если она есть; файл конфигурации клиент не создает
+	config := &Config{}
+	config.Control.Socket = defaultControlSocket
+	if _, err := os.Stat(configFilePath); err == nil {
+		if loaded, err := loadConfig(configFilePath); err == nil {
+			config = loaded
+		}
+	}
+	setupLocale(config)
+
+	fs := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	socket := fs.String("socket", config.Control.Socket, T("flag.ctl_socket"))
+	channel := fs.String("channel", "", T("flag.ctl_channel"))
+	asJSON := fs.Bool("json", false, T("flag.ctl_json"))
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), T("ctl.usage"))
+		fs.PrintDefaults()
+	}
+
+	// Флаги допускаются и до, и после команды
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	var request controlRequest
+	request.Name = fs.Arg(0)
+	rest := fs.Args()
+	if len(rest) > 0 {
+		rest = rest[1:]
+	}
+	arguments := map[string]int{"status": 0, "skip": 0, "pause": 0, "resume": 0, "reload": 0,
+		"goto": 1, "enqueue": 1, "play-next": 1, "override": 1, "remove": 1, "move": 2}
+	count, ok := arguments[request.Name]
+	if !ok || len(rest) < count {
+		fs.Usage()
+		return 2
+	}
+	switch request.Name {
+	case "goto":
+		request.Name, request.File = "jump", rest[0]
+	case "enqueue", "play-next", "override":
+		request.File = rest[0]
+	case "remove", "move":
+		request.Position, _ = strconv.Atoi(rest[0])
+		if count == 2 {
+			request.To, _ = strconv.Atoi(rest[1])
+		}
+	}
+	rest = rest[count:]
+	if err := fs.Parse(rest); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	request.Channel = *channel
+
+	response, raw, err := sendControlRequest(*socket, request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", T("ctl.failed", "socket", *socket), err)
+		return 1
+	}
+	if *asJSON {
+		os.Stdout.Write(raw)
+	} else {
+		printControlResponse(os.Stdout, request, response)
+	}
+	if response.Error != "" {
+		return 1
+	}
+	return 0
+}
+
+// sendControlRequest отправляет запрос процессу и возвращает разобранный и исходный ответ
+func sendControlRequest(socket string, request controlRequest) (controlResponse, []byte, error) {
+	var response controlResponse
+	conn, err := net.DialTimeout("unix", socket, controlTimeout)
+	if err != nil {
+		return response, nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	if err := json.NewEncoder(conn).Encode(request); err != nil {
+		return response, nil, err
+	}
+	raw, err := io.ReadAll(conn)
+	if err != nil {
+		return response, nil, err
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return response, nil, err
+	}
+	return response, raw, nil
+}
+
+// printControlResponse выводит ответ в читаемом виде
+func printControlResponse(w io.Writer, request controlRequest, response controlResponse) {
+	if response.Error != "" {
+		fmt.Fprintln(w, T("ctl.error", "error", response.Error))
+		return
+	}
+	if request.Name != "status" {
+		for _, status := range response.Channels {
+			fmt.Fprintln(w, T("ctl.done", "command", request.Name, "channel", status.Channel))
+			printQueue(w, status)
+		}
+		return
+	}
+
+	for i, status := range response.Channels {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		state, _ := channelStateLabel(status)
+		fmt.Fprintf(w, "%s  [%s]\n", T("tui.channel", "name", status.Channel), state)
+		if status.NowPlaying != "" {
+			position := tuiTime(status.Position)
+			if status.Duration > 0 {
+				position += " / " + tuiTime(status.Duration)
+			}
+			fmt.Fprintf(w, "  %s  %s\n", T("tui.now_playing", "file", status.NowPlaying,
+				"index", status.Index+1, "total", status.Total), position)
+		} else {
+			fmt.Fprintf(w, "  %s\n", T("tui.nothing_playing"))
+		}
+		fmt.Fprintf(w, "  %s\n", T("tui.bitrate", "bitrate_kbps", status.Bitrate/1000))
+		fmt.Fprintf(w, "  %s\n", T("tui.destination", "url", status.Destination.URL, "breaker", status.Destination.Breaker.State))
+		printQueue(w, status)
+		if len(status.Queue) > 0 {
+			fmt.Fprintf(w, "  %s: %s\n", T("tui.up_next"), strings.Join(status.Queue, ", "))
+		}
+	}
+}
+
+// printQueue выводит очередь оператора с позициями для remove и move
+func printQueue(w io.Writer, status MonitorSnapshot) {
+	if len(status.Enqueued) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  %s:\n", T("ctl.enqueued"))
+	for i, name := range status.Enqueued {
+		fmt.Fprintf(w, "    %d. %s\n", i+1, name)
+	}
+}
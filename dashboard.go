@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultDashboardListen = "127.0.0.1:8080" // Адрес панели оператора по умолчанию
+
+// Ошибки проверки команд панели
+var (
+	errDashboardOrigin = newCodedError("err.dashboard_origin") // Запрос пришел со страницы другого сайта
+	errDashboardToken  = newCodedError("err.dashboard_token")  // Нет ключа панели или он неверный
+)
+
+// dashboardPage - страница панели оператора. Стили и скрипты встроены в нее,
+// поэтому панель работает без доступа к интернету.
+//
+//go:embed dashboard/index.html
+var dashboardPage []byte
+
+// Dashboard - веб-панель оператора: состояние каналов и управление ими.
+//
+//	GET  /                                 страница панели
+//	GET  /api/channels                     состояние всех каналов
+//	POST /api/channels/<имя>/<команда>     skip, jump?file=<имя файла>, pause, resume, reconnect, reload,
+//	                                       enqueue?file=, play-next?file=, remove?position=, move?position=&to=,
+//	                                       override?file=
+//	GET  /api/events                       поток событий (Server-Sent Events)
+//	GET  /api/events/ws                    поток событий (WebSocket)
+//	GET  /epg.xml                          программа передач XMLTV, если она включена
+//
+// Команды принимаются только со страниц самой панели. Если задан ключ, команда должна
+// передать его в заголовке Authorization: Bearer <ключ>. Без ключа команды принимаются,
+// только когда панель слушает loopback адрес и запрос адресован loopback имени.
+type Dashboard struct {
+	channels []*Channel
+	events   *EventBus
+	mux      *http.ServeMux
+	token    string // Ключ для команд, пусто = команды только с этой машины
+	local    bool   // Панель слушает только loopback адрес
+}
+
+// dashboardResponse - ответ на запрос состояния каналов
+type dashboardResponse struct {
+	Locale   string            `json:"locale"`
+	Channels []MonitorSnapshot `json:"channels"`
+}
+
+// NewDashboard создает панель для каналов процесса и их шины событий на адресе listen.
+// Программа передач epg может отсутствовать.
+func NewDashboard(channels []*Channel, events *EventBus, epg *EPGGenerator, listen, token string) *Dashboard {
+	d := &Dashboard{channels: channels, events: events, mux: http.NewServeMux(),
+		token: token, local: isLoopbackListen(listen)}
+	d.mux.HandleFunc("/", d.handlePage)
+	d.mux.HandleFunc("/api/channels", d.handleChannels)
+	d.mux.HandleFunc("/api/channels/", d.handleCommand)
+	d.mux.HandleFunc("/api/events", d.handleEventsSSE)
+	d.mux.HandleFunc("/api/events/ws", d.handleEventsWS)
+	if epg != nil {
+		d.mux.Handle("/epg.xml", epg)
+	}
+	return d
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mux.ServeHTTP(w, r)
+}
+
+// handlePage отдает страницу панели
+func (d *Dashboard) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardPage)
+}
+
+// handleChannels отдает состояние всех каналов
+func (d *Dashboard) handleChannels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := dashboardResponse{Locale: locale, Channels: make([]MonitorSnapshot, 0, len(d.channels))}
+	for _, ch := range d.channels {
+		response.Channels = append(response.Channels, ch.Status())
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleCommand выполняет команду оператора для канала
+func (d *Dashboard) handleCommand(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err := d.authorize(r); err != nil {
+		status := http.StatusForbidden
+		if errors.Is(err, errDashboardToken) {
+			status = http.StatusUnauthorized
+		}
+		logWarn("dashboard.rejected", "path", r.URL.Path, "remote", r.RemoteAddr, "error", err)
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/channels/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	ch := d.channel(parts[0])
+	if ch == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": T("err.channel_unknown") + ": " + parts[0]})
+		return
+	}
+
+	command := OperatorCommand{Name: parts[1], File: r.FormValue("file")}
+	command.Position, _ = strconv.Atoi(r.FormValue("position"))
+	command.To, _ = strconv.Atoi(r.FormValue("to"))
+	if err := ch.Command(command); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ch.Log.Info("control.command", "command", command.Name, "file", command.File, "remote", r.RemoteAddr)
+	writeJSON(w, http.StatusOK, ch.Status())
+}
+
+// authorize проверяет, что команда пришла со страницы панели и от оператора
+func (d *Dashboard) authorize(r *http.Request) error {
+	// Браузер сообщает, с какой страницы отправлен запрос: команды с чужих страниц отклоняются
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+		return errDashboardOrigin
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			return errDashboardOrigin
+		}
+	}
+
+	if d.token != "" {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+d.token)) != 1 {
+			return errDashboardToken
+		}
+		return nil
+	}
+	// Без ключа нужен loopback и в адресе сервера, и в заголовке Host: иначе страница
+	// с подмененным DNS именем могла бы обратиться к панели как к своему сайту
+	if !d.local || !isLoopbackHost(r.Host) {
+		return errDashboardToken
+	}
+	return nil
+}
+
+// isLoopbackListen сообщает, что адрес сервера доступен только с этой машины
+func isLoopbackListen(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	return err == nil && host != "" && isLoopbackHost(host)
+}
+
+// isLoopbackHost проверяет имя или адрес (с портом или без) на loopback
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// channel ищет канал по имени
+func (d *Dashboard) channel(name string) *Channel {
+	for _, ch := range d.channels {
+		if ch.Name == name {
+			return ch
+		}
+	}
+	return nil
+}
+
+// writeJSON отправляет ответ в формате JSON
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
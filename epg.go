@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Значения по умолчанию для программы передач
+const (
+	defaultEPGHours   = 24              // На сколько часов вперед строится программа
+	defaultEPGRefresh = 10              // Обновление по таймеру в минутах, чтобы программа сдвигалась вперед
+	epgRefreshDelay   = 2 * time.Second // Задержка обновления после события: несколько событий подряд дают одно обновление
+	epgHistory        = 6 * time.Hour   // Сколько часов проигранного эфира остается в программе
+	epgTimeLayout     = "20060102150405 -0700"
+	epgGeneratorName  = "rtmp-streamer"
+)
+
+// EPGProgramme - передача в программе: файл канала и время его эфира
+type EPGProgramme struct {
+	File  string
+	Title string   // Название из метаданных файла, пусто = по имени файла
+	Tags  []string // Метки из метаданных файла, выводятся категориями
+	Start time.Time
+	Stop  time.Time // Нулевое время - передача еще в эфире
+}
+
+// Элементы XMLTV
+type (
+	xmltvGuide struct {
+		XMLName       xml.Name         `xml:"tv"`
+		GeneratorName string           `xml:"generator-info-name,attr"`
+		Channels      []xmltvChannel   `xml:"channel"`
+		Programmes    []xmltvProgramme `xml:"programme"`
+	}
+	xmltvChannel struct {
+		ID          string    `xml:"id,attr"`
+		DisplayName xmltvText `xml:"display-name"`
+	}
+	xmltvProgramme struct {
+		Start      string      `xml:"start,attr"`
+		Stop       string      `xml:"stop,attr"`
+		Channel    string      `xml:"channel,attr"`
+		Title      xmltvText   `xml:"title"`
+		Categories []xmltvText `xml:"category"`
+	}
+	xmltvText struct {
+		Lang  string `xml:"lang,attr,omitempty"`
+		Value string `xml:",chardata"`
+	}
+)
+
+// EPGGenerator строит программу передач XMLTV для всех каналов процесса.
+// Будущий эфир берется из симуляции от текущего состояния канала: файла, позиции,
+// порядка и очереди оператора. Проигранный эфир берется из событий file.started
+// и file.finished, поэтому прошедшие передачи стоят в программе по фактическому времени.
+// Программа обновляется при смене файла, изменении каталога, очереди и окна вещания.
+type EPGGenerator struct {
+	File    string        // Файл программы, пусто = только HTTP
+	Hours   time.Duration // Горизонт программы
+	Refresh time.Duration // Обновление по таймеру
+
+	mu       sync.Mutex
+	channels []*Channel
+	aired    map[string][]EPGProgramme // Проигранные передачи по каналам
+	queue    map[string]string         // Очередь оператора при последнем обновлении
+	guide    []byte                    // Последняя построенная программа
+}
+
+// NewEPGGenerator создает построение программы передач из конфигурации процесса
+func NewEPGGenerator(config *Config) *EPGGenerator {
+	g := &EPGGenerator{
+		File:    config.EPG.File,
+		Hours:   time.Duration(config.EPG.Hours) * time.Hour,
+		Refresh: time.Duration(config.EPG.RefreshMinutes) * time.Minute,
+		aired:   make(map[string][]EPGProgramme),
+		queue:   make(map[string]string),
+	}
+	if g.Hours <= 0 {
+		g.Hours = defaultEPGHours * time.Hour
+	}
+	if g.Refresh <= 0 {
+		g.Refresh = defaultEPGRefresh * time.Minute
+	}
+	return g
+}
+
+// Add добавляет канал в программу
+func (g *EPGGenerator) Add(ch *Channel) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.channels = append(g.channels, ch)
+}
+
+// Start подписывается на события каналов и обновляет программу в отдельной горутине.
+// Подписка оформляется сразу, чтобы первые файлы каналов попали в проигранный эфир.
+func (g *EPGGenerator) Start(bus *EventBus) {
+	go g.run(bus, bus.Subscribe(0))
+}
+
+// run обновляет программу по событиям и таймеру. Первая программа строится
+// с задержкой, когда каналы уже запущены.
+func (g *EPGGenerator) run(bus *EventBus, sub *EventSubscription) {
+	ticker := time.NewTicker(g.Refresh)
+	defer ticker.Stop()
+	pending := time.NewTimer(epgRefreshDelay)
+
+	var lastID uint64
+	for {
+		if sub == nil {
+			sub = bus.Subscribe(lastID)
+		}
+	events:
+		for {
+			select {
+			case event, ok := <-sub.C:
+				if !ok {
+					// Подписка отключена из-за переполнения: пропущенное берется из буфера шины
+					sub = nil
+					break events
+				}
+				lastID = event.ID
+				if g.record(event) {
+					pending.Reset(epgRefreshDelay)
+				}
+			case <-pending.C:
+				g.generate()
+			case <-ticker.C:
+				g.generate()
+			}
+		}
+	}
+}
+
+// record учитывает событие канала и сообщает, нужно ли обновить программу
+func (g *EPGGenerator) record(event Event) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	aired := g.aired[event.Channel]
+	switch data := event.Data.(type) {
+	case FileStartedEvent:
+		if n := len(aired); n > 0 && aired[n-1].Stop.IsZero() {
+			aired[n-1].Stop = event.Time
+		}
+		g.aired[event.Channel] = append(aired, EPGProgramme{File: data.File, Title: data.Title, Tags: data.Tags, Start: event.Time})
+		return true
+	case FileFinishedEvent:
+		if n := len(aired); n > 0 && aired[n-1].Stop.IsZero() {
+			aired[n-1].Stop = event.Time
+		}
+		return false
+	case StateSavedEvent:
+		// Очередь оператора сохраняется сразу после изменения, поэтому ее правка видна по этому событию
+		for _, ch := range g.channels {
+			if ch.Name == event.Channel {
+				queue := strings.Join(ch.Queue.Items(), "\n")
+				changed := queue != g.queue[ch.Name]
+				g.queue[ch.Name] = queue
+				return changed
+			}
+		}
+		return false
+	}
+	switch event.Type {
+	case EventDirectoryChanged, EventOnAirOpened, EventOnAirClosed, EventOverrideFinished:
+		return true
+	}
+	return false
+}
+
+// generate строит программу всех каналов, сохраняет ее для HTTP и записывает в файл
+func (g *EPGGenerator) generate() {
+	g.mu.Lock()
+	channels := append([]*Channel(nil), g.channels...)
+	g.mu.Unlock()
+
+	now := time.Now()
+	guide := xmltvGuide{GeneratorName: epgGeneratorName}
+	for _, ch := range channels {
+		id, name := ch.Config().EPG.ChannelID, ch.Config().EPG.DisplayName
+		if id == "" {
+			id = ch.Name
+		}
+		if name == "" {
+			name = ch.Name
+		}
+		lang := ch.Config().EPG.Language
+		guide.Channels = append(guide.Channels, xmltvChannel{ID: id, DisplayName: xmltvText{Lang: lang, Value: name}})
+
+		programmes, err := g.programmes(ch, now)
+		if err != nil {
+			ch.Log.Warn("epg.failed", "error", err)
+		}
+		for _, p := range programmes {
+			programme := xmltvProgramme{
+				Start:   p.Start.Format(epgTimeLayout),
+				Stop:    p.Stop.Format(epgTimeLayout),
+				Channel: id,
+				Title:   xmltvText{Lang: lang, Value: programmeTitle(p)},
+			}
+			for _, tag := range p.Tags {
+				programme.Categories = append(programme.Categories, xmltvText{Lang: lang, Value: tag})
+			}
+			guide.Programmes = append(guide.Programmes, programme)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(guide); err != nil {
+		logError("epg.failed", "error", err)
+		return
+	}
+	buf.WriteString("\n")
+
+	g.mu.Lock()
+	g.guide = buf.Bytes()
+	g.mu.Unlock()
+	logDebug("epg.generated", "programmes", len(guide.Programmes))
+
+	if g.File != "" {
+		// Запись через временный файл, чтобы потребитель не прочитал программу наполовину
+		tmp := g.File + ".tmp"
+		if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+			logError("epg.write_failed", "path", g.File, "error", err)
+			return
+		}
+		if err := os.Rename(tmp, g.File); err != nil {
+			logError("epg.write_failed", "path", g.File, "error", err)
+		}
+	}
+}
+
+// programmes возвращает передачи канала: проигранные по факту и будущие по симуляции.
+// Части одного файла, разделенные рекламными паузами, считаются одной передачей.
+func (g *EPGGenerator) programmes(ch *Channel, now time.Time) ([]EPGProgramme, error) {
+	g.mu.Lock()
+	var programmes []EPGProgramme
+	kept := g.aired[ch.Name][:0]
+	for _, p := range g.aired[ch.Name] {
+		if p.Stop.IsZero() || now.Sub(p.Stop) < epgHistory {
+			kept = append(kept, p)
+			programmes = append(programmes, p)
+		}
+	}
+	g.aired[ch.Name] = kept
+	g.mu.Unlock()
+
+	simulation, err := simulateChannel(ch.Config(), now, now.Add(g.Hours), epgState(ch), true, ch.Media)
+	if err != nil {
+		return closeProgrammes(programmes, now), err
+	}
+
+	interrupted := false
+	for _, item := range simulation.Items {
+		switch item.Kind {
+		case SimAd:
+			continue
+		case SimFile:
+		default:
+			interrupted = true
+			continue
+		}
+
+		stop := item.Start.Add(time.Duration(item.Duration * float64(time.Second)))
+		if n := len(programmes); n > 0 && programmes[n-1].File == item.File && !interrupted &&
+			(programmes[n-1].Stop.IsZero() || containsString(item.Flags, SimFlagResumed)) {
+			programmes[n-1].Stop = stop
+		} else {
+			programmes = append(closeProgrammes(programmes, item.Start), EPGProgramme{File: item.File, Title: item.Title, Tags: item.Tags, Start: item.Start, Stop: stop})
+		}
+		interrupted = false
+	}
+	return closeProgrammes(programmes, now), nil
+}
+
+// closeProgrammes завершает передачу, оставшуюся без времени окончания, моментом at
+func closeProgrammes(programmes []EPGProgramme, at time.Time) []EPGProgramme {
+	if n := len(programmes); n > 0 && programmes[n-1].Stop.IsZero() {
+		programmes[n-1].Stop = at
+	}
+	return programmes
+}
+
+// epgState возвращает состояние канала для симуляции: порядок из файла состояния,
+// текущий файл, позиция и очередь оператора из работающего канала
+func epgState(ch *Channel) *StreamState {
+	state := &StreamState{}
+	if data, err := os.ReadFile(ch.StateFile()); err == nil {
+		json.Unmarshal(data, state)
+	}
+	status := ch.Monitor.Snapshot()
+	if status.NowPlaying != "" {
+		state.CurrentFile = status.NowPlaying
+		state.Position = time.Duration(status.Position * float64(time.Second))
+	}
+	state.Queue, state.QueueCurrent = ch.Queue.State()
+	return state
+}
+
+// programmeTitle возвращает название передачи: из метаданных файла или имя файла без расширения
+func programmeTitle(p EPGProgramme) string {
+	if p.Title != "" {
+		return p.Title
+	}
+	return strings.TrimSuffix(p.File, filepath.Ext(p.File))
+}
+
+// ServeHTTP отдает последнюю построенную программу
+func (g *EPGGenerator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	g.mu.Lock()
+	guide := g.guide
+	g.mu.Unlock()
+	if guide == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write(guide)
+}
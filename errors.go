@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Классы ошибок трансляции. Конкретные ошибки оборачиваются через %w,
+// а политика повторов в main выбирается по errors.Is.
+// Текст ошибок берется из каталога сообщений на текущем языке.
+var (
+	ErrInvalidURL  = newCodedError("err.invalid_url")  // Ошибка конфигурации, продолжать бессмысленно
+	ErrDial        = newCodedError("err.dial")         // Сервер недоступен
+	ErrHandshake   = newCodedError("err.handshake")    // Рукопожатие или публикация отклонены
+	ErrWrite       = newCodedError("err.write")        // Соединение оборвалось во время передачи
+	ErrDemux       = newCodedError("err.demux")        // Файл временно не читается
+	ErrFileCorrupt = newCodedError("err.file_corrupt") // Файл не может быть воспроизведен
+	ErrNoStreams   = newCodedError("err.no_streams")   // Частный случай поврежденного файла
+
+	ErrNoVideoFiles = newCodedError("err.no_video_files") // В каталоге канала нет файлов, канал будет перезапущен
+	ErrChannelPanic = newCodedError("err.channel_panic")  // Паника в горутине канала
+)
+
+// RetryAction - реакция на ошибку трансляции файла
+type RetryAction int
+
+const (
+	ActionRetryConnection RetryAction = iota // Переподключиться и продолжить тот же файл с той же позиции
+	ActionRetryFile                          // Повторить файл, после исчерпания попыток пропустить
+	ActionQuarantine                         // Файл поврежден: убрать в карантин и перейти к следующему
+	ActionFatal                              // Продолжать бессмысленно
+)
+
+// classifyError определяет реакцию на ошибку по ее классу
+func classifyError(err error) RetryAction {
+	switch {
+	case errors.Is(err, ErrInvalidURL):
+		return ActionFatal
+	case errors.Is(err, ErrDial), errors.Is(err, ErrHandshake), errors.Is(err, ErrWrite):
+		return ActionRetryConnection
+	case errors.Is(err, ErrFileCorrupt), errors.Is(err, ErrNoStreams):
+		return ActionQuarantine
+	default:
+		return ActionRetryFile
+	}
+}
+
+// isMissingMoov проверяет ошибку joy4 об отсутствии атома moov.
+// joy4 не экспортирует эту ошибку, поэтому это единственное место сравнения по тексту.
+func isMissingMoov(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "'moov'")
+}
+
+// demuxError относит ошибку открытия или чтения файла к классу поврежденных или временных ошибок
+func demuxError(err error) error {
+	if isMissingMoov(err) {
+		return fmt.Errorf("%w: %w", ErrFileCorrupt, err)
+	}
+	return fmt.Errorf("%w: %w", ErrDemux, err)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	eventReplaySize       = 1000 // Сколько последних событий хранится для повторной отправки
+	eventSubscriberBuffer = 256  // Очередь событий подписчика до его отключения
+)
+
+// Типы событий жизненного цикла канала
+const (
+	EventFileStarted      = "file.started"      // Начато воспроизведение файла
+	EventFileFinished     = "file.finished"     // Файл завершен, данные содержат StreamStatus
+	EventEarlyEnd         = "file.early_end"    // Файл завершен раньше конца для подготовки следующего
+	EventRetry            = "file.retry"        // Повторная попытка воспроизведения файла
+	EventReconnect        = "reconnect"         // Соединение потеряно, запланировано переподключение
+	EventReconnected      = "reconnected"       // Соединение восстановлено
+	EventStateSaved       = "state.saved"       // Состояние сохранено в файл
+	EventDirectoryChanged = "directory.changed" // Изменилось содержимое каталога видео
+	EventBitrateWarning   = "bitrate.warning"   // Битрейт ниже минимального
+	EventOverrideStarted  = "override.started"  // Экстренная вставка прервала эфир
+	EventOverrideFinished = "override.finished" // Экстренная вставка закончилась, эфир продолжается
+	EventOnAirOpened      = "onair.opened"      // Открылось окно вещания
+	EventOnAirClosed      = "onair.closed"      // Окно вещания закрылось, канал вне эфира
+	EventClipPlayed       = "clip.played"       // Отправлен служебный ролик, рекламный ролик или заставка
+)
+
+// Виды роликов в событии clip.played
+const (
+	ClipInterstitial = "interstitial"
+	ClipAd           = "ad"
+	ClipSlate        = "slate"
+)
+
+// Event - событие жизненного цикла канала. ID растет монотонно в пределах процесса,
+// по нему клиент после переподключения получает пропущенные события.
+type Event struct {
+	ID      uint64    `json:"id"`
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Channel string    `json:"channel"`
+	Data    any       `json:"data,omitempty"`
+}
+
+// Данные событий. Время и позиции в секундах.
+
+// FileStartedEvent - данные события file.started
+type FileStartedEvent struct {
+	File     string   `json:"file"`
+	Index    int      `json:"index"`
+	Total    int      `json:"total"`
+	Position float64  `json:"position"`        // Позиция продолжения, 0 для начала файла
+	Duration float64  `json:"duration"`        // Длительность файла или до точки окончания, 0 если неизвестна
+	Title    string   `json:"title,omitempty"` // Название из метаданных файла
+	Tags     []string `json:"tags,omitempty"`  // Метки из метаданных файла
+}
+
+// FileFinishedEvent - данные события file.finished. Error заполняется, если все попытки не удались.
+type FileFinishedEvent struct {
+	File     string       `json:"file"`
+	Status   StreamStatus `json:"status"`
+	Position float64      `json:"position"` // Позиция, на которой файл остановлен
+	Error    string       `json:"error,omitempty"`
+}
+
+// EarlyEndEvent - данные события file.early_end
+type EarlyEndEvent struct {
+	File     string  `json:"file,omitempty"`
+	Position float64 `json:"position"`
+	Elapsed  float64 `json:"elapsed"`
+}
+
+// RetryEvent - данные события file.retry
+type RetryEvent struct {
+	File        string `json:"file"`
+	Attempt     int    `json:"attempt"`
+	MaxAttempts int    `json:"maxAttempts"`
+}
+
+// ReconnectEvent - данные событий reconnect и reconnected
+type ReconnectEvent struct {
+	Destination string  `json:"destination"` // Адрес сервера без ключа потока
+	File        string  `json:"file,omitempty"`
+	Position    float64 `json:"position"`
+	Error       string  `json:"error,omitempty"`
+	RetryIn     float64 `json:"retryIn,omitempty"`
+	Breaker     string  `json:"breaker"`
+}
+
+// StateSavedEvent - данные события state.saved
+type StateSavedEvent struct {
+	File     string  `json:"file"`
+	Position float64 `json:"position"`
+	Path     string  `json:"path"`
+}
+
+// DirectoryChangedEvent - данные события directory.changed
+type DirectoryChangedEvent struct {
+	Directory string `json:"directory"`
+	Files     int    `json:"files"`
+	Queued    int    `json:"queued"`
+}
+
+// BitrateWarningEvent - данные события bitrate.warning
+type BitrateWarningEvent struct {
+	File       string `json:"file,omitempty"`
+	Bitrate    int64  `json:"bitrate"`
+	MinBitrate int64  `json:"minBitrate"`
+}
+
+// OverrideEvent - данные событий override.started и override.finished
+type OverrideEvent struct {
+	File        string  `json:"file"`
+	Path        string  `json:"path"`
+	Interrupted string  `json:"interrupted,omitempty"` // Прерванный файл, продолжается после вставки
+	Position    float64 `json:"position"`              // Позиция прерванного файла
+	Duration    float64 `json:"duration,omitempty"`    // Сколько длилась вставка
+	Error       string  `json:"error,omitempty"`
+}
+
+// OnAirEvent - данные событий onair.opened и onair.closed
+type OnAirEvent struct {
+	OnAir bool       `json:"onAir"`
+	Next  *time.Time `json:"next,omitempty"` // Следующее изменение окна, если оно в ближайшую неделю
+}
+
+// ClipPlayedEvent - данные события clip.played
+type ClipPlayedEvent struct {
+	Kind     string    `json:"kind"` // interstitial, ad или slate
+	File     string    `json:"file"`
+	Path     string    `json:"path"`
+	Started  time.Time `json:"started"`
+	Duration float64   `json:"duration"` // Сколько отправлено
+	Error    string    `json:"error,omitempty"`
+}
+
+// MarshalJSON записывает статус для потока событий; длительности в секундах
+func (s StreamStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		EndOfFile     bool    `json:"endOfFile"`
+		PrepareNext   bool    `json:"prepareNext"`
+		Interrupted   bool    `json:"interrupted"`
+		TotalPackets  int     `json:"totalPackets"`
+		VideoDuration float64 `json:"videoDuration"`
+		ElapsedTime   float64 `json:"elapsedTime"`
+		Bitrate       int64   `json:"bitrate"`
+	}{s.EndOfFile, s.PrepareNext, s.Interrupted, s.TotalPackets, s.VideoDuration.Seconds(), s.ElapsedTime.Seconds(), s.Bitrate})
+}
+
+// EventBus раздает события всех каналов подписчикам и хранит последние события для повторной отправки.
+// Публикация никогда не блокирует канал: подписчик, не успевающий читать, отключается
+// и после переподключения догоняет пропущенное по ID последнего события.
+type EventBus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	replay      []Event
+	replaySize  int
+	subscribers map[*EventSubscription]struct{}
+}
+
+// EventSubscription - подписка на события. Канал C закрывается при отписке или переполнении.
+type EventSubscription struct {
+	C chan Event
+
+	bus    *EventBus
+	closed bool
+}
+
+// NewEventBus создает шину событий с буфером повторной отправки заданного размера
+func NewEventBus(replaySize int) *EventBus {
+	return &EventBus{
+		nextID:      1,
+		replaySize:  replaySize,
+		subscribers: make(map[*EventSubscription]struct{}),
+	}
+}
+
+// Publish отправляет событие подписчикам. Нулевая шина события игнорирует.
+func (b *EventBus) Publish(channel, eventType string, data any) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{ID: b.nextID, Type: eventType, Time: time.Now(), Channel: channel, Data: data}
+	b.nextID++
+	b.replay = append(b.replay, event)
+	if len(b.replay) > b.replaySize {
+		b.replay = b.replay[len(b.replay)-b.replaySize:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.C <- event:
+		default:
+			b.unsubscribeLocked(sub)
+		}
+	}
+}
+
+// Subscribe подписывается на события. Если since > 0, сначала в подписку попадают
+// события из буфера с ID больше since.
+func (b *EventBus) Subscribe(since uint64) *EventSubscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if since > 0 {
+		for _, event := range b.replay {
+			if event.ID > since {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	sub := &EventSubscription{C: make(chan Event, len(missed)+eventSubscriberBuffer), bus: b}
+	for _, event := range missed {
+		sub.C <- event
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Close отписывается от событий
+func (s *EventSubscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.unsubscribeLocked(s)
+}
+
+func (b *EventBus) unsubscribeLocked(sub *EventSubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.C)
+}
+
+// emit публикует событие канала
+func (ch *Channel) emit(eventType string, data any) {
+	ch.Events.Publish(ch.Name, eventType, data)
+}
+
+// emitClip публикует событие clip.played об отправленном ролике
+func (ch *Channel) emitClip(kind, path string, started time.Time, played time.Duration, err error) {
+	event := ClipPlayedEvent{Kind: kind, File: filepath.Base(path), Path: path, Started: started, Duration: played.Seconds()}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	ch.emit(EventClipPlayed, event)
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	eventKeepAlive = 15 * time.Second // Интервал пустых сообщений, чтобы прокси не закрывали соединение
+	wsMaxFrameSize = 64 * 1024        // Максимальный размер входящего кадра WebSocket
+	wsAcceptGUID   = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// Коды операций WebSocket (RFC 6455)
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// eventFilter отбирает события по каналу и типам из параметров запроса
+// ?channel=<имя>&types=<тип>,<тип>
+type eventFilter struct {
+	channel string
+	types   map[string]bool
+}
+
+func newEventFilter(r *http.Request) eventFilter {
+	f := eventFilter{channel: r.URL.Query().Get("channel")}
+	if types := r.URL.Query().Get("types"); types != "" {
+		f.types = make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			f.types[strings.TrimSpace(t)] = true
+		}
+	}
+	return f
+}
+
+func (f eventFilter) match(event Event) bool {
+	if f.channel != "" && event.Channel != f.channel {
+		return false
+	}
+	return f.types == nil || f.types[event.Type]
+}
+
+// eventsSince возвращает ID последнего полученного клиентом события:
+// из заголовка Last-Event-ID (его отправляет EventSource при переподключении) или параметра since
+func eventsSince(r *http.Request) uint64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("since")
+	}
+	since, _ := strconv.ParseUint(value, 10, 64)
+	return since
+}
+
+// handleEventsSSE отдает поток событий в формате Server-Sent Events
+func (d *Dashboard) handleEventsSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	filter := newEventFilter(r)
+	sub := d.events.Subscribe(eventsSince(r))
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				// Клиент не успевал читать; EventSource переподключится и получит пропущенное
+				return
+			}
+			if !filter.match(event) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// handleEventsWS отдает поток событий через WebSocket: каждое событие - текстовый кадр с JSON
+func (d *Dashboard) handleEventsWS(w http.ResponseWriter, r *http.Request) {
+	conn, rw, err := upgradeWebSocket(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer conn.Close()
+
+	filter := newEventFilter(r)
+	sub := d.events.Subscribe(eventsSince(r))
+	defer sub.Close()
+
+	ws := &wsConn{conn: conn, rw: rw}
+
+	// Входящие кадры читаются только для ответа на ping и закрытия соединения
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			opcode, payload, err := readWSFrame(rw.Reader)
+			if err != nil {
+				return
+			}
+			switch opcode {
+			case wsOpPing:
+				if ws.write(wsOpPong, payload) != nil {
+					return
+				}
+			case wsOpClose:
+				ws.write(wsOpClose, payload)
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-keepAlive.C:
+			if ws.write(wsOpPing, nil) != nil {
+				return
+			}
+		case event, ok := <-sub.C:
+			if !ok {
+				// Клиент не успевал читать: закрываем соединение, он переподключится с ?since=<id>
+				ws.write(wsOpClose, nil)
+				return
+			}
+			if !filter.match(event) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if ws.write(wsOpText, data) != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsConn - серверная сторона WebSocket соединения; запись защищена мьютексом,
+// так как кадры отправляют и поток событий, и ответы на ping
+type wsConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := writeWSFrame(c.rw.Writer, opcode, payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// upgradeWebSocket выполняет рукопожатие WebSocket и забирает соединение у HTTP сервера
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	if r.Method != http.MethodGet ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		r.Header.Get("Sec-WebSocket-Key") == "" {
+		return nil, nil, newCodedError("err.websocket_handshake")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, newCodedError("err.websocket_handshake")
+	}
+
+	sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsAcceptGUID))
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+// writeWSFrame записывает кадр WebSocket без маски (кадры сервера не маскируются)
+func writeWSFrame(w io.Writer, opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readWSFrame читает кадр WebSocket клиента и снимает с него маску
+func readWSFrame(r *bufio.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxFrameSize {
+		return 0, nil, newCodedError("err.websocket_frame")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
@@ -2,4 +2,11 @@ module rtmp-streamer
 
 go 1.21
 
-require github.com/nareix/joy4 v0.0.0-20181022032202-3ddbc8f9d431
+require (
+	github.com/fsnotify/fsnotify v1.8.0
+	github.com/nareix/joy4 v0.0.0-20181022032202-3ddbc8f9d431
+	golang.org/x/term v0.20.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.20.0 // indirect
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultInterstitialDirectory = "interstitials" // Каталог служебных роликов по умолчанию
+
+// InterstitialRule добавляет интро перед и аутро после подходящих файлов
+type InterstitialRule struct {
+	Files    []string `json:"files"`    // Имена файлов или шаблоны (news_*.mp4)
+	Category string   `json:"category"` // Категория из interstitials.categories
+	Intro    string   `json:"intro"`    // Ролик перед файлом, пусто = нет
+	Outro    string   `json:"outro"`    // Ролик после файла, пусто = нет
+}
+
+// Interstitials выбирает служебные ролики на границах файлов: позывной канала раз в N минут,
+// промо после каждых K файлов, интро и аутро для отдельных файлов и категорий.
+// Ролики лежат в отдельном каталоге, поэтому не попадают в обычный порядок воспроизведения
+// и в файл состояния: после перезапуска продолжается контент, а не ролик.
+type Interstitials struct {
+	Enabled          bool
+	Directory        string
+	StationID        []string
+	StationIDEvery   time.Duration
+	Promos           []string
+	PromoEveryNItems int
+	Categories       map[string][]string
+	Rules            []InterstitialRule
+
+	OnClip func(path string, started time.Time, played time.Duration, err error) // Вызывается после каждого ролика, например для журнала эфира
+
+	lastStationID   time.Time // Время последнего позывного
+	nextStationID   int       // Индекс следующего ролика позывного
+	nextPromo       int       // Индекс следующего промо
+	itemsSincePromo int       // Сколько файлов проиграно после последнего промо
+
+	now func() time.Time // Часы; симулятор эфира подставляет модельное время
+	log *Logger
+}
+
+// NewInterstitials создает выбор служебных роликов из конфигурации
+func NewInterstitials(config *Config, log *Logger) *Interstitials {
+	cfg := config.Interstitials
+	s := &Interstitials{
+		Enabled:          cfg.Enabled,
+		Directory:        cfg.Directory,
+		StationID:        cfg.StationID.Files,
+		StationIDEvery:   time.Duration(cfg.StationID.EveryMinutes) * time.Minute,
+		Promos:           cfg.Promos.Files,
+		PromoEveryNItems: cfg.Promos.EveryNItems,
+		Categories:       cfg.Categories,
+		Rules:            cfg.Rules,
+		lastStationID:    time.Now(),
+		now:              time.Now,
+		log:              log,
+	}
+	if s.Directory == "" {
+		s.Directory = defaultInterstitialDirectory
+	}
+	if s.Enabled && filepath.Clean(s.Directory) == filepath.Clean(config.Video.Directory) {
+		log.Warn("interstitial.same_dir", "dir", s.Directory)
+	}
+	return s
+}
+
+// Before возвращает ролики перед файлом: позывной, если подошло время, промо,
+// если проиграно нужное число файлов, и интро файла
+func (s *Interstitials) Before(fileName string) []string {
+	if s == nil || !s.Enabled {
+		return nil
+	}
+
+	var clips []string
+	if s.StationIDEvery > 0 && len(s.StationID) > 0 && s.now().Sub(s.lastStationID) >= s.StationIDEvery {
+		clips = append(clips, s.StationID[s.nextStationID%len(s.StationID)])
+		s.nextStationID++
+		s.lastStationID = s.now()
+	}
+	if s.PromoEveryNItems > 0 && len(s.Promos) > 0 && s.itemsSincePromo >= s.PromoEveryNItems {
+		clips = append(clips, s.Promos[s.nextPromo%len(s.Promos)])
+		s.nextPromo++
+		s.itemsSincePromo = 0
+	}
+	if rule, ok := s.rule(fileName, func(r InterstitialRule) bool { return r.Intro != "" }); ok {
+		clips = append(clips, rule.Intro)
+	}
+	return clips
+}
+
+// After отмечает проигранный файл и возвращает его аутро
+func (s *Interstitials) After(fileName string) []string {
+	if s == nil || !s.Enabled {
+		return nil
+	}
+
+	s.itemsSincePromo++
+	if rule, ok := s.rule(fileName, func(r InterstitialRule) bool { return r.Outro != "" }); ok {
+		return []string{rule.Outro}
+	}
+	return nil
+}
+
+// rule возвращает первое подходящее к файлу правило, для которого выполняется has
+func (s *Interstitials) rule(fileName string, has func(InterstitialRule) bool) (InterstitialRule, bool) {
+	for _, r := range s.Rules {
+		if has(r) && (matchFileName(r.Files, fileName) || (r.Category != "" && matchFileName(s.Categories[r.Category], fileName))) {
+			return r, true
+		}
+	}
+	return InterstitialRule{}, false
+}
+
+// matchFileName проверяет имя файла по списку имен и шаблонов
+func matchFileName(patterns []string, fileName string) bool {
+	for _, pattern := range patterns {
+		if pattern == fileName {
+			return true
+		}
+		if ok, err := filepath.Match(pattern, fileName); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Play воспроизводит служебные ролики на текущем соединении.
+// Отсутствующий или нечитаемый ролик пропускается; при ошибке соединения оно закрывается,
+// а файл канала продолжится после переподключения.
+func (s *Interstitials) Play(clips []string, pub *Publisher, sessionBitrate *BitrateCalculator) {
+	for _, clip := range clips {
+		if !pub.Connected() {
+			return
+		}
+
+		path := filepath.Join(s.Directory, clip)
+		if _, err := os.Stat(path); err != nil {
+			s.log.Warn("interstitial.missing", "file", clip, "dir", s.Directory)
+			continue
+		}
+
+		s.log.Info("interstitial.start", "file", clip)
+		started := time.Now()
+		played, err := streamClip(path, pub, sessionBitrate, 0)
+		if s.OnClip != nil {
+			s.OnClip(path, started, played, err)
+		}
+		if err != nil {
+			s.log.Error("interstitial.failed", "file", clip, "error", err)
+			if errors.Is(err, ErrWrite) || errors.Is(err, ErrHandshake) {
+				pub.Close()
+				return
+			}
+			continue
+		}
+		s.log.Debug("interstitial.done", "file", clip, "duration", played)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLocale  = "ru"              // Язык сообщений по умолчанию
+	fallbackLocale = "en"              // Язык, используемый при отсутствии перевода
+	localeEnvVar   = "STREAMER_LOCALE" // Переменная окружения для выбора языка
+)
+
+// locale - текущий язык сообщений
+var locale = defaultLocale
+
+// setupLogging настраивает slog и язык сообщений по конфигурации и окружению
+func setupLogging(config *Config) {
+	var out io.Writer = os.Stdout
+	var fileErr error
+	if config.Logging.File != "" {
+		f, err := os.OpenFile(config.Logging.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			out = f
+		}
+		fileErr = err
+	}
+	slog.SetDefault(slog.New(newLogHandler(out, config)))
+	if fileErr != nil {
+		logWarn("log.file_failed", "path", config.Logging.File, "error", fileErr)
+	}
+	setupLocale(config)
+}
+
+// setupLocale выбирает язык сообщений по конфигурации и окружению
+func setupLocale(config *Config) {
+	locale = defaultLocale
+	if config.Logging.Locale != "" {
+		locale = strings.ToLower(config.Logging.Locale)
+	}
+	if env := os.Getenv(localeEnvVar); env != "" {
+		locale = strings.ToLower(env)
+	}
+
+	if config.Logging.CatalogDir != "" {
+		loadCatalogDir(config.Logging.CatalogDir)
+	}
+	if _, ok := catalogs[locale]; !ok {
+		unknown := locale
+		locale = fallbackLocale
+		logWarn("log.locale_unknown", "locale", unknown, "fallback", fallbackLocale)
+	}
+}
+
+// newLogHandler создает обработчик логов с настроенными форматом и уровнем
+func newLogHandler(w io.Writer, config *Config) slog.Handler {
+	opts := &slog.HandlerOptions{Level: parseLogLevel(config.Logging.Level)}
+	if strings.EqualFold(config.Logging.Format, "json") {
+		// Длительности в JSON пишутся в секундах, а не в наносекундах
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if a.Value.Kind() == slog.KindDuration {
+				a.Value = slog.Float64Value(a.Value.Duration().Seconds())
+			}
+			return a
+		}
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// parseLogLevel переводит уровень из конфигурации в slog.Level
+func parseLogLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// loadCatalogDir загружает дополнительные каталоги сообщений из файлов <язык>.json.
+// Сообщения из файлов дополняют и переопределяют встроенные.
+func loadCatalogDir(dir string) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return
+	}
+
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			logWarn("log.catalog_failed", "path", path, "error", err)
+			continue
+		}
+
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			logWarn("log.catalog_failed", "path", path, "error", err)
+			continue
+		}
+
+		lang := strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".json"))
+		if catalogs[lang] == nil {
+			catalogs[lang] = make(map[string]string)
+		}
+		for code, text := range messages {
+			catalogs[lang][code] = text
+		}
+	}
+}
+
+// T возвращает сообщение каталога на текущем языке. Аргументы - пары ключ/значение,
+// как в slog; ключи подставляются в шаблон вместо {ключ}.
+func T(code string, args ...any) string {
+	text, ok := catalogs[locale][code]
+	if !ok {
+		text, ok = catalogs[fallbackLocale][code]
+	}
+	if !ok {
+		return code
+	}
+
+	for i := 0; i+1 < len(args); i += 2 {
+		key, ok := args[i].(string)
+		if !ok {
+			continue
+		}
+		text = strings.ReplaceAll(text, "{"+key+"}", formatLogValue(args[i+1]))
+	}
+	return text
+}
+
+// formatLogValue форматирует значение поля для подстановки в текст сообщения
+func formatLogValue(value any) string {
+	switch v := value.(type) {
+	case time.Duration:
+		if v >= time.Second {
+			return v.Round(time.Second).String()
+		}
+		return v.Round(time.Millisecond).String()
+	case float64:
+		return fmt.Sprintf("%.2f", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Logger пишет сообщения каталога с общими полями, например именем канала.
+// Нулевой *Logger пишет в логгер по умолчанию без дополнительных полей.
+type Logger struct {
+	base *slog.Logger
+
+	// onEvent вызывается для каждой записи независимо от уровня логирования,
+	// например чтобы панель оператора видела историю ошибок канала
+	onEvent func(level slog.Level, code, message string, args []any)
+}
+
+// NewLogger создает логгер, добавляющий поля attrs (пары ключ/значение) к каждой записи
+func NewLogger(attrs ...any) *Logger {
+	return &Logger{base: slog.Default().With(attrs...)}
+}
+
+// event пишет сообщение каталога с машиночитаемым кодом события (поле event) и полями.
+// Код события не зависит от языка, поэтому на него можно настраивать оповещения.
+func (lg *Logger) event(level slog.Level, code string, args ...any) {
+	logger := slog.Default()
+	if lg != nil && lg.base != nil {
+		logger = lg.base
+	}
+	if lg != nil && lg.onEvent != nil {
+		lg.onEvent(level, code, T(code, args...), args)
+	}
+	if !logger.Enabled(context.Background(), level) {
+		return
+	}
+	attrs := append([]any{"event", code}, args...)
+	logger.Log(context.Background(), level, T(code, args...), attrs...)
+}
+
+func (lg *Logger) Debug(code string, args ...any) { lg.event(slog.LevelDebug, code, args...) }
+func (lg *Logger) Info(code string, args ...any)  { lg.event(slog.LevelInfo, code, args...) }
+func (lg *Logger) Warn(code string, args ...any)  { lg.event(slog.LevelWarn, code, args...) }
+func (lg *Logger) Error(code string, args ...any) { lg.event(slog.LevelError, code, args...) }
+
+// Сообщения уровня процесса, не относящиеся к конкретному каналу
+func logDebug(code string, args ...any) { (*Logger)(nil).Debug(code, args...) }
+func logInfo(code string, args ...any)  { (*Logger)(nil).Info(code, args...) }
+func logWarn(code string, args ...any)  { (*Logger)(nil).Warn(code, args...) }
+func logError(code string, args ...any) { (*Logger)(nil).Error(code, args...) }
+
+// logFatal пишет сообщение об ошибке и завершает процесс
+func logFatal(code string, args ...any) {
+	logError(code, args...)
+	os.Exit(1)
+}
+
+// codedError - ошибка, текст которой берется из каталога сообщений на текущем языке
+type codedError struct {
+	code  string
+	class error // Класс ошибки для errors.Is, может отсутствовать
+}
+
+func (e *codedError) Error() string {
+	return T(e.code)
+}
+
+// Code возвращает код ошибки каталога
+func (e *codedError) Code() string {
+	return e.code
+}
+
+// Unwrap возвращает класс ошибки
+func (e *codedError) Unwrap() error {
+	return e.class
+}
+
+// newCodedError создает ошибку с текстом из каталога
+func newCodedError(code string) error {
+	return &codedError{code: code}
+}
+
+// newClassError создает ошибку с текстом из каталога, относящуюся к классу class
+func newClassError(code string, class error) error {
+	return &codedError{code: code, class: class}
+}
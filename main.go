@@ -2,20 +2,22 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nareix/joy4/av"
 	"github.com/nareix/joy4/av/avutil"
 	"github.com/nareix/joy4/format"
-	"github.com/nareix/joy4/format/rtmp"
 )
 
 const (
@@ -32,35 +34,145 @@ const (
 	reconnectTimeout      = 10 * time.Second       // Таймаут для переподключения к RTMP
 	maxConsecutiveErrors  = 10                     // Максимальное количество ошибок подряд перед перезапуском соединения
 	minPlayTime           = 60 * time.Second       // Минимальное время воспроизведения каждого файла
-	stateFilePath         = "stream_state.json"    // Путь к файлу состояния потока
+	defaultStateFilePath  = "stream_state.json"    // Путь к файлу состояния потока по умолчанию
 	saveStateInterval     = 30 * time.Second       // Интервал сохранения состояния
+	waitForFilesInterval  = 5 * time.Second        // Интервал проверки новых файлов
+	configFilePath        = "config.json"          // Путь к файлу конфигурации
+)
+
+// Действия после однократного проигрывания каталога (loopMode = false)
+const (
+	EndActionExit  = "exit"  // Завершить процесс с кодом 0
+	EndActionSlate = "slate" // Удерживать эфир на заставке
+	EndActionWait  = "wait"  // Отключиться и ждать новые файлы
 )
 
 // Config структура для загрузки конфигурации
 type Config struct {
-	RTMP struct {
+	Name     string            `json:"name,omitempty"`     // Имя канала, используется в логах и именах файлов состояния
+	Channels []json.RawMessage `json:"channels,omitempty"` // Каналы; каждая запись переопределяет секции верхнего уровня
+	RTMP     struct {
 		URL string `json:"url"`
 		Key string `json:"key"`
 	} `json:"rtmp"`
 	Video struct {
-		Directory string `json:"directory"`
-		LoopMode  bool   `json:"loopMode"`
+		Directory      string             `json:"directory"`
+		LoopMode       bool               `json:"loopMode"`
+		PlaybackMode   string             `json:"playbackMode"`   // sequential, shuffle, weighted или norepeat
+		Seed           int64              `json:"seed"`           // Зерно для случайных режимов, 0 = случайное при первом запуске
+		NoRepeatWindow int                `json:"noRepeatWindow"` // Сколько последних файлов не повторять в режиме norepeat
+		Weights        map[string]float64 `json:"weights"`        // Веса файлов для режима weighted (по умолчанию 1)
+		EndAction      string             `json:"endAction"`      // Действие после однократного проигрывания: exit, slate или wait
 	} `json:"video"`
+	Staging struct {
+		Enabled             bool   `json:"enabled"`             // Принимать новые файлы через входящий каталог с проверкой
+		IncomingDirectory   string `json:"incomingDirectory"`   // Каталог для загрузки новых файлов
+		QuarantineDirectory string `json:"quarantineDirectory"` // Каталог для отбракованных файлов
+		VideoCodec          string `json:"videoCodec"`          // Требуемый видео кодек (например H264), пусто = любой
+		AudioCodec          string `json:"audioCodec"`          // Требуемый аудио кодек (например AAC), пусто = любой
+		Width               int    `json:"width"`               // Требуемое разрешение, 0 = любое
+		Height              int    `json:"height"`
+		MinDuration         int    `json:"minDuration"` // Минимальная длительность в секундах
+	} `json:"staging"`
+	Slate struct {
+		File string `json:"file"` // Файл заставки (короткий MP4)
+	} `json:"slate"`
+	OnAir struct {
+		Enabled    bool             `json:"enabled"`    // Вещать только в окна вещания
+		Timezone   string           `json:"timezone"`   // Часовой пояс окон (Europe/Moscow), пусто = системный
+		Windows    []OnAirWindow    `json:"windows"`    // Недельные окна вещания
+		Exceptions []OnAirException `json:"exceptions"` // Даты с особыми окнами, например праздники
+		Outside    string           `json:"outside"`    // Вне окна: disconnect или slate
+		Slate      string           `json:"slate"`      // Заставка вне эфира, пусто = slate.file
+	} `json:"onAir"`
+	Override struct {
+		Enabled   bool   `json:"enabled"`   // Ставить в эфир файлы, положенные в каталог экстренных вставок
+		Directory string `json:"directory"` // Каталог экстренных вставок, по умолчанию override
+	} `json:"override"`
 	Settings struct {
-		ForceBitrate       int  `json:"forceBitrate"`       // Принудительно установить битрейт (бит/с), 0 = автоматически
-		ForceKeyframe      bool `json:"forceKeyframe"`      // Принудительно генерировать ключевые кадры
-		KeyframeSeconds    int  `json:"keyframeSeconds"`    // Интервал ключевых кадров в секундах
-		ReconnectOnNewFile bool `json:"reconnectOnNewFile"` // Переподключаться при каждом новом файле
-		DisableEarlyEnd    bool `json:"disableEarlyEnd"`    // Отключить раннее завершение файла
-		MinPlayTime        int  `json:"minPlayTime"`        // Минимальное время воспроизведения каждого файла в секундах
-		RestoreState       bool `json:"restoreState"`       // Восстанавливать состояние при запуске
+		ForceBitrate       int    `json:"forceBitrate"`       // Принудительно установить битрейт (бит/с), 0 = автоматически
+		ForceKeyframe      bool   `json:"forceKeyframe"`      // Принудительно генерировать ключевые кадры
+		KeyframeSeconds    int    `json:"keyframeSeconds"`    // Интервал ключевых кадров в секундах
+		ReconnectOnNewFile bool   `json:"reconnectOnNewFile"` // Переподключаться при каждом новом файле
+		DisableEarlyEnd    bool   `json:"disableEarlyEnd"`    // Отключить раннее завершение файла
+		MinPlayTime        int    `json:"minPlayTime"`        // Минимальное время воспроизведения каждого файла в секундах
+		RestoreState       bool   `json:"restoreState"`       // Восстанавливать состояние при запуске
+		StateFile          string `json:"stateFile"`          // Файл состояния для восстановления после перезапуска
+		StatusFile         string `json:"statusFile"`         // Файл статуса трансляции (JSON)
 	} `json:"settings"`
+	Reconnect struct {
+		InitialDelay     float64 `json:"initialDelay"`     // Первая задержка переподключения в секундах
+		MaxDelay         float64 `json:"maxDelay"`         // Максимальная задержка в секундах
+		Multiplier       float64 `json:"multiplier"`       // Множитель задержки
+		Jitter           float64 `json:"jitter"`           // Случайное отклонение задержки (доля от 0 до 1)
+		FailureThreshold int     `json:"failureThreshold"` // Ошибок соединения подряд до размыкания автомата
+	} `json:"reconnect"`
+	AdBreaks struct {
+		Enabled     bool            `json:"enabled"`     // Включить рекламные паузы
+		Directory   string          `json:"directory"`   // Каталог с локальными рекламными роликами
+		InsertAds   bool            `json:"insertAds"`   // Воспроизводить локальные ролики во время паузы
+		EveryNFiles int             `json:"everyNFiles"` // Пауза на границе каждых N файлов, 0 = отключено
+		Duration    int             `json:"duration"`    // Длительность паузы в секундах по умолчанию
+		Markers     []AdBreakMarker `json:"markers"`     // Паузы для конкретных файлов
+	} `json:"adBreaks"`
+	Interstitials struct {
+		Enabled   bool   `json:"enabled"`   // Вставлять служебные ролики на границах файлов
+		Directory string `json:"directory"` // Каталог служебных роликов, вне каталога видео
+		StationID struct {
+			Files        []string `json:"files"`        // Ролики позывного, по кругу
+			EveryMinutes int      `json:"everyMinutes"` // Позывной раз в N минут на ближайшей границе файла, 0 = отключено
+		} `json:"stationId"`
+		Promos struct {
+			Files       []string `json:"files"`       // Промо-ролики, по кругу
+			EveryNItems int      `json:"everyNItems"` // Промо после каждых K файлов, 0 = отключено
+		} `json:"promos"`
+		Categories map[string][]string `json:"categories"` // Категории: имя -> имена файлов или шаблоны
+		Rules      []InterstitialRule  `json:"rules"`      // Интро и аутро для файлов и категорий
+	} `json:"interstitials"`
+	Logging struct {
+		Level      string `json:"level"`      // Уровень логов: debug, info, warn или error
+		Format     string `json:"format"`     // Формат логов: text или json
+		Locale     string `json:"locale"`     // Язык сообщений: ru или en (переопределяется STREAMER_LOCALE)
+		CatalogDir string `json:"catalogDir"` // Каталог с дополнительными переводами <язык>.json
+		File       string `json:"file"`       // Файл логов, пусто = стандартный вывод
+	} `json:"logging"`
+	Dashboard struct {
+		Enabled bool   `json:"enabled"` // Включить веб-панель оператора
+		Listen  string `json:"listen"`  // Адрес HTTP сервера панели
+		Token   string `json:"token"`   // Ключ для команд панели, пусто = команды только с этой машины
+	} `json:"dashboard"`
+	Webhooks struct {
+		Targets     []WebhookTarget `json:"targets"`     // Получатели событий
+		DeliveryLog string          `json:"deliveryLog"` // Журнал доставки (JSON Lines), пусто = только логи
+	} `json:"webhooks"`
+	Control struct {
+		Enabled bool   `json:"enabled"` // Принимать команды rtmp-streamer ctl
+		Socket  string `json:"socket"`  // Путь к Unix сокету управления
+	} `json:"control"`
+	EPG struct {
+		Enabled        bool   `json:"enabled"`        // Строить программу передач XMLTV
+		File           string `json:"file"`           // Файл программы, пусто = только на панели /epg.xml
+		Hours          int    `json:"hours"`          // На сколько часов вперед, 0 = 24
+		RefreshMinutes int    `json:"refreshMinutes"` // Обновление по таймеру в минутах, 0 = 10
+		ChannelID      string `json:"channelId"`      // ID канала в программе, пусто = имя канала
+		DisplayName    string `json:"displayName"`    // Название канала в программе, пусто = имя канала
+		Language       string `json:"language"`       // Язык названий (ru, en), пусто = не указывать
+	} `json:"epg"`
+	AsRun struct {
+		Enabled   bool   `json:"enabled"`   // Вести журнал эфира
+		Directory string `json:"directory"` // Каталог журнала, по одному файлу на сутки
+	} `json:"asRun"`
+	Media struct {
+		Enabled   bool   `json:"enabled"`   // Вести каталог медиафайлов с результатами проб
+		IndexFile string `json:"indexFile"` // Файл индекса каталога
+	} `json:"media"`
 }
 
 // StreamStatus содержит статус потоковой передачи
 type StreamStatus struct {
 	EndOfFile     bool          // Флаг окончания файла
 	PrepareNext   bool          // Флаг необходимости подготовки следующего файла
+	Interrupted   bool          // Файл прерван командой оператора
 	TotalPackets  int           // Общее количество отправленных пакетов
 	VideoDuration time.Duration // Общая длительность видео
 	ElapsedTime   time.Duration // Прошедшее время
@@ -69,10 +181,14 @@ type StreamStatus struct {
 
 // StreamState содержит информацию о состоянии стрима для сохранения/восстановления
 type StreamState struct {
-	CurrentFile  string        `json:"currentFile"`  // Текущий проигрываемый файл
-	Position     time.Duration `json:"position"`     // Примерная позиция в файле
-	LastSaveTime time.Time     `json:"lastSaveTime"` // Время последнего сохранения
-	FileIndex    int           `json:"fileIndex"`    // Индекс файла в списке
+	CurrentFile  string         `json:"currentFile"`            // Текущий проигрываемый файл
+	Fingerprint  string         `json:"fingerprint,omitempty"`  // Отпечаток содержимого текущего файла
+	Position     time.Duration  `json:"position"`               // Примерная позиция в файле
+	LastSaveTime time.Time      `json:"lastSaveTime"`           // Время последнего сохранения
+	FileIndex    int            `json:"fileIndex"`              // Индекс файла в списке
+	Playlist     *PlaylistState `json:"playlist,omitempty"`     // Порядок воспроизведения и состояние генератора
+	Queue        []string       `json:"queue,omitempty"`        // Очередь оператора
+	QueueCurrent string         `json:"queueCurrent,omitempty"` // Играющий файл из очереди оператора
 }
 
 // BitrateCalculator помогает отслеживать и вычислять битрейт
@@ -84,6 +200,8 @@ type BitrateCalculator struct {
 	CurrentBitrate  int64     // Текущий битрейт в бит/с
 	WindowStartTime time.Time // Время начала текущего окна
 	WindowBytes     int64     // Байты в текущем окне
+
+	OnSample func(bitrate int64) // Вызывается с каждым секундным замером, например для графика панели
 }
 
 // NewBitrateCalculator создает новый калькулятор битрейта
@@ -122,6 +240,9 @@ func (bc *BitrateCalculator) AddBytes(bytes int64) {
 			sum += b
 		}
 		bc.CurrentBitrate = sum / int64(len(bc.SampleWindow))
+		if bc.OnSample != nil {
+			bc.OnSample(bitrate)
+		}
 
 		// Сбрасываем окно
 		bc.WindowStartTime = time.Now()
@@ -147,292 +268,234 @@ func (bc *BitrateCalculator) GetTotalBytes() int64 {
 }
 
 func init() {
-	// Registrar todos los formatos
+	// Регистрируем все форматы joy4
 	format.RegisterAll()
 }
 
 func main() {
+	// Клиент управления работающим процессом
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(runCtl(os.Args[2:]))
+	}
+	// Симуляция эфира без сети
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		os.Exit(runSimulate(os.Args[2:]))
+	}
+	// Выгрузка журнала эфира
+	if len(os.Args) > 1 && os.Args[1] == "asrun" {
+		os.Exit(runAsRun(os.Args[2:]))
+	}
+
 	// Загрузить конфигурацию
-	config, err := loadConfig("config.json")
+	config, err := loadConfig(configFilePath)
 	if err != nil {
-		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
+		logFatal("config.load_failed", "error", err)
 	}
 
-	// Устанавливаем минимальное время воспроизведения, если оно указано в конфигурации
-	minFilePlayTime := minPlayTime
-	if config.Settings.MinPlayTime > 0 {
-		minFilePlayTime = time.Duration(config.Settings.MinPlayTime) * time.Second
-		fmt.Printf("Установлено минимальное время воспроизведения: %v\n", minFilePlayTime)
+	tuiMode := flag.Bool("tui", false, T("flag.tui"))
+	flag.Parse()
+	if *tuiMode {
+		// Ошибка выводится до перенаправления логов в файл, чтобы ее было видно
+		if err := checkTerminal(); err != nil {
+			logFatal("tui.failed", "error", err)
+		}
+		// Логи не должны портить полноэкранный вывод
+		if config.Logging.File == "" {
+			config.Logging.File = defaultTUILogFile
+		}
 	}
+	setupLogging(config)
 
-	videoDir := config.Video.Directory
-	rtmpURL := config.RTMP.URL + config.RTMP.Key
-
-	fmt.Println("=== MP4 RTMP Стример ===")
-	fmt.Printf("RTMP URL: %s\n", rtmpURL)
-	fmt.Printf("Директория видео: %s\n", videoDir)
-
-	// Информация о настройках битрейта
-	if config.Settings.ForceBitrate > 0 {
-		fmt.Printf("Принудительный битрейт: %d kbps\n", config.Settings.ForceBitrate/1000)
-	} else {
-		fmt.Printf("Минимальный битрейт: %d kbps\n", minBitrate/1000)
-	}
-	if config.Settings.ForceKeyframe {
-		fmt.Printf("Принудительная генерация ключевых кадров каждые %d сек\n", config.Settings.KeyframeSeconds)
+	channels, err := channelConfigs(config)
+	if err != nil {
+		logFatal("config.load_failed", "error", err)
 	}
-	if config.Settings.DisableEarlyEnd {
-		fmt.Println("Раннее завершение файла отключено, каждый файл будет воспроизведен до конца")
+	logInfo("app.start", "channels", len(channels))
+
+	// Каждый канал работает в своей горутине под присмотром супервизора,
+	// поэтому сбой или шторм переподключений в одном канале не затрагивает остальные
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	var running []*Channel
+	events := NewEventBus(eventReplaySize)
+	// Вебхуки подписываются до запуска каналов, чтобы не пропустить первые события
+	NewWebhookDispatcher(config.Webhooks.Targets, config.Webhooks.DeliveryLog).Start(events)
+	// Программа передач и журнал эфира тоже подписываются заранее: первые файлы попадают в них по факту эфира
+	var epg *EPGGenerator
+	if config.EPG.Enabled {
+		epg = NewEPGGenerator(config)
+		epg.Start(events)
 	}
-	if config.Settings.RestoreState {
-		fmt.Println("Включено восстановление состояния из предыдущей сессии")
+	var asRun *AsRunLog
+	if config.AsRun.Enabled {
+		asRun = NewAsRunLog(config)
+		asRun.Start(events)
 	}
-	fmt.Println()
-
-	// Первоначальное сканирование директории
-	mp4Files := scanVideoDirectory(videoDir)
-	if len(mp4Files) == 0 {
-		log.Fatal("MP4 файлы не найдены в каталоге видео")
+	// Каталог медиафайлов общий для всех каналов: пробы одного файла не повторяются
+	var media *MediaCatalog
+	if config.Media.Enabled {
+		media = NewMediaCatalog(config)
+		media.Start()
 	}
-
-	// Создаем общий калькулятор битрейта для всей сессии
-	sessionBitrate := NewBitrateCalculator(10)
-
-	// Проверяем существование и загружаем состояние, если необходимо
-	var state *StreamState
-	if config.Settings.RestoreState {
-		var err error
-		state, err = loadStreamState()
-		if err != nil {
-			log.Printf("Ошибка при загрузке состояния: %v. Начинаем с начала.", err)
+	for _, channelConfig := range channels {
+		ch := NewChannel(channelConfig, events)
+		ch.Media = media
+		running = append(running, ch)
+		if epg != nil {
+			epg.Add(ch)
+		}
+		if asRun != nil {
+			asRun.Add(ch)
 		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ch.Supervise(); err != nil {
+				failed.Store(true)
+			}
+		}()
 	}
 
-	// Цикл непрерывного стриминга
-	streamCount := 0
-	fileIndex := 0
-	consecutiveErrors := 0
-
-	// Восстанавливаем fileIndex, если есть сохраненное состояние
-	if state != nil && state.CurrentFile != "" {
-		// Находим индекс файла в списке
-		found := false
-		for i, file := range mp4Files {
-			if file.Name() == state.CurrentFile {
-				fileIndex = i
-				found = true
-				fmt.Printf("🔄 Восстановление с файла #%d: %s, позиция: %v\n",
-					fileIndex+1, state.CurrentFile, state.Position.Round(time.Second))
-				break
+	// Веб-панель оператора общая для всех каналов процесса
+	if config.Dashboard.Enabled {
+		go func() {
+			logInfo("dashboard.started", "listen", config.Dashboard.Listen)
+			if config.Dashboard.Token == "" && !isLoopbackListen(config.Dashboard.Listen) {
+				logWarn("dashboard.read_only", "listen", config.Dashboard.Listen)
 			}
-		}
-
-		if !found {
-			fmt.Printf("⚠️ Не найден файл из сохраненного состояния: %s. Начинаем с первого файла.\n", state.CurrentFile)
-			state = nil // Сбрасываем состояние, если файл не найден
-		}
+			dashboard := NewDashboard(running, events, epg, config.Dashboard.Listen, config.Dashboard.Token)
+			if err := http.ListenAndServe(config.Dashboard.Listen, dashboard); err != nil {
+				logError("dashboard.failed", "error", err)
+			}
+		}()
 	}
 
-	// Текущее состояние для сохранения
-	currentState := &StreamState{
-		FileIndex: fileIndex,
+	// Управление из скриптов через локальный сокет
+	if config.Control.Enabled {
+		go func() {
+			logInfo("ctl.started", "socket", config.Control.Socket)
+			if err := NewControlServer(running).ListenAndServe(config.Control.Socket); err != nil {
+				logError("ctl.server_failed", "error", err)
+			}
+		}()
 	}
 
-	// Создаем таймер для периодического сохранения состояния
-	saveStateTicker := time.NewTicker(saveStateInterval)
-	defer saveStateTicker.Stop()
-
-	// Запускаем горутину для сохранения состояния
+	// Процесс завершается, когда остановлены все каналы
+	stopped := make(chan struct{})
 	go func() {
-		for range saveStateTicker.C {
-			// Проверяем, что есть какая-то информация для сохранения
-			if currentState.CurrentFile != "" {
-				err := saveStreamState(*currentState)
-				if err != nil {
-					log.Printf("Ошибка при сохранении состояния: %v", err)
-				}
-			}
-		}
+		wg.Wait()
+		close(stopped)
 	}()
 
-	for {
-		streamCount++
-		fmt.Printf("\n=== Цикл стриминга #%d ===\n", streamCount)
-
-		// Повторное сканирование директории перед каждым циклом для обнаружения новых файлов
-		mp4Files = scanVideoDirectory(videoDir)
-		if len(mp4Files) == 0 {
-			log.Println("⚠️ MP4 файлы не найдены, ожидание 5 секунд и повторная проверка...")
-			time.Sleep(5 * time.Second)
-			continue
+	if *tuiMode {
+		// Выход из полноэкранного режима завершает процесс
+		if err := NewTerminalUI(running).Run(stopped); err != nil {
+			logFatal("tui.failed", "error", err)
 		}
+		select {
+		case <-stopped:
+		default:
+			os.Exit(0)
+		}
+	}
 
-		for {
-			// Проверяем, что индекс в допустимых пределах
-			if fileIndex >= len(mp4Files) {
-				fileIndex = 0
-			}
-
-			file := mp4Files[fileIndex]
-			videoPath := filepath.Join(videoDir, file.Name())
-			fmt.Printf("\n[%d/%d] Начало стриминга MP4: %s\n", fileIndex+1, len(mp4Files), file.Name())
-			fmt.Printf("-> Отправка на RTMP: %s\n", rtmpURL)
-			fmt.Printf("Текущий общий битрейт сессии: %d kbps\n", sessionBitrate.GetBitrate()/1000)
-
-			// Обновляем информацию о текущем файле в состоянии
-			currentState.CurrentFile = file.Name()
-			currentState.LastSaveTime = time.Now()
-			currentState.FileIndex = fileIndex
-
-			// Определяем, нужно ли использовать начальную позицию для продолжения
-			var startPosition time.Duration = 0
-			if state != nil && state.CurrentFile == file.Name() {
-				startPosition = state.Position
-				fmt.Printf("▶️ Возобновление воспроизведения с позиции: %v\n", startPosition.Round(time.Second))
-				// Сбрасываем состояние, чтобы больше не использовать его
-				state = nil
-			}
-
-			// Попытки трансляции с повторами при ошибках
-			var streamStatus StreamStatus
-			var streamErr error
-
-			startTime := time.Now()
-
-			// Определяем, нужно ли использовать принудительный битрейт
-			targetBitrate := minBitrate
-			if config.Settings.ForceBitrate > 0 {
-				targetBitrate = config.Settings.ForceBitrate
-			}
-
-			for attempt := 1; attempt <= maxRetries; attempt++ {
-				if attempt > 1 {
-					fmt.Printf("⚠️ Повторная попытка %d из %d...\n", attempt, maxRetries)
-					time.Sleep(time.Duration(retryDelay) * time.Second)
-				}
-
-				// Передаем информацию о желаемом битрейте, калькулятор и начальную позицию
-				streamStatus, streamErr = streamFileToRTMP(videoPath, rtmpURL, sessionBitrate,
-					targetBitrate, config, minFilePlayTime, startPosition, currentState)
-				duration := time.Since(startTime)
-
-				if streamErr == nil {
-					// Если streamStatus.PrepareNext = true, значит мы заранее вышли для подготовки следующего файла
-					if streamStatus.PrepareNext {
-						fmt.Printf("🔄 Файл %s почти закончен (длительность: %v), подготовка к следующему файлу\n",
-							file.Name(), duration)
-					} else {
-						fmt.Printf("✅ Стриминг файла %s завершен (длительность: %v)\n", file.Name(), duration)
-					}
-					// Сбрасываем счетчик ошибок при успешной передаче
-					consecutiveErrors = 0
-					break
-				} else {
-					log.Printf("❌ Попытка %d: Ошибка при стриминге: %v", attempt, streamErr)
-					consecutiveErrors++
-				}
-			}
+	<-stopped
+	if failed.Load() {
+		os.Exit(1)
+	}
+}
 
-			// Если слишком много ошибок подряд, делаем паузу и считаем, что нужно переподключиться
-			if consecutiveErrors >= maxConsecutiveErrors {
-				log.Printf("⛔ Слишком много ошибок подряд (%d). Пауза на %v и сброс соединения...",
-					consecutiveErrors, reconnectTimeout)
-				time.Sleep(reconnectTimeout)
-				consecutiveErrors = 0
-			}
+// excludePlayed возвращает файлы, которые еще не были проиграны
+func excludePlayed(files []os.DirEntry, played map[string]bool) []os.DirEntry {
+	var result []os.DirEntry
+	for _, file := range files {
+		if !played[file.Name()] {
+			result = append(result, file)
+		}
+	}
+	return result
+}
 
-			if streamErr != nil {
-				log.Printf("⛔ Все попытки стриминга файла %s не удались. Переход к следующему файлу...", file.Name())
-			} else {
-				// Выводим информацию о битрейте после успешной передачи
-				fmt.Printf("📊 Битрейт трансляции: %d kbps, отправлено: %.2f MB\n",
-					streamStatus.Bitrate/1000, float64(sessionBitrate.GetTotalBytes())/(1024*1024))
-			}
+// findDirEntry ищет запись каталога по имени файла
+func findDirEntry(files []os.DirEntry, name string) os.DirEntry {
+	for _, file := range files {
+		if file.Name() == name {
+			return file
+		}
+	}
+	return nil
+}
 
-			// Сохраняем состояние после завершения файла
-			err := saveStreamState(*currentState)
-			if err != nil {
-				log.Printf("Ошибка при сохранении состояния: %v", err)
-			}
+// Сканирование директории и получение списка MP4 файлов
+func scanVideoDirectory(videoDir string, log *Logger) []os.DirEntry {
+	mp4Files, err := listVideoFiles(videoDir)
+	if err != nil {
+		log.Error("catalog.read_failed", "dir", videoDir, "error", err)
+		return nil
+	}
 
-			// Если мы почти закончили файл и собираемся подготовить следующий,
-			// пересканируем директорию, чтобы найти новые файлы
-			if streamStatus.PrepareNext {
-				fmt.Println("🔍 Сканирование директории на наличие новых файлов...")
-				newMp4Files := scanVideoDirectory(videoDir)
+	if len(mp4Files) == 0 {
+		return nil
+	}
 
-				if len(newMp4Files) > len(mp4Files) {
-					fmt.Printf("📁 Обнаружены новые файлы! Было: %d, стало: %d\n",
-						len(mp4Files), len(newMp4Files))
-					mp4Files = newMp4Files
-				}
-			}
+	log.Info("catalog.found", "files", len(mp4Files))
 
-			// Переходим к следующему файлу
-			fileIndex++
-			// Сбрасываем текущую позицию, так как будет новый файл
-			currentState.Position = 0
-
-			if fileIndex >= len(mp4Files) {
-				fileIndex = 0
-				fmt.Println("\n🔄 Все файлы проиграны, начинаем заново...")
-				// Перед новым циклом делаем небольшую паузу для стабильности
-				time.Sleep(1 * time.Second)
-				break // Завершаем внутренний цикл, чтобы начать новый с обновленным списком файлов
-			}
+	// Информация о файлах; размер берется из записи каталога без отдельного stat
+	for _, file := range mp4Files {
+		info, err := file.Info()
+		if err == nil {
+			log.Info("catalog.file", "file", file.Name(), "size_mb", float64(info.Size())/(1024*1024))
 		}
 	}
+
+	return mp4Files
 }
 
-// Сканирование директории и получение списка MP4 файлов
-func scanVideoDirectory(videoDir string) []os.DirEntry {
+// listVideoFiles возвращает отсортированный по имени список MP4 файлов каталога без вывода информации
+func listVideoFiles(videoDir string) ([]os.DirEntry, error) {
 	// Поиск видеофайлов
 	files, err := os.ReadDir(videoDir)
 	if err != nil {
-		log.Printf("Ошибка при чтении каталога видео: %v", err)
-		return nil
+		return nil, err
 	}
 
 	// Фильтр только MP4 файлов
 	var mp4Files []os.DirEntry
 	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(strings.ToLower(file.Name()), ".mp4") {
+		if !file.IsDir() && isVideoFile(file.Name()) {
 			mp4Files = append(mp4Files, file)
 		}
 	}
 
-	if len(mp4Files) == 0 {
-		return nil
-	}
-
 	// Сортировка файлов по имени для предсказуемого порядка
 	sort.Slice(mp4Files, func(i, j int) bool {
 		return mp4Files[i].Name() < mp4Files[j].Name()
 	})
-
-	fmt.Printf("Найдено %d MP4 файлов для стриминга\n", len(mp4Files))
-
-	// Информация о файлах
-	for _, file := range mp4Files {
-		path := filepath.Join(videoDir, file.Name())
-		info, err := os.Stat(path)
-		if err == nil {
-			fmt.Printf("Файл: %s, Размер: %.2f MB\n", file.Name(), float64(info.Size())/(1024*1024))
-		}
-	}
-
-	return mp4Files
+	return mp4Files, nil
 }
 
 // Загрузка конфигурации из файла
 func loadConfig(configPath string) (*Config, error) {
 	// Значения по умолчанию
 	config := &Config{}
-	config.Settings.KeyframeSeconds = 2       // Ключевой кадр каждые 2 секунды по умолчанию
-	config.Settings.ReconnectOnNewFile = true // По умолчанию переподключаемся при каждом новом файле
-	config.Settings.DisableEarlyEnd = false   // По умолчанию раннее завершение файла включено
-	config.Settings.MinPlayTime = 60          // Минимум 60 секунд воспроизведения по умолчанию
-	config.Settings.RestoreState = true       // По умолчанию восстанавливаем состояние при запуске
+	config.Settings.KeyframeSeconds = 2                 // Ключевой кадр каждые 2 секунды по умолчанию
+	config.Settings.ReconnectOnNewFile = true           // По умолчанию переподключаемся при каждом новом файле
+	config.Settings.DisableEarlyEnd = false             // По умолчанию раннее завершение файла включено
+	config.Settings.MinPlayTime = 60                    // Минимум 60 секунд воспроизведения по умолчанию
+	config.Settings.RestoreState = true                 // По умолчанию восстанавливаем состояние при запуске
+	config.Settings.StateFile = defaultStateFilePath    // Файл состояния по умолчанию
+	config.Settings.StatusFile = defaultStatusFilePath  // Файл статуса по умолчанию
+	config.Video.LoopMode = true                        // По умолчанию каталог проигрывается по кругу
+	config.Video.EndAction = EndActionExit              // По умолчанию после однократного проигрывания процесс завершается
+	config.Logging.Level = "info"                       // По умолчанию без отладочных сообщений
+	config.Logging.Format = "text"                      // По умолчанию логи в текстовом виде
+	config.Dashboard.Listen = defaultDashboardListen    // Панель доступна только с этой машины
+	config.Webhooks.DeliveryLog = defaultWebhookLogPath // Журнал доставки вебхуков по умолчанию
+	config.Control.Enabled = true                       // Управление через сокет доступно только локально
+	config.Control.Socket = defaultControlSocket        // Сокет управления по умолчанию
+	config.AsRun.Directory = defaultAsRunDirectory      // Каталог журнала эфира по умолчанию
+	config.Media.Enabled = true                         // Длительность и ключевые кадры файлов известны до эфира
+	config.Media.IndexFile = defaultMediaIndexFile      // Индекс каталога медиафайлов по умолчанию
 
 	file, err := os.Open(configPath)
 	if err != nil {
@@ -441,7 +504,6 @@ func loadConfig(configPath string) (*Config, error) {
 			config.RTMP.URL = "rtmp://ovsu.okcdn.ru/input/"
 			config.RTMP.Key = "-230262285_889404346_43_bseha6vkqe"
 			config.Video.Directory = "video"
-			config.Video.LoopMode = true
 
 			// Сохраняем дефолтный файл
 			jsonData, _ := json.MarshalIndent(config, "", "  ")
@@ -462,6 +524,98 @@ func loadConfig(configPath string) (*Config, error) {
 	return config, nil
 }
 
+// channelConfigs возвращает конфигурации каналов. Секции верхнего уровня служат значениями
+// по умолчанию: запись в channels переопределяет только указанные в ней поля.
+// Без списка channels работает один канал с конфигурацией верхнего уровня.
+func channelConfigs(config *Config) ([]*Config, error) {
+	if len(config.Channels) == 0 {
+		single := *config
+		if single.Name == "" {
+			single.Name = defaultChannelName
+		}
+		if _, err := NewOnAirSchedule(&single); err != nil {
+			return nil, fmt.Errorf("%s: %w", single.Name, err)
+		}
+		return []*Config{&single}, nil
+	}
+
+	base := *config
+	base.Name = ""
+	base.Channels = nil
+	baseJSON, err := json.Marshal(base)
+	if err != nil {
+		return nil, err
+	}
+
+	var channels []*Config
+	names := make(map[string]bool)
+	stateFiles := make(map[string]bool)
+	sharedDirs := make(map[string]string) // Входящие каталоги и каталоги экстренных вставок -> канал
+	for i, raw := range config.Channels {
+		channel := &Config{}
+		if err := json.Unmarshal(baseJSON, channel); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, channel); err != nil {
+			return nil, fmt.Errorf("%s #%d: %w", T("err.channel_config"), i+1, err)
+		}
+		channel.Channels = nil
+
+		if channel.Name == "" {
+			channel.Name = fmt.Sprintf("channel%d", i+1)
+		}
+		if strings.ContainsAny(channel.Name, `/\`) || names[channel.Name] {
+			return nil, fmt.Errorf("%s: %s", T("err.channel_name"), channel.Name)
+		}
+		names[channel.Name] = true
+
+		// Файлы состояния и статуса, унаследованные с верхнего уровня, разделяются по каналам
+		if channel.Settings.StateFile == config.Settings.StateFile {
+			channel.Settings.StateFile = channelFilePath(channel.Settings.StateFile, channel.Name)
+		}
+		if channel.Settings.StatusFile != "" && channel.Settings.StatusFile == config.Settings.StatusFile {
+			channel.Settings.StatusFile = channelFilePath(channel.Settings.StatusFile, channel.Name)
+		}
+		if stateFiles[channel.Settings.StateFile] {
+			return nil, fmt.Errorf("%s: %s", T("err.channel_state_shared"), channel.Settings.StateFile)
+		}
+		stateFiles[channel.Settings.StateFile] = true
+
+		// Каталоги, из которых канал забирает файлы, тоже разделяются: иначе каналы
+		// переносят одни и те же загруженные файлы, а одна вставка прерывает все каналы
+		if channel.Staging.IncomingDirectory == config.Staging.IncomingDirectory {
+			channel.Staging.IncomingDirectory = channelFilePath(incomingDirectory(config), channel.Name)
+		}
+		if channel.Staging.QuarantineDirectory == config.Staging.QuarantineDirectory {
+			channel.Staging.QuarantineDirectory = channelFilePath(quarantineDirectory(config), channel.Name)
+		}
+		if channel.Override.Directory == config.Override.Directory {
+			channel.Override.Directory = channelFilePath(overrideDirectory(config), channel.Name)
+		}
+		var dirs []string
+		if channel.Staging.Enabled {
+			dirs = append(dirs, incomingDirectory(channel))
+		}
+		if channel.Override.Enabled {
+			dirs = append(dirs, overrideDirectory(channel))
+		}
+		for _, dir := range dirs {
+			dir = filepath.Clean(dir)
+			if other, ok := sharedDirs[dir]; ok {
+				return nil, fmt.Errorf("%s: %s (%s, %s)", T("err.channel_dir_shared"), dir, other, channel.Name)
+			}
+			sharedDirs[dir] = channel.Name
+		}
+
+		if _, err := NewOnAirSchedule(channel); err != nil {
+			return nil, fmt.Errorf("%s: %w", channel.Name, err)
+		}
+
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
 // Пакет с информацией о времени и потоке
 type TimedPacket struct {
 	Packet    av.Packet
@@ -469,7 +623,9 @@ type TimedPacket struct {
 	IsAudio   bool
 }
 
-func streamFileToRTMP(videoPath, rtmpURL string, bitrateCalc *BitrateCalculator, targetBitrate int, config *Config, minPlayTime time.Duration, startPosition time.Duration, state *StreamState) (StreamStatus, error) {
+func streamFileToRTMP(videoPath string, pub *Publisher, bitrateCalc *BitrateCalculator, targetBitrate int, ch *Channel, minPlayTime time.Duration, startPosition time.Duration, state *StreamState, breaks []AdBreak, ads *AdScheduler, media *MediaEntry, meta *FileMeta) (StreamStatus, error) {
+	config := ch.Config()
+
 	// Инициализация статуса
 	status := StreamStatus{
 		EndOfFile:    false,
@@ -483,7 +639,7 @@ func streamFileToRTMP(videoPath, rtmpURL string, bitrateCalc *BitrateCalculator,
 
 tryAgain:
 	// Открыть видеофайл
-	fmt.Println("Открытие MP4 файла...")
+	ch.Log.Debug("file.open", "file", filepath.Base(videoPath))
 	var file av.DemuxCloser
 	var err error
 
@@ -491,92 +647,94 @@ tryAgain:
 	file, err = avutil.Open(videoPath)
 	if err != nil {
 		// Проверяем, не связана ли ошибка с отсутствием атома moov
-		if strings.Contains(err.Error(), "moov") {
+		// При включенном приеме файлов поврежденные файлы уходят в карантин, а не исправляются
+		if isMissingMoov(err) && !config.Staging.Enabled {
 			if fixAttempts < 2 {
-				fmt.Printf("⚠️ Обнаружена ошибка структуры MP4 (отсутствует атом 'moov'), попытка исправления (%d/2)...\n", fixAttempts+1)
+				ch.Log.Warn("repair.missing_moov", "file", filepath.Base(videoPath), "attempt", fixAttempts+1, "max_attempts", 2)
 
 				fixAttempts++
-				err = fixMP4Structure(videoPath)
+				err = fixMP4Structure(videoPath, ch.Log)
 				if err != nil {
-					log.Printf("❌ Не удалось исправить структуру MP4 файла: %v\n", err)
+					ch.Log.Error("repair.failed", "file", filepath.Base(videoPath), "error", err)
 				} else {
-					fmt.Println("✅ Структура MP4 файла исправлена, повторная попытка открытия...")
+					ch.Log.Info("repair.done", "file", filepath.Base(videoPath))
 					time.Sleep(1 * time.Second)
 					goto tryAgain
 				}
 			}
 		}
-		return status, fmt.Errorf("ошибка при открытии MP4 файла: %v", err)
+		return status, fmt.Errorf("%s: %w", T("err.open_file"), demuxError(err))
 	}
 	defer file.Close()
 
-	// Подключение к RTMP серверу с увеличенным таймаутом
-	fmt.Println("Подключение к RTMP серверу...")
-	rtmpConn, err := rtmp.Dial(rtmpURL)
+	// Подключение к RTMP серверу, если соединение еще не установлено
+	err = pub.Connect()
 	if err != nil {
-		return status, fmt.Errorf("ошибка при подключении к RTMP серверу: %v", err)
+		return status, err
 	}
-	defer rtmpConn.Close()
 
 	// Получение информации о потоках
-	fmt.Println("Получение информации о потоках...")
 	streams, err := file.Streams()
 	if err != nil {
 		// Проверяем, не связана ли ошибка с отсутствием атома moov
-		if strings.Contains(err.Error(), "moov") && fixAttempts < 2 {
-			fmt.Printf("⚠️ Ошибка структуры MP4 (отсутствует атом 'moov') при получении потоков, попытка исправления (%d/2)...\n", fixAttempts+1)
+		if isMissingMoov(err) && fixAttempts < 2 && !config.Staging.Enabled {
+			ch.Log.Warn("repair.missing_moov", "file", filepath.Base(videoPath), "attempt", fixAttempts+1, "max_attempts", 2)
 			file.Close()
-			rtmpConn.Close()
 
 			fixAttempts++
-			err = fixMP4Structure(videoPath)
+			err = fixMP4Structure(videoPath, ch.Log)
 			if err != nil {
-				log.Printf("❌ Не удалось исправить структуру MP4 файла: %v\n", err)
-				return status, fmt.Errorf("ошибка при получении потоков: %v", err)
+				ch.Log.Error("repair.failed", "file", filepath.Base(videoPath), "error", err)
+				return status, fmt.Errorf("%w: %s: %w", ErrFileCorrupt, T("err.streams"), err)
 			}
 
-			fmt.Println("✅ Структура MP4 файла исправлена, пробуем открыть снова...")
+			ch.Log.Info("repair.done", "file", filepath.Base(videoPath))
 			goto tryAgain
 		}
-		return status, fmt.Errorf("ошибка при получении потоков: %v", err)
+		return status, fmt.Errorf("%s: %w", T("err.streams"), demuxError(err))
 	}
 
 	// Анализ потоков и идентификация аудио/видео индексов
 	var audioStreamIdx, videoStreamIdx int = -1, -1
-	fmt.Printf("Информация о потоках:\n")
 	for i, stream := range streams {
 		streamType := stream.Type().String()
-		fmt.Printf("  Поток #%d: %s\n", i, streamType)
 
 		if streamType == "H264" || streamType == "Video" {
 			videoStreamIdx = i
-			fmt.Printf("  Видео кодек: %s, Разрешение: %dx%d\n",
-				streamType,
-				stream.(av.VideoCodecData).Width(),
-				stream.(av.VideoCodecData).Height())
+			ch.Log.Debug("stream.video", "index", i, "codec", streamType,
+				"width", stream.(av.VideoCodecData).Width(),
+				"height", stream.(av.VideoCodecData).Height())
 		} else if streamType == "AAC" || streamType == "Audio" {
 			audioStreamIdx = i
 			if audioStream, ok := stream.(av.AudioCodecData); ok {
-				fmt.Printf("  Аудио кодек: %s, Частота: %d Гц, Каналы: %d\n",
-					streamType,
-					audioStream.SampleRate(),
-					audioStream.ChannelLayout().Count())
+				ch.Log.Debug("stream.audio", "index", i, "codec", streamType,
+					"sample_rate", audioStream.SampleRate(),
+					"channels", audioStream.ChannelLayout().Count())
 			}
+		} else {
+			ch.Log.Debug("stream.other", "index", i, "codec", streamType)
 		}
 	}
 
-	fmt.Printf("Обнаружены потоки: Видео=%d, Аудио=%d\n", videoStreamIdx, audioStreamIdx)
+	ch.Log.Debug("stream.detected", "video_index", videoStreamIdx, "audio_index", audioStreamIdx)
 
 	// Проверяем, что нашли хотя бы один поток
 	if videoStreamIdx == -1 && audioStreamIdx == -1 {
-		return status, fmt.Errorf("не найдены аудио или видео потоки в файле")
+		return status, ErrNoStreams
 	}
 
 	// Установка заголовков потоков для RTMP
-	fmt.Println("Запись заголовка потока...")
-	err = rtmpConn.WriteHeader(streams)
+	ch.Log.Debug("stream.write_header", "file", filepath.Base(videoPath))
+	err = pub.WriteHeader(streams)
 	if err != nil {
-		return status, fmt.Errorf("ошибка при записи заголовка: %v", err)
+		return status, err
+	}
+
+	// Название и описание из метаданных файла передаются в потоке сообщением onMetaData
+	if meta != nil && meta.Title != "" {
+		if err := pub.WriteMetadata(meta.Title, meta.Description); err != nil {
+			return status, err
+		}
 	}
 
 	// Создаем калькулятор битрейта для этого файла
@@ -584,16 +742,16 @@ tryAgain:
 
 	// Если у нас есть начальная позиция, пытаемся перемотать к этой позиции
 	if startPosition > 0 {
-		fmt.Printf("📍 Перемотка к позиции %v...\n", startPosition.Round(time.Second))
+		ch.Log.Info("file.seek", "file", filepath.Base(videoPath), "position", startPosition)
 	}
 
 	// Запускаем потоковую передачу пакетов
-	return streamPacketsSync(file, rtmpConn, audioStreamIdx, videoStreamIdx, fileBitrate, bitrateCalc, targetBitrate, config, minPlayTime, startPosition, state)
+	return streamPacketsSync(file, pub, streams, audioStreamIdx, videoStreamIdx, fileBitrate, bitrateCalc, targetBitrate, ch, minPlayTime, startPosition, state, breaks, ads, media, meta)
 }
 
 // fixMP4Structure пытается исправить структуру MP4 файла с отсутствующим атомом 'moov'
-func fixMP4Structure(videoPath string) error {
-	fmt.Println("🔧 Начало исправления структуры MP4 файла...")
+func fixMP4Structure(videoPath string, log *Logger) error {
+	log.Info("repair.start", "file", filepath.Base(videoPath))
 
 	// Создаем временный файл для исправленного видео
 	tmpPath := videoPath + ".fixed.mp4"
@@ -605,21 +763,21 @@ func fixMP4Structure(videoPath string) error {
 	cmd := exec.Command("ffmpeg", "-version")
 	err := cmd.Run()
 	if err != nil {
-		return fmt.Errorf("для исправления MP4 требуется ffmpeg, но он не найден в системе: %v", err)
+		return fmt.Errorf("%s: %w", T("err.ffmpeg_missing"), err)
 	}
 
-	fmt.Println("🔍 Анализ MP4 файла с помощью ffmpeg...")
+	log.Debug("repair.analyze", "file", filepath.Base(videoPath))
 
 	// Запускаем ffmpeg для анализа файла
 	analyzeCmd := exec.Command("ffmpeg", "-v", "error", "-i", videoPath)
 	output, _ := analyzeCmd.CombinedOutput()
 
 	if len(output) > 0 {
-		fmt.Printf("⚠️ Обнаружены проблемы в файле: %s\n", string(output))
+		log.Warn("repair.problems", "file", filepath.Base(videoPath), "output", string(output))
 	}
 
 	// Запускаем ffmpeg для ремонта файла - переупаковываем без перекодирования
-	fmt.Println("🛠️ Исправление структуры MP4 файла с помощью ffmpeg...")
+	log.Debug("repair.remux", "file", filepath.Base(videoPath))
 
 	// Формируем команду для ремонта файла
 	// -c copy = копирование потоков без перекодирования
@@ -635,14 +793,14 @@ func fixMP4Structure(videoPath string) error {
 
 	remuxOutput, err := remuxCmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("ошибка при ремонте MP4 файла: %v\n%s", err, string(remuxOutput))
+		return fmt.Errorf("%s: %w\n%s", T("err.remux"), err, string(remuxOutput))
 	}
 
 	// Создаем бэкап оригинального файла
 	backupPath := videoPath + ".bak"
 	err = os.Rename(videoPath, backupPath)
 	if err != nil {
-		return fmt.Errorf("ошибка при создании бэкапа оригинального файла: %v", err)
+		return fmt.Errorf("%s: %w", T("err.backup"), err)
 	}
 
 	// Заменяем оригинальный файл исправленным
@@ -650,18 +808,25 @@ func fixMP4Structure(videoPath string) error {
 	if err != nil {
 		// Если не удалось, пытаемся восстановить оригинал
 		os.Rename(backupPath, videoPath)
-		return fmt.Errorf("ошибка при замене оригинального файла: %v", err)
+		return fmt.Errorf("%s: %w", T("err.replace"), err)
 	}
 
-	fmt.Println("✅ MP4 файл успешно исправлен и сохранен")
+	log.Debug("repair.saved", "file", filepath.Base(videoPath))
 	return nil
 }
 
 // Синхронизированная потоковая передача пакетов
-func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, videoIdx int,
-	fileBitrate, sessionBitrate *BitrateCalculator, targetBitrate int, config *Config, minPlayTime time.Duration,
-	startPosition time.Duration, state *StreamState) (StreamStatus, error) {
-	fmt.Println("Начало синхронизированной передачи пакетов...")
+func streamPacketsSync(file av.DemuxCloser, pub *Publisher, streams []av.CodecData, audioIdx, videoIdx int,
+	fileBitrate, sessionBitrate *BitrateCalculator, targetBitrate int, ch *Channel, minPlayTime time.Duration,
+	startPosition time.Duration, state *StreamState, breaks []AdBreak, ads *AdScheduler, media *MediaEntry, meta *FileMeta) (StreamStatus, error) {
+	config := ch.Config()
+	ch.Log.Debug("stream.start")
+
+	// Имя файла для событий
+	var currentFile string
+	if state != nil {
+		currentFile = state.CurrentFile
+	}
 
 	// Инициализация статуса
 	status := StreamStatus{
@@ -684,6 +849,16 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 	var videoDuration time.Duration
 	var endDetected bool
 
+	// Известная длительность: из каталога медиафайлов, точка окончания из метаданных ее сокращает
+	knownDuration := media.Duration()
+	var outPoint time.Duration
+	if meta != nil && meta.OutPoint > 0 {
+		outPoint = meta.OutPoint
+		if knownDuration == 0 || outPoint < knownDuration {
+			knownDuration = outPoint
+		}
+	}
+
 	// Предотвращаем раннее завершение при коротких файлах
 	minTimeReached := false
 
@@ -695,6 +870,14 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 	// Таймстампы реального времени для синхронизации
 	baseRealTime := time.Now()
 
+	// Рекламные паузы внутри файла; паузы до начальной позиции пропускаются
+	nextBreak := 0
+	for nextBreak < len(breaks) && breaks[nextBreak].At < startPosition {
+		nextBreak++
+	}
+	// Время, потраченное на вставку рекламы, не учитывается при оценке конца файла
+	var insertedTime time.Duration
+
 	// Время последнего принудительного ключевого кадра
 	lastKeyframeTime := time.Now()
 
@@ -709,7 +892,7 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 	go func() {
 		<-minPlayTimeTimer.C
 		minTimeReached = true
-		fmt.Printf("⏱️ Достигнуто минимальное время воспроизведения: %v\n", minPlayTime)
+		ch.Log.Debug("file.min_play_time", "min_play_time", minPlayTime)
 	}()
 	defer minPlayTimeTimer.Stop()
 
@@ -717,11 +900,11 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 		pkt, err := file.ReadPacket()
 		if err != nil {
 			if err == io.EOF {
-				fmt.Println("Конец файла, стрим завершен")
+				ch.Log.Debug("stream.eof")
 				status.EndOfFile = true
 				break
 			}
-			return status, fmt.Errorf("ошибка чтения пакета: %v", err)
+			return status, fmt.Errorf("%s: %w", T("err.read_packet"), demuxError(err))
 		}
 
 		totalPackets++
@@ -739,25 +922,31 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 		if isVideo && firstVideoTS < 0 {
 			firstVideoTS = pkt.Time
 			lastVideoTS = pkt.Time
-			fmt.Printf("Первый видео таймстамп: %v\n", firstVideoTS)
+			ch.Log.Debug("stream.first_video_ts", "timestamp", firstVideoTS)
 
 			// Устанавливаем позицию для пропуска пакетов
 			if skipToPosition {
-				skipUntilPos = firstVideoTS + startPosition
-				fmt.Printf("Пропуск пакетов до позиции: %v\n", skipUntilPos)
+				// По индексу ключевых кадров продолжение начинается с ключевого кадра, а не с середины GOP
+				seekTo := startPosition
+				if keyframe, ok := media.KeyframeAtOrBefore(startPosition); ok {
+					seekTo = keyframe
+					ch.Log.Debug("media.seek_keyframe", "position", startPosition, "keyframe", keyframe)
+				}
+				skipUntilPos = firstVideoTS + seekTo
+				ch.Log.Debug("stream.skip_until", "timestamp", skipUntilPos)
 				skipStarted = true
 			}
 		} else if isAudio && firstAudioTS < 0 {
 			firstAudioTS = pkt.Time
 			lastAudioTS = pkt.Time
-			fmt.Printf("Первый аудио таймстамп: %v\n", firstAudioTS)
+			ch.Log.Debug("stream.first_audio_ts", "timestamp", firstAudioTS)
 		}
 
 		// Если оба первых таймстампа еще не обнаружены, просто отправляем пакеты без задержки
 		if firstVideoTS < 0 || firstAudioTS < 0 {
-			err = rtmpConn.WritePacket(pkt)
+			err = pub.WritePacket(pkt)
 			if err != nil {
-				return status, fmt.Errorf("ошибка отправки начального пакета: %v", err)
+				return status, fmt.Errorf("%s: %w", T("err.write_initial_packet"), err)
 			}
 			continue
 		}
@@ -782,6 +971,7 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 			if state != nil {
 				state.Position = streamPos
 			}
+			ch.Monitor.SetPosition(streamPos)
 		} else if isAudio {
 			streamPos = pkt.Time - firstAudioTS
 			lastAudioTS = pkt.Time
@@ -795,17 +985,63 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 			videoDuration = streamPos
 		}
 
+		// Точка окончания из метаданных завершает файл так же, как его конец
+		if isVideo && outPoint > 0 && streamPos >= outPoint {
+			ch.Log.Debug("meta.out_point", "position", streamPos)
+			status.EndOfFile = true
+			break
+		}
+
 		// Если нужно пропустить пакеты до определенной позиции (восстановление состояния)
 		if skipStarted && (pkt.Time < skipUntilPos) {
 			// Просто пропускаем эти пакеты
 			continue
 		} else if skipStarted {
 			skipStarted = false
-			fmt.Printf("📍 Достигнута начальная позиция %v, начинаем передачу\n", streamPos.Round(time.Second))
+			ch.Log.Info("file.position_reached", "position", streamPos)
 			// Переустанавливаем базовое время, чтобы синхронизация начиналась с текущего момента
 			baseRealTime = time.Now().Add(-streamPos)
 		}
 
+		// Рекламная пауза на ключевом кадре, чтобы вернуться к контенту с той же позиции
+		if isVideo && pkt.IsKeyFrame && nextBreak < len(breaks) && streamPos >= breaks[nextBreak].At {
+			adBreak := breaks[nextBreak]
+			nextBreak++
+			ch.Log.Info("ads.break_point", "position", streamPos)
+
+			adTime, err := ads.RunBreak(pub, adBreak.Duration, sessionBitrate)
+			if err != nil {
+				return status, fmt.Errorf("%s: %w", T("err.ad_break"), err)
+			}
+			if adTime > 0 {
+				// Возвращаемся к основному контенту с его заголовками
+				insertedTime += adTime
+				err = pub.WriteHeader(streams)
+				if err != nil {
+					return status, err
+				}
+				ch.Log.Info("ads.resume_content", "position", streamPos)
+			}
+			baseRealTime = time.Now().Add(-streamPos)
+		}
+
+		// Экстренная вставка прерывает файл на ключевом кадре; после нее файл продолжается с этого же кадра
+		if isVideo && pkt.IsKeyFrame && ch.overridePending() {
+			ch.Log.Warn("override.cut", "file", currentFile, "position", streamPos)
+
+			overrideTime, err := runOverrides(pub, ch, sessionBitrate)
+			insertedTime += overrideTime
+			if err != nil {
+				return status, fmt.Errorf("%s: %w", T("err.override"), err)
+			}
+			err = pub.WriteHeader(streams)
+			if err != nil {
+				return status, err
+			}
+			ch.Log.Info("override.resume", "file", currentFile, "position", streamPos)
+			baseRealTime = time.Now().Add(-streamPos)
+		}
+
 		// Точное время, когда пакет должен быть отправлен
 		targetSendTime := baseRealTime.Add(streamPos)
 
@@ -817,22 +1053,39 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 			time.Sleep(waitTime)
 		} else if waitTime > 500*time.Millisecond {
 			// Если задержка слишком большая, корректируем базовое время
-			fmt.Printf("⚠️ Большая задержка обнаружена (%v), перекалибровка\n", waitTime)
+			ch.Log.Warn("stream.recalibrate", "delay", waitTime)
 			baseRealTime = time.Now().Add(-streamPos)
 		}
 
 		// Отправляем пакет
-		err = rtmpConn.WritePacket(pkt)
+		err = pub.WritePacket(pkt)
 		if err != nil {
-			return status, fmt.Errorf("ошибка отправки пакета: %v", err)
+			return status, fmt.Errorf("%s: %w", T("err.write_packet"), err)
+		}
+
+		// Прогресс потоков для панелей оператора
+		var videoProgress, audioProgress time.Duration
+		if lastVideoTS > firstVideoTS {
+			videoProgress = lastVideoTS - firstVideoTS
+		}
+		if lastAudioTS > firstAudioTS {
+			audioProgress = lastAudioTS - firstAudioTS
+		}
+		ch.Monitor.SetStreamProgress(videoProgress, audioProgress)
+
+		// Команда оператора прерывает файл, позиция остается в состоянии для продолжения
+		if ch.interrupted() {
+			ch.Log.Debug("control.interrupted", "position", streamPos)
+			status.Interrupted = true
+			break
 		}
 
 		// Периодическое сохранение состояния
 		if state != nil && time.Since(lastStateSaveTime) > stateSaveInterval {
 			lastStateSaveTime = time.Now()
-			err := saveStreamState(*state)
+			err := saveStreamState(ch, *state)
 			if err != nil {
-				log.Printf("Ошибка при сохранении состояния: %v", err)
+				ch.Log.Error("state.save_failed", "error", err)
 			}
 		}
 
@@ -841,24 +1094,29 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 		if isVideo && !endDetected && minTimeReached && !config.Settings.DisableEarlyEnd {
 			// Проверяем, можем ли мы определить приближение конца файла
 			if pkt.IsKeyFrame && videoDuration > preloadNextFileTime {
-				elapsedTime := time.Since(startTime)
+				elapsedTime := time.Since(startTime) - insertedTime
 
 				// Определяем оставшееся время более точно
 				// Используем метаданные файла, если они доступны, иначе приближенные вычисления
 				estimatedRemaining := time.Duration(0)
-
-				// Если файл воспроизводится достаточно долго, можно использовать отношение времени
-				if elapsedTime > 30*time.Second && streamPos > 0 {
+				estimated := false
+
+				if knownDuration > 0 {
+					// Длительность известна из каталога медиафайлов или точки окончания
+					estimatedRemaining = knownDuration - streamPos
+					estimated = true
+				} else if elapsedTime > 30*time.Second && streamPos > 0 {
+					// Если файл воспроизводится достаточно долго, можно использовать отношение времени
 					elapsedRatio := float64(elapsedTime) / float64(streamPos)
 					estimatedRemaining = time.Duration(float64(videoDuration-streamPos) * elapsedRatio)
+					estimated = true
+				}
 
-					// Устанавливаем флаг подготовки следующего файла, если осталось мало времени
-					if estimatedRemaining < preloadNextFileTime {
-						fmt.Printf("🔍 Приближается конец файла! Прошло: %v, Текущая позиция: %v, Осталось ~%v\n",
-							elapsedTime.Round(time.Second), streamPos.Round(time.Second), estimatedRemaining.Round(time.Second))
-						status.PrepareNext = true
-						endDetected = true
-					}
+				// Устанавливаем флаг подготовки следующего файла, если осталось мало времени
+				if estimated && estimatedRemaining < preloadNextFileTime {
+					ch.Log.Info("file.end_approaching", "elapsed", elapsedTime, "position", streamPos, "remaining", estimatedRemaining)
+					status.PrepareNext = true
+					endDetected = true
 				}
 			}
 		}
@@ -868,8 +1126,8 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 		if status.PrepareNext && minTimeReached && !config.Settings.DisableEarlyEnd {
 			// Задержка для стабильности
 			if elapsedReal := time.Since(startTime); elapsedReal > minPlayTime {
-				fmt.Printf("🔄 Заблаговременное завершение трансляции после %v для подготовки следующего файла\n",
-					elapsedReal.Round(time.Second))
+				ch.Log.Info("file.early_end", "elapsed", elapsedReal)
+				ch.emit(EventEarlyEnd, EarlyEndEvent{File: currentFile, Position: streamPos.Seconds(), Elapsed: elapsedReal.Seconds()})
 				break
 			}
 		}
@@ -879,26 +1137,15 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 			currentBitrate := fileBitrate.GetBitrate()
 			elapsed := time.Since(startTime)
 
-			videoProgress := ""
-			audioProgress := ""
-
-			if firstVideoTS >= 0 && lastVideoTS > firstVideoTS {
-				videoProgress = fmt.Sprintf("Видео: %v", lastVideoTS-firstVideoTS)
-			}
-
-			if firstAudioTS >= 0 && lastAudioTS > firstAudioTS {
-				audioProgress = fmt.Sprintf("Аудио: %v", lastAudioTS-firstAudioTS)
-			}
-
-			fmt.Printf("  ▶️ Отправлено пакетов: %d | Битрейт: %d kbps | Время: %v | %s | %s\n",
-				totalPackets, currentBitrate/1000, elapsed.Round(time.Second), videoProgress, audioProgress)
+			ch.Log.Debug("stream.progress", "packets", totalPackets, "bitrate_kbps", currentBitrate/1000,
+				"elapsed", elapsed, "video", videoProgress, "audio", audioProgress)
 
 			lastStatusTime = time.Now()
 
 			// Проверка на достаточность битрейта
 			if currentBitrate < int64(minBitrate) {
-				fmt.Printf("⚠️ Внимание! Текущий битрейт (%d kbps) ниже рекомендуемого (%d kbps)\n",
-					currentBitrate/1000, minBitrate/1000)
+				ch.Log.Warn("stream.low_bitrate", "bitrate_kbps", currentBitrate/1000, "min_kbps", minBitrate/1000)
+				ch.emit(EventBitrateWarning, BitrateWarningEvent{File: currentFile, Bitrate: currentBitrate, MinBitrate: minBitrate})
 			}
 		}
 	}
@@ -912,52 +1159,85 @@ func streamPacketsSync(file av.DemuxCloser, rtmpConn *rtmp.Conn, audioIdx, video
 	// Вычисляем средний битрейт за всю передачу
 	avgBitrate := int64(float64(totalBytes*8) / status.ElapsedTime.Seconds())
 
-	fmt.Printf("Стриминг завершен. Пакетов: %d | Длительность: %v | Битрейт: %d kbps\n",
-		totalPackets, status.ElapsedTime.Round(time.Second), avgBitrate/1000)
+	ch.Log.Info("stream.done", "packets", totalPackets, "duration", status.ElapsedTime, "bitrate_kbps", avgBitrate/1000)
 	return status, nil
 }
 
 // Сохранение состояния стрима в файл
-func saveStreamState(state StreamState) error {
+func saveStreamState(ch *Channel, state StreamState) error {
 	state.LastSaveTime = time.Now()
+	state.Queue, state.QueueCurrent = ch.Queue.State()
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
-		return fmt.Errorf("ошибка при преобразовании состояния в JSON: %v", err)
+		return fmt.Errorf("%s: %w", T("err.state_marshal"), err)
 	}
 
-	err = os.WriteFile(stateFilePath, data, 0644)
+	err = os.WriteFile(ch.StateFile(), data, 0644)
 	if err != nil {
-		return fmt.Errorf("ошибка при сохранении состояния в файл: %v", err)
+		return fmt.Errorf("%s: %w", T("err.state_write"), err)
 	}
 
-	fmt.Printf("💾 Состояние стрима сохранено: Файл %s, Позиция %v\n",
-		state.CurrentFile, state.Position.Round(time.Second))
+	ch.Log.Debug("state.saved", "file", state.CurrentFile, "position", state.Position)
+	ch.emit(EventStateSaved, StateSavedEvent{File: state.CurrentFile, Position: state.Position.Seconds(), Path: ch.StateFile()})
 	return nil
 }
 
 // Загрузка состояния стрима из файла
-func loadStreamState() (*StreamState, error) {
-	data, err := os.ReadFile(stateFilePath)
+func loadStreamState(ch *Channel) (*StreamState, error) {
+	data, err := os.ReadFile(ch.StateFile())
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil // Файл не существует, это нормально
 		}
-		return nil, fmt.Errorf("ошибка при чтении файла состояния: %v", err)
+		return nil, fmt.Errorf("%s: %w", T("err.state_read"), err)
 	}
 
 	var state StreamState
 	err = json.Unmarshal(data, &state)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при разборе JSON состояния: %v", err)
+		return nil, fmt.Errorf("%s: %w", T("err.state_parse"), err)
 	}
 
 	// Проверяем, не устарело ли состояние (например, больше недели)
 	if time.Since(state.LastSaveTime) > 7*24*time.Hour {
-		fmt.Println("⚠️ Сохраненное состояние устарело (больше недели), начинаем с начала")
+		ch.Log.Warn("state.stale", "saved_at", state.LastSaveTime)
 		return nil, nil
 	}
 
-	fmt.Printf("📂 Загружено состояние стрима: Файл %s, Позиция %v\n",
-		state.CurrentFile, state.Position.Round(time.Second))
+	ch.Log.Info("state.loaded", "file", state.CurrentFile, "position", state.Position)
 	return &state, nil
 }
+
+// restoreStateFile сверяет файл из состояния с каталогом по отпечатку содержимого.
+// Переименованный файл находится по отпечатку и продолжается с сохраненной позиции.
+// Если под тем же именем теперь другое содержимое, файл начинается сначала.
+// Состояние без отпечатка, сохраненное прежними версиями, сверяется только по имени.
+func restoreStateFile(ch *Channel, state *StreamState, videoDir string, files []os.DirEntry) {
+	if state == nil || state.CurrentFile == "" || state.Fingerprint == "" {
+		return
+	}
+	fingerprint, err := ch.Media.Fingerprint(filepath.Join(videoDir, state.CurrentFile))
+	if err == nil && fingerprint == state.Fingerprint {
+		return
+	}
+
+	for _, file := range files {
+		if file.Name() == state.CurrentFile {
+			continue
+		}
+		if other, err := ch.Media.Fingerprint(filepath.Join(videoDir, file.Name())); err == nil && other == state.Fingerprint {
+			ch.Log.Info("state.file_renamed", "from", state.CurrentFile, "to", file.Name())
+			if state.QueueCurrent == state.CurrentFile {
+				state.QueueCurrent = file.Name()
+			}
+			state.CurrentFile = file.Name()
+			return
+		}
+	}
+
+	if findDirEntry(files, state.CurrentFile) != nil {
+		ch.Log.Warn("state.file_changed", "file", state.CurrentFile, "position", state.Position)
+		state.Position = 0
+		state.Fingerprint = fingerprint
+	}
+}
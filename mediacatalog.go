@@ -0,0 +1,460 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMediaIndexFile = "media_index.json" // Индекс каталога медиафайлов по умолчанию
+	mediaIndexVersion     = 1                  // Версия формата индекса; индекс другой версии строится заново
+	mediaPartialHashSize  = 1 << 20            // Сколько байт с начала и с конца файла входит в частичный хэш
+	mediaSaveEvery        = 10                 // Индекс сохраняется после стольких проб, даже если очередь не пуста
+)
+
+// GOPStats - интервалы между ключевыми кадрами файла
+type GOPStats struct {
+	Min time.Duration `json:"min"`
+	Max time.Duration `json:"max"`
+	Avg time.Duration `json:"avg"`
+}
+
+// MediaEntry - результат пробы файла. Запись действительна, пока совпадают размер
+// и время изменения файла; частичный хэш позволяет узнать файл после переименования.
+type MediaEntry struct {
+	Path        string          `json:"path"`
+	Size        int64           `json:"size"`
+	ModTime     time.Time       `json:"modTime"`
+	PartialHash string          `json:"partialHash"`           // SHA-256 размера, первого и последнего мегабайта
+	Fingerprint string          `json:"fingerprint,omitempty"` // Отпечаток содержимого, см. fileFingerprint
+	Probed      time.Time       `json:"probed"`
+	Valid       bool            `json:"valid"`            // Файл целиком демультиплексируется
+	Reason      string          `json:"reason,omitempty"` // Код причины, если файл не прошел проверку
+	Error       string          `json:"error,omitempty"`
+	Probe       *ProbeResult    `json:"probe,omitempty"`
+	Keyframes   []time.Duration `json:"keyframes,omitempty"` // Позиции ключевых кадров от начала видео
+	GOP         GOPStats        `json:"gop"`
+}
+
+// Duration возвращает длительность файла по результату пробы, 0 если она неизвестна
+func (e *MediaEntry) Duration() time.Duration {
+	if e == nil || e.Probe == nil {
+		return 0
+	}
+	return e.Probe.Duration
+}
+
+// Playable сообщает, стоит ли пытаться воспроизвести файл. Файл без moov еще может
+// быть исправлен при воспроизведении, ошибка открытия может быть временной.
+func (e *MediaEntry) Playable() bool {
+	return e == nil || e.Valid || e.Reason == RejectNoMoov || e.Reason == RejectOpenFailed
+}
+
+// KeyframeAtOrBefore возвращает позицию последнего ключевого кадра не позже pos
+func (e *MediaEntry) KeyframeAtOrBefore(pos time.Duration) (time.Duration, bool) {
+	if e == nil {
+		return 0, false
+	}
+	i := sort.Search(len(e.Keyframes), func(i int) bool { return e.Keyframes[i] > pos })
+	if i == 0 {
+		return 0, false
+	}
+	return e.Keyframes[i-1], true
+}
+
+// KeyframeAfter возвращает позицию первого ключевого кадра строго после pos
+func (e *MediaEntry) KeyframeAfter(pos time.Duration) (time.Duration, bool) {
+	if e == nil {
+		return 0, false
+	}
+	i := sort.Search(len(e.Keyframes), func(i int) bool { return e.Keyframes[i] > pos })
+	if i == len(e.Keyframes) {
+		return 0, false
+	}
+	return e.Keyframes[i], true
+}
+
+// mediaIndex - формат файла индекса
+type mediaIndex struct {
+	Version int           `json:"version"`
+	Files   []*MediaEntry `json:"files"`
+}
+
+// MediaCatalog хранит результаты проб файлов всех каналов процесса в JSON индексе,
+// чтобы длительность, кодеки и ключевые кадры были известны до воспроизведения.
+// Пробы выполняются в отдельной горутине только для новых и измененных файлов.
+// Нулевой каталог допустим: поиск в нем ничего не находит.
+type MediaCatalog struct {
+	File string // Файл индекса
+
+	mu      sync.Mutex
+	entries map[string]*MediaEntry // Записи по абсолютному пути
+	pending map[string]bool        // Файлы, ожидающие пробы
+	wake    chan struct{}
+}
+
+// NewMediaCatalog загружает индекс медиафайлов из конфигурации процесса
+func NewMediaCatalog(config *Config) *MediaCatalog {
+	m := &MediaCatalog{
+		File:    config.Media.IndexFile,
+		entries: make(map[string]*MediaEntry),
+		pending: make(map[string]bool),
+		wake:    make(chan struct{}, 1),
+	}
+	if m.File == "" {
+		m.File = defaultMediaIndexFile
+	}
+
+	data, err := os.ReadFile(m.File)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logWarn("media.load_failed", "path", m.File, "error", err)
+		}
+		return m
+	}
+	var index mediaIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		logWarn("media.load_failed", "path", m.File, "error", err)
+		return m
+	}
+	if index.Version != mediaIndexVersion {
+		return m
+	}
+	for _, entry := range index.Files {
+		m.entries[entry.Path] = entry
+	}
+	logDebug("media.loaded", "path", m.File, "files", len(m.entries))
+	return m
+}
+
+// Start запускает пробы файлов в отдельной горутине
+func (m *MediaCatalog) Start() {
+	go m.run()
+}
+
+// Refresh ставит в очередь пробы файлы каталога, которых нет в индексе или которые изменились
+func (m *MediaCatalog) Refresh(dir string, files []os.DirEntry) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queued := false
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		path := mediaPath(filepath.Join(dir, file.Name()))
+		if entry, ok := m.entries[path]; ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+			continue
+		}
+		if !m.pending[path] {
+			m.pending[path] = true
+			queued = true
+		}
+	}
+	if queued {
+		select {
+		case m.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Lookup возвращает запись файла, если она соответствует файлу на диске
+func (m *MediaCatalog) Lookup(path string) *MediaEntry {
+	if m == nil {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.entries[mediaPath(path)]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return nil
+	}
+	return entry
+}
+
+// Duration возвращает длительность файла из индекса, а для файла без записи - из заголовка MP4
+func (m *MediaCatalog) Duration(path string) time.Duration {
+	if d := m.Lookup(path).Duration(); d > 0 {
+		return d
+	}
+	return mp4Duration(path)
+}
+
+// Fingerprint возвращает отпечаток содержимого файла из индекса или считает его по файлу
+func (m *MediaCatalog) Fingerprint(path string) (string, error) {
+	if entry := m.Lookup(path); entry != nil && entry.Fingerprint != "" {
+		return entry.Fingerprint, nil
+	}
+	return fileFingerprint(path)
+}
+
+// run выполняет пробы файлов из очереди и сохраняет индекс
+func (m *MediaCatalog) run() {
+	probed := 0
+	for range m.wake {
+		for {
+			path, ok := m.next()
+			if !ok {
+				break
+			}
+			if m.probe(path) {
+				probed++
+			}
+			if probed >= mediaSaveEvery {
+				m.save()
+				probed = 0
+			}
+		}
+		if probed > 0 {
+			m.save()
+			probed = 0
+		}
+	}
+}
+
+// next забирает следующий файл из очереди пробы
+func (m *MediaCatalog) next() (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for path := range m.pending {
+		delete(m.pending, path)
+		return path, true
+	}
+	return "", false
+}
+
+// probe строит запись файла. Переименованный файл узнается по размеру и частичному хэшу
+// и получает запись старого имени без повторной пробы. Возвращает true, если индекс изменился.
+func (m *MediaCatalog) probe(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	hash, err := partialHash(path, info.Size())
+	if err != nil {
+		logWarn("media.probe_failed", "file", path, "error", err)
+		return false
+	}
+
+	if renamed := m.renamed(path, info.Size(), hash); renamed != nil {
+		logInfo("media.renamed", "from", renamed.Path, "to", path)
+		entry := *renamed
+		entry.Path, entry.ModTime = path, info.ModTime()
+		m.mu.Lock()
+		delete(m.entries, renamed.Path)
+		m.entries[path] = &entry
+		m.mu.Unlock()
+		return true
+	}
+
+	entry := &MediaEntry{
+		Path:        path,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		PartialHash: hash,
+		Probed:      time.Now(),
+		Valid:       true,
+	}
+	entry.Fingerprint, _ = fileFingerprint(path)
+	probe, err := probeVideoFile(path)
+	entry.Probe = probe
+	if err != nil {
+		entry.Valid = false
+		entry.Error = err.Error()
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			entry.Reason, entry.Error = verr.Reason, verr.Details
+		}
+		logWarn("media.invalid", "file", path, "reason", entry.Reason, "error", entry.Error)
+	}
+	if probe != nil {
+		entry.Keyframes = probe.KeyframeTimes
+		entry.GOP = gopStats(probe.KeyframeTimes)
+	}
+	logInfo("media.probed", "file", filepath.Base(path), "duration", entry.Duration(),
+		"keyframes", len(entry.Keyframes), "gop_avg", entry.GOP.Avg)
+
+	m.mu.Lock()
+	m.entries[path] = entry
+	m.mu.Unlock()
+	return true
+}
+
+// renamed ищет запись файла, который исчез с диска, с тем же размером и частичным хэшем
+func (m *MediaCatalog) renamed(path string, size int64, hash string) *MediaEntry {
+	m.mu.Lock()
+	var candidates []*MediaEntry
+	for _, entry := range m.entries {
+		if entry.Path != path && entry.Size == size && entry.PartialHash == hash {
+			candidates = append(candidates, entry)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, entry := range candidates {
+		if _, err := os.Stat(entry.Path); os.IsNotExist(err) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// save записывает индекс через временный файл. Записи удаленных файлов в индекс не попадают.
+func (m *MediaCatalog) save() {
+	m.mu.Lock()
+	var files []*MediaEntry
+	for _, entry := range m.entries {
+		files = append(files, entry)
+	}
+	m.mu.Unlock()
+
+	kept := files[:0]
+	for _, entry := range files {
+		if _, err := os.Stat(entry.Path); os.IsNotExist(err) {
+			m.mu.Lock()
+			delete(m.entries, entry.Path)
+			m.mu.Unlock()
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].Path < kept[j].Path
+	})
+
+	data, err := json.MarshalIndent(mediaIndex{Version: mediaIndexVersion, Files: kept}, "", "  ")
+	if err != nil {
+		logError("media.save_failed", "path", m.File, "error", err)
+		return
+	}
+	tmp := m.File + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logError("media.save_failed", "path", m.File, "error", err)
+		return
+	}
+	if err := os.Rename(tmp, m.File); err != nil {
+		logError("media.save_failed", "path", m.File, "error", err)
+		return
+	}
+	logDebug("media.saved", "path", m.File, "files", len(kept))
+}
+
+// mediaPath приводит путь к абсолютному, чтобы каналы и команды находили одну запись
+func mediaPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// partialHash считает SHA-256 размера файла, первого и последнего мегабайта
+func partialHash(path string, size int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:", size)
+	if _, err := io.CopyN(h, file, mediaPartialHashSize); err != nil && err != io.EOF {
+		return "", err
+	}
+	// Середина большого файла пропускается, небольшой файл хэшируется целиком
+	if size > 2*mediaPartialHashSize {
+		if _, err := file.Seek(-mediaPartialHashSize, io.SeekEnd); err != nil {
+			return "", err
+		}
+	}
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fileFingerprint возвращает отпечаток содержимого MP4 файла: SHA-256 размера файла и атома moov.
+// Атом moov описывает все сэмплы файла, поэтому другой файл под тем же именем дает другой
+// отпечаток, а переименование отпечаток не меняет. Читается только moov, а не весь файл.
+func fileFingerprint(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	size := info.Size()
+	header := make([]byte, 16)
+	for offset := int64(0); offset+8 <= size; {
+		if _, err := file.ReadAt(header[:8], offset); err != nil {
+			return "", err
+		}
+		boxSize, headerSize := int64(binary.BigEndian.Uint32(header[:4])), int64(8)
+		switch boxSize {
+		case 0:
+			// Атом до конца файла
+			boxSize = size - offset
+		case 1:
+			// 64-битный размер после типа атома
+			if _, err := file.ReadAt(header[8:16], offset+8); err != nil {
+				return "", err
+			}
+			boxSize, headerSize = int64(binary.BigEndian.Uint64(header[8:16])), 16
+		}
+		if boxSize < headerSize || offset+boxSize > size {
+			break
+		}
+		if string(header[4:8]) == "moov" {
+			h := sha256.New()
+			fmt.Fprintf(h, "%d:", size)
+			if _, err := io.Copy(h, io.NewSectionReader(file, offset, boxSize)); err != nil {
+				return "", err
+			}
+			return hex.EncodeToString(h.Sum(nil)), nil
+		}
+		offset += boxSize
+	}
+	return "", fmt.Errorf("%w: %s", ErrFileCorrupt, T("err.no_moov"))
+}
+
+// gopStats считает интервалы между соседними ключевыми кадрами
+func gopStats(keyframes []time.Duration) GOPStats {
+	var stats GOPStats
+	if len(keyframes) < 2 {
+		return stats
+	}
+	for i := 1; i < len(keyframes); i++ {
+		gop := keyframes[i] - keyframes[i-1]
+		if stats.Min == 0 || gop < stats.Min {
+			stats.Min = gop
+		}
+		if gop > stats.Max {
+			stats.Max = gop
+		}
+	}
+	stats.Avg = (keyframes[len(keyframes)-1] - keyframes[0]) / time.Duration(len(keyframes)-1)
+	return stats
+}
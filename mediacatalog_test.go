@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mp4Box собирает атом MP4 с 32-битным размером
+func mp4Box(kind string, payload []byte) []byte {
+	box := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(box, uint32(8+len(payload)))
+	copy(box[4:], kind)
+	return append(box, payload...)
+}
+
+// mp4LargeBox собирает атом MP4 с 64-битным размером
+func mp4LargeBox(kind string, payload []byte) []byte {
+	box := make([]byte, 16, 16+len(payload))
+	binary.BigEndian.PutUint32(box, 1)
+	copy(box[4:], kind)
+	binary.BigEndian.PutUint64(box[8:], uint64(16+len(payload)))
+	return append(box, payload...)
+}
+
+// mp4ToEndBox собирает атом MP4 с нулевым размером (до конца файла)
+func mp4ToEndBox(kind string, payload []byte) []byte {
+	box := make([]byte, 8, 8+len(payload))
+	copy(box[4:], kind)
+	return append(box, payload...)
+}
+
+func joinBoxes(boxes ...[]byte) []byte {
+	var data []byte
+	for _, box := range boxes {
+		data = append(data, box...)
+	}
+	return data
+}
+
+func TestFileFingerprint(t *testing.T) {
+	ftyp := mp4Box("ftyp", []byte("isom"))
+	moov := mp4Box("moov", []byte("movie header"))
+	mdat := mp4Box("mdat", []byte("frames-1"))
+
+	base := joinBoxes(ftyp, moov, mdat)
+	tests := []struct {
+		name     string
+		data     []byte
+		wantSame bool // Отпечаток совпадает с отпечатком base
+		wantErr  bool
+	}{
+		{name: "same content", data: base, wantSame: true},
+		{name: "other samples of same size", data: joinBoxes(ftyp, moov, mp4Box("mdat", []byte("frames-2"))), wantSame: true},
+		{name: "other size", data: joinBoxes(ftyp, moov, mp4Box("mdat", []byte("frames-22")))},
+		{name: "other moov", data: joinBoxes(ftyp, mp4Box("moov", []byte("movie HEADER")), mdat)},
+		{name: "moov after 64-bit mdat", data: joinBoxes(ftyp, mp4LargeBox("mdat", []byte("frames")), moov)},
+		{name: "moov to end of file", data: joinBoxes(ftyp, mdat, mp4ToEndBox("moov", []byte("movie header")))},
+		{name: "no moov", data: joinBoxes(ftyp, mdat), wantErr: true},
+		{name: "truncated moov", data: joinBoxes(ftyp, mdat, moov[:len(moov)-2]), wantErr: true},
+		{name: "empty file", data: nil, wantErr: true},
+	}
+
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	want, err := fileFingerprint(write("base.mp4", base))
+	if err != nil {
+		t.Fatalf("fileFingerprint(base): %v", err)
+	}
+
+	for i, tt := range tests {
+		got, err := fileFingerprint(write(string(rune('a'+i))+".mp4", tt.data))
+		if tt.wantErr {
+			if !errors.Is(err, ErrFileCorrupt) {
+				t.Errorf("%s: error = %v, want ErrFileCorrupt", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if (got == want) != tt.wantSame {
+			t.Errorf("%s: fingerprint %s, base %s, want same = %v", tt.name, got, want, tt.wantSame)
+		}
+	}
+
+	if _, err := fileFingerprint(filepath.Join(dir, "missing.mp4")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: error = %v, want os.ErrNotExist", err)
+	}
+}
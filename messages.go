@@ -0,0 +1,708 @@
+package main
+
+// catalogs - встроенные каталоги сообщений: язык -> код события -> шаблон.
+// Коды событий стабильны и не зависят от языка; в шаблонах поля события
+// подставляются вместо {ключ}. Дополнительные языки загружаются из logging.catalogDir.
+var catalogs = map[string]map[string]string{
+	"ru": {
+		"app.start": "Запуск MP4 RTMP стримера, каналов: {channels}",
+
+		"channel.start":   "Запуск канала, отправка на {destination}, каталог видео {video_dir}",
+		"channel.stopped": "Канал остановлен",
+		"channel.fatal":   "Неустранимая ошибка, канал остановлен",
+		"channel.restart": "Сбой канала, перезапуск через {retry_in}",
+
+		"log.locale_unknown": "Неизвестный язык сообщений {locale}, используется {fallback}",
+		"log.catalog_failed": "Не удалось загрузить каталог сообщений {path}",
+		"log.file_failed":    "Не удалось открыть файл логов {path}, логи пишутся в стандартный вывод",
+
+		"config.load_failed":        "Ошибка загрузки конфигурации",
+		"config.min_play_time":      "Установлено минимальное время воспроизведения: {min_play_time}",
+		"config.force_bitrate":      "Принудительный битрейт: {bitrate_kbps} kbps",
+		"config.min_bitrate":        "Минимальный битрейт: {bitrate_kbps} kbps",
+		"config.force_keyframe":     "Принудительная генерация ключевых кадров каждые {interval_sec} сек",
+		"config.early_end_disabled": "Раннее завершение файла отключено, каждый файл будет воспроизведен до конца",
+		"config.restore_state":      "Включено восстановление состояния из предыдущей сессии",
+		"config.play_once":          "Однократное проигрывание каталога, действие по окончании: {end_action}",
+		"config.ad_breaks":          "Рекламные паузы включены, каталог рекламы: {dir}",
+
+		"catalog.waiting":     "MP4 файлы не найдены, повторная проверка через {retry_in}",
+		"catalog.found":       "Найдено {files} MP4 файлов для стриминга",
+		"catalog.file":        "Файл: {file}, размер: {size_mb} MB",
+		"catalog.changed":     "Каталог изменился, файлов: {files}, в очереди круга: {queued}",
+		"catalog.added":       "Новый файл готов к воспроизведению: {file} ({size_mb} MB)",
+		"catalog.removed":     "Файл удален из каталога: {file}",
+		"catalog.uploading":   "Обнаружен новый файл, ожидание окончания загрузки: {file}",
+		"catalog.read_failed": "Ошибка при чтении каталога видео {dir}",
+
+		"watch.started":     "Наблюдение за каталогом {dir}",
+		"watch.unavailable": "Наблюдение за каталогом {dir} недоступно, используется пересканирование",
+		"watch.error":       "Ошибка наблюдения за каталогом {dir}",
+
+		"playlist.mode":         "Режим воспроизведения: {mode}",
+		"playlist.mode_unknown": "Неизвестный режим воспроизведения {mode}, используется {fallback}",
+		"playlist.restored":     "Восстановлен порядок воспроизведения (круг #{loop}, позиция {index} из {total})",
+		"queue.restored":        "Восстановлена очередь оператора, файлов: {files}",
+
+		"state.load_failed":        "Ошибка при загрузке состояния, начинаем с начала",
+		"state.loaded":             "Загружено состояние стрима: файл {file}, позиция {position}",
+		"state.saved":              "Состояние стрима сохранено: файл {file}, позиция {position}",
+		"state.save_failed":        "Ошибка при сохранении состояния",
+		"state.stale":              "Сохраненное состояние устарело (больше недели), начинаем с начала",
+		"state.resume":             "Восстановление с файла #{index}: {file}, позиция {position}",
+		"state.file_missing":       "Не найден файл из сохраненного состояния: {file}, начинаем с первого файла",
+		"state.file_renamed":       "Файл из сохраненного состояния {from} найден по отпечатку под именем {to}",
+		"state.file_changed":       "Содержимое файла {file} изменилось, позиция {position} не используется, файл начнется сначала",
+		"state.fingerprint_failed": "Не удалось посчитать отпечаток {file}, состояние будет сверяться только по имени",
+		"status.save_failed":       "Ошибка при сохранении статуса",
+
+		"loop.start":            "Цикл стриминга #{loop}",
+		"loop.all_played":       "Все файлы проиграны",
+		"loop.restart":          "Все файлы проиграны, начинаем заново",
+		"loop.nothing_playable": "Ни один файл круга не подходит для эфира, ждем изменений в {dir}",
+
+		"file.start":            "[{index}/{total}] Начало стриминга {file}",
+		"file.resume":           "Возобновление воспроизведения {file} с позиции {position}",
+		"file.seek":             "Перемотка {file} к позиции {position}",
+		"file.position_reached": "Достигнута начальная позиция {position}, начинаем передачу",
+		"file.open":             "Открытие MP4 файла {file}",
+		"file.missing":          "Файл {file} больше не найден, пропуск",
+		"file.retry":            "Повторная попытка {attempt} из {max_attempts} для {file}",
+		"file.done":             "Стриминг файла {file} завершен (длительность: {duration})",
+		"file.prepare_next":     "Файл {file} почти закончен (длительность: {duration}), подготовка к следующему файлу",
+		"file.bitrate":          "Битрейт трансляции: {bitrate_kbps} kbps, отправлено: {sent_mb} MB",
+		"file.corrupt":          "Файл {file} поврежден",
+		"file.attempt_failed":   "Попытка {attempt}: ошибка при стриминге {file}",
+		"file.skipped":          "Файл {file} пропущен, переход к следующему файлу",
+		"file.failed":           "Все попытки стриминга файла {file} не удались, переход к следующему файлу",
+		"file.min_play_time":    "Достигнуто минимальное время воспроизведения: {min_play_time}",
+		"file.end_approaching":  "Приближается конец файла: прошло {elapsed}, позиция {position}, осталось ~{remaining}",
+		"file.early_end":        "Заблаговременное завершение трансляции после {elapsed} для подготовки следующего файла",
+		"errors.too_many":       "Слишком много ошибок подряд ({errors}), пауза на {pause} и сброс соединения",
+
+		"conn.failed":     "Ошибка подключения к RTMP серверу, повтор через {retry_in} (автомат: {breaker})",
+		"conn.lost":       "Потеря соединения с RTMP сервером, переподключение через {retry_in} (автомат: {breaker})",
+		"conn.restored":   "Соединение с RTMP сервером восстановлено",
+		"rtmp.connecting": "Подключение к RTMP серверу",
+
+		"stream.start":          "Начало синхронизированной передачи пакетов",
+		"stream.video":          "Поток #{index}: видео {codec}, {width}x{height}",
+		"stream.audio":          "Поток #{index}: аудио {codec}, {sample_rate} Гц, каналов: {channels}",
+		"stream.other":          "Поток #{index}: {codec}",
+		"stream.detected":       "Обнаружены потоки: видео={video_index}, аудио={audio_index}",
+		"stream.write_header":   "Запись заголовка потока",
+		"stream.first_video_ts": "Первый видео таймстамп: {timestamp}",
+		"stream.first_audio_ts": "Первый аудио таймстамп: {timestamp}",
+		"stream.skip_until":     "Пропуск пакетов до позиции: {timestamp}",
+		"stream.recalibrate":    "Обнаружена большая задержка ({delay}), перекалибровка",
+		"stream.progress":       "Отправлено пакетов: {packets}, битрейт: {bitrate_kbps} kbps, время: {elapsed}",
+		"stream.low_bitrate":    "Текущий битрейт ({bitrate_kbps} kbps) ниже рекомендуемого ({min_kbps} kbps)",
+		"stream.eof":            "Конец файла, стрим завершен",
+		"stream.done":           "Стриминг завершен: пакетов {packets}, длительность {duration}, битрейт {bitrate_kbps} kbps",
+
+		"repair.missing_moov": "Отсутствует атом moov в {file}, попытка исправления ({attempt}/{max_attempts})",
+		"repair.start":        "Начало исправления структуры MP4 файла {file}",
+		"repair.analyze":      "Анализ MP4 файла {file} с помощью ffmpeg",
+		"repair.problems":     "Обнаружены проблемы в файле {file}",
+		"repair.remux":        "Исправление структуры MP4 файла {file} с помощью ffmpeg",
+		"repair.saved":        "MP4 файл {file} исправлен и сохранен",
+		"repair.done":         "Структура MP4 файла {file} исправлена, повторная попытка открытия",
+		"repair.failed":       "Не удалось исправить структуру MP4 файла {file}",
+
+		"ads.break_start":    "Рекламная пауза #{event_id}, длительность {duration}",
+		"ads.break_end":      "Рекламная пауза #{event_id} завершена",
+		"ads.break_point":    "Точка рекламной паузы на позиции {position}",
+		"ads.break_failed":   "Ошибка во время рекламной паузы",
+		"ads.resume_content": "Возврат к контенту с позиции {position}",
+		"ads.clip":           "Рекламный ролик: {file}",
+		"ads.no_clips":       "Рекламные ролики не найдены, пауза только с метками",
+		"ads.dir_failed":     "Ошибка при чтении каталога рекламы {dir}",
+		"ads.marker_invalid": "Пропуск рекламной паузы для {file}",
+
+		"override.watching":       "Каталог экстренных вставок: {dir}",
+		"override.mkdir_failed":   "Не удалось создать каталог экстренных вставок {dir}",
+		"override.dropped":        "Экстренная вставка из каталога: {file}",
+		"override.cut":            "Эфир прерван для экстренной вставки: {file} на позиции {position}",
+		"override.start":          "Экстренная вставка в эфире: {file}",
+		"override.done":           "Экстренная вставка {file} завершена, длительность {duration}",
+		"override.failed":         "Ошибка при воспроизведении экстренной вставки {file}",
+		"override.resume":         "Возврат к {file} с позиции {position}",
+		"override.conn_failed":    "Ошибка соединения во время экстренной вставки",
+		"override.archive_failed": "Не удалось перенести проигранную вставку {file}",
+
+		"interstitial.start":    "Служебный ролик: {file}",
+		"interstitial.done":     "Служебный ролик {file} завершен, длительность {duration}",
+		"interstitial.failed":   "Ошибка при воспроизведении служебного ролика {file}",
+		"interstitial.missing":  "Служебный ролик {file} не найден в каталоге {dir}",
+		"interstitial.same_dir": "Каталог служебных роликов {dir} совпадает с каталогом видео, ролики попадут в обычный порядок",
+
+		"onair.opened":      "Окно вещания открыто, закроется: {next}",
+		"onair.closed":      "Вне часов вещания ({action}), окно откроется: {next}",
+		"onair.interrupted": "Файл {file} прерван на позиции {position}: окно вещания закрылось",
+		"onair.no_slate":    "Заставка вне эфира не задана, соединение закрывается до открытия окна",
+
+		"slate.on_air":         "Нет доступного контента, в эфире заставка: {file}",
+		"slate.hold":           "Удержание эфира на заставке: {file}",
+		"slate.connect_failed": "Ошибка подключения для заставки",
+		"slate.failed":         "Ошибка при воспроизведении заставки {file}",
+
+		"end.exit":     "Трансляция завершена, выход",
+		"end.wait":     "Трансляция завершена, отключение и ожидание новых файлов",
+		"end.no_slate": "Файл заставки не задан, ожидание новых файлов",
+
+		"control.command":        "Команда оператора: {command}",
+		"control.skip":           "Файл {file} пропущен по команде оператора на позиции {position}",
+		"control.interrupted":    "Файл {file} прерван командой оператора на позиции {position}",
+		"control.jump":           "Переход к файлу {file} по команде оператора",
+		"control.jump_missing":   "Файл {file} не найден в текущем круге, переход отменен",
+		"control.paused":         "Канал на паузе, в эфире заставка",
+		"control.paused_offline": "Канал на паузе, заставка не задана: соединение закрыто до снятия паузы",
+		"control.resumed":        "Пауза снята, воспроизведение продолжается",
+		"control.reload":         "Перезагрузка канала с перечитанной конфигурацией",
+		"control.reload_failed":  "Не удалось перечитать конфигурацию, канал перезапускается с прежней",
+		"control.reconnect":      "Переподключение к {destination} по команде оператора",
+
+		"dashboard.started":   "Панель оператора доступна на http://{listen}/",
+		"dashboard.failed":    "Панель оператора остановлена",
+		"dashboard.read_only": "Панель доступна в сети на {listen} без ключа dashboard.token: команды с нее отключены",
+		"dashboard.rejected":  "Команда панели {path} от {remote} отклонена",
+
+		"webhook.started":     "Вебхук {target}: события {events}",
+		"webhook.no_url":      "Вебхук {name} без адреса пропущен",
+		"webhook.delivered":   "Событие {event} доставлено на {target}, попытка {attempt}",
+		"webhook.retry":       "Не удалось доставить событие {event} на {target} (попытка {attempt} из {max_attempts}), повтор через {retry_in}",
+		"webhook.failed":      "Событие {event} не доставлено на {target}, попыток: {attempt}",
+		"webhook.lagging":     "Получатель {target} не успевает принимать события, пропущенные события берутся из буфера",
+		"webhook.events_lost": "Получатель {target} отстал больше чем на буфер событий, потеряно событий: {count}",
+		"webhook.log_failed":  "Не удалось открыть журнал доставки вебхуков {path}",
+
+		"epg.generated":    "Программа передач обновлена, передач: {programmes}",
+		"epg.failed":       "Не удалось построить программу передач",
+		"epg.write_failed": "Не удалось записать программу передач в {path}",
+
+		"media.loaded":        "Каталог медиафайлов {path}: файлов {files}",
+		"media.load_failed":   "Не удалось прочитать каталог медиафайлов {path}, он будет построен заново",
+		"media.probed":        "Проба {file}: длительность {duration}, ключевых кадров {keyframes}, средний GOP {gop_avg}",
+		"media.probe_failed":  "Не удалось выполнить пробу {file}",
+		"media.invalid":       "Файл {file} не прошел пробу ({reason})",
+		"media.renamed":       "Файл {from} переименован в {to}, результат пробы сохранен",
+		"media.saved":         "Каталог медиафайлов {path} сохранен, файлов {files}",
+		"media.save_failed":   "Не удалось сохранить каталог медиафайлов {path}",
+		"media.skip_invalid":  "Файл {file} пропущен: проба не прошла ({reason})",
+		"media.seek_keyframe": "Продолжение с ключевого кадра {keyframe} вместо позиции {position}",
+
+		"meta.invalid":       "Метаданные файла {file} не прочитаны и не используются",
+		"meta.loaded":        "Метаданные файла {file}: название {title}",
+		"meta.not_available": "Файл {file} пропущен: вне дат эфира из метаданных ({not_before} - {not_after})",
+		"meta.in_point":      "Файл {file} начинается с точки начала {position}",
+		"meta.out_point":     "Достигнута точка окончания {position}",
+
+		"asrun.started":      "Журнал эфира: каталог {dir}",
+		"asrun.write_failed": "Не удалось дописать журнал эфира {path}",
+		"asrun.hash_failed":  "Не удалось посчитать хэш файла {file} для журнала эфира",
+		"asrun.lagging":      "Журнал эфира не успевает принимать события, пропущенные события берутся из буфера",
+		"asrun.events_lost":  "Журнал эфира отстал больше чем на буфер событий, потеряно событий: {count}",
+		"asrun.usage":        "Использование: rtmp-streamer asrun [флаги]",
+		"asrun.bad_line":     "Пропущена нечитаемая строка {line} в {path}",
+		"asrun.read_failed":  "Не удалось прочитать журнал эфира",
+		"flag.asrun_from":    "первые сутки выгрузки, ГГГГ-ММ-ДД",
+		"flag.asrun_to":      "последние сутки выгрузки, ГГГГ-ММ-ДД, по умолчанию равны from",
+		"flag.asrun_channel": "выгрузить только этот канал",
+		"flag.asrun_format":  "формат выгрузки: csv или json",
+
+		"ctl.started":       "Управление через сокет {socket}",
+		"ctl.server_failed": "Сокет управления недоступен",
+		"ctl.usage":         "Использование: rtmp-streamer ctl [флаги] status|skip|goto <файл>|enqueue <файл>|play-next <файл>|override <файл>|remove <позиция>|move <позиция> <новая позиция>|pause|resume|reload",
+		"ctl.failed":        "Нет связи с процессом через {socket}",
+		"ctl.error":         "Ошибка: {error}",
+		"ctl.done":          "{command}: выполнено, канал {channel}",
+		"ctl.enqueued":      "Очередь оператора",
+		"flag.ctl_socket":   "путь к сокету управления",
+		"flag.ctl_channel":  "имя канала (можно не указывать, если канал один)",
+		"flag.ctl_json":     "вывести ответ в формате JSON",
+
+		"sim.usage":             "Использование: rtmp-streamer simulate [флаги]",
+		"sim.title":             "Канал {name}: эфир с {start} до {end}",
+		"sim.col_start":         "Начало",
+		"sim.col_duration":      "Длит.",
+		"sim.col_kind":          "Тип",
+		"sim.col_file":          "Файл",
+		"sim.col_notes":         "Примечания",
+		"sim.kind_file":         "файл",
+		"sim.kind_interstitial": "служебный",
+		"sim.kind_ad":           "реклама",
+		"sim.kind_slate":        "заставка",
+		"sim.kind_off_air":      "вне эфира",
+		"sim.kind_gap":          "пусто",
+		"sim.flag_early_end":    "раннее завершение",
+		"sim.flag_overrun":      "не помещается в окно вещания",
+		"sim.flag_skipped":      "не читается, будет пропущен",
+		"sim.flag_missing":      "ролик не найден",
+		"sim.flag_resumed":      "продолжение",
+		"sim.flag_no_files":     "каталог видео пуст",
+		"sim.flag_no_playable":  "ни один файл не подходит для эфира",
+		"sim.flag_end":          "однократное проигрывание закончено",
+		"sim.summary":           "Итого: контент {content}, служебные {interstitials}, реклама {ads}, заставка {slate}, вне эфира {off_air}, пусто {gaps}",
+		"sim.problems":          "Превышений окна: {overruns}, пропущенных файлов: {skipped}, ранних завершений: {early_ends}",
+		"flag.sim_hours":        "на сколько часов вперед строить эфир",
+		"flag.sim_start":        "начало симуляции в формате RFC 3339, по умолчанию сейчас",
+		"flag.sim_channel":      "симулировать только этот канал",
+		"flag.sim_fresh":        "не учитывать сохраненное состояние",
+		"flag.sim_json":         "вывести эфир в формате JSON",
+
+		"flag.tui":            "полноэкранный режим для терминала (логи пишутся в файл)",
+		"tui.failed":          "Не удалось запустить полноэкранный режим",
+		"tui.channel":         "канал {name}",
+		"tui.on_air":          "в эфире",
+		"tui.slate":           "заставка",
+		"tui.paused":          "пауза",
+		"tui.override":        "экстренная вставка",
+		"tui.off_air":         "вне эфира",
+		"tui.disconnected":    "нет соединения",
+		"tui.now_playing":     "Сейчас: {file}  [{index}/{total}]",
+		"tui.nothing_playing": "Ничего не воспроизводится",
+		"tui.streams":         "Видео: {video}   Аудио: {audio}",
+		"tui.bitrate":         "Битрейт: {bitrate_kbps} кбит/с",
+		"tui.destination":     "Назначение: {url}  автомат: {breaker}",
+		"tui.failures":        "ошибок подряд: {failures}",
+		"tui.up_next":         "Далее",
+		"tui.recent_errors":   "Последние ошибки",
+		"tui.keys":            "[s] пропустить  [r] переподключить  [p] пауза/продолжить  [Tab/1-9] канал  [q] выход",
+
+		"staging.started":              "Прием новых файлов через каталог {dir}, карантин: {quarantine}",
+		"staging.checking":             "Проверка нового файла: {file}",
+		"staging.accepted":             "Файл принят: {file} ({duration}, {video_codec}/{audio_codec})",
+		"staging.mkdir_failed":         "Не удалось создать входящий каталог {dir}",
+		"staging.move_failed":          "Не удалось перенести {file} в каталог видео (попытка {attempt})",
+		"staging.renamed":              "В каталоге видео уже есть {file}, новый файл принят как {to}",
+		"staging.video_codec_mismatch": "видео кодек {actual}, ожидается {expected}",
+		"staging.audio_codec_mismatch": "аудио кодек {actual}, ожидается {expected}",
+		"staging.resolution_mismatch":  "разрешение {actual}, ожидается {expected}",
+		"staging.too_short":            "длительность {duration}, минимум {min}",
+		"quarantine.moved":             "Файл {file} помещен в карантин: {reason} ({details})",
+		"quarantine.failed":            "Не удалось переместить {file} в карантин",
+		"quarantine.renamed":           "В карантине уже есть {file}, файл помещен как {to}",
+
+		"err.invalid_url":          "неверный RTMP URL",
+		"err.dial":                 "ошибка подключения к RTMP серверу",
+		"err.breaker_open":         "автомат защиты разомкнут, попытка подключения отложена",
+		"err.handshake":            "ошибка установки RTMP сессии",
+		"err.write":                "ошибка отправки данных RTMP",
+		"err.demux":                "ошибка чтения видеофайла",
+		"err.file_corrupt":         "видеофайл поврежден",
+		"err.no_streams":           "не найдены аудио или видео потоки",
+		"err.no_moov":              "нет атома moov",
+		"err.not_connected":        "нет соединения с RTMP сервером",
+		"err.write_header":         "ошибка при записи заголовка",
+		"err.flush":                "ошибка при сбросе буфера RTMP",
+		"err.write_cue":            "ошибка отправки cue point",
+		"err.write_metadata":       "ошибка отправки метаданных потока",
+		"err.meta_out_point":       "точка окончания должна быть позже точки начала",
+		"err.meta_date":            "неверная дата",
+		"err.meta_weight":          "вес не может быть отрицательным",
+		"err.timecode":             "неверный таймкод",
+		"err.open_clip":            "ошибка при открытии ролика",
+		"err.clip_streams":         "ошибка при получении потоков ролика",
+		"err.read_clip_packet":     "ошибка чтения пакета ролика",
+		"err.write_clip_packet":    "ошибка отправки пакета ролика",
+		"err.open_file":            "ошибка при открытии MP4 файла",
+		"err.streams":              "ошибка при получении потоков",
+		"err.read_packet":          "ошибка чтения пакета",
+		"err.write_initial_packet": "ошибка отправки начального пакета",
+		"err.write_packet":         "ошибка отправки пакета",
+		"err.ad_break":             "ошибка во время рекламной паузы",
+		"err.override":             "ошибка во время экстренной вставки",
+		"err.onair_time":           "неверное время окна вещания, нужно ЧЧ:ММ",
+		"err.onair_day":            "неверный день недели окна вещания, нужно mon..sun",
+		"err.onair_date":           "неверная дата исключения, нужно ГГГГ-ММ-ДД",
+		"err.onair_timezone":       "неизвестный часовой пояс окон вещания",
+		"err.onair_action":         "неверное действие вне эфира, нужно disconnect или slate",
+		"err.sim_start":            "неверное начало симуляции, нужно RFC 3339",
+		"err.asrun_date":           "неверная дата, нужно ГГГГ-ММ-ДД",
+		"err.asrun_format":         "неверный формат выгрузки, нужно csv или json",
+		"err.ffmpeg_missing":       "для исправления MP4 требуется ffmpeg, но он не найден в системе",
+		"err.remux":                "ошибка при ремонте MP4 файла",
+		"err.backup":               "ошибка при создании бэкапа оригинального файла",
+		"err.replace":              "ошибка при замене оригинального файла",
+		"err.state_marshal":        "ошибка при преобразовании состояния в JSON",
+		"err.state_write":          "ошибка при сохранении состояния в файл",
+		"err.state_read":           "ошибка при чтении файла состояния",
+		"err.state_parse":          "ошибка при разборе JSON состояния",
+		"err.status_marshal":       "ошибка при преобразовании статуса в JSON",
+		"err.status_write":         "ошибка при сохранении статуса",
+		"err.no_video_files":       "MP4 файлы не найдены в каталоге видео",
+		"err.channel_panic":        "паника в канале",
+		"err.channel_config":       "ошибка в конфигурации канала",
+		"err.channel_name":         "имя канала повторяется или содержит разделитель пути",
+		"err.channel_state_shared": "несколько каналов используют один файл состояния",
+		"err.channel_dir_shared":   "несколько каналов используют один входящий каталог или каталог экстренных вставок",
+		"err.channel_reload":       "перезагрузка канала по команде оператора",
+		"err.channel_missing":      "канал отсутствует в конфигурации",
+		"err.channel_unknown":      "неизвестный канал",
+		"err.dashboard_origin":     "команда отправлена со страницы другого сайта",
+		"err.dashboard_token":      "нужен ключ панели (dashboard.token)",
+		"err.command_unknown":      "неизвестная команда",
+		"err.command_file":         "не указан файл",
+		"err.tui_no_terminal":      "стандартный ввод не является терминалом",
+		"err.websocket_handshake":  "ожидается запрос на установку WebSocket соединения",
+		"err.websocket_frame":      "слишком большой кадр WebSocket",
+		"err.webhook_status":       "получатель вебхука ответил ошибкой",
+		"err.command_file_missing": "файл не найден в каталоге канала",
+		"err.command_jump_missing": "файла нет в текущем круге",
+		"err.ctl_socket_in_use":    "сокет уже используется другим процессом",
+		"err.ctl_not_socket":       "путь занят файлом, который не является сокетом",
+		"err.ctl_channel_required": "в процессе несколько каналов, укажите --channel",
+		"err.queue_position":       "нет такой позиции в очереди",
+	},
+
+	"en": {
+		"app.start": "Starting MP4 RTMP streamer, channels: {channels}",
+
+		"channel.start":   "Starting channel, publishing to {destination}, video directory {video_dir}",
+		"channel.stopped": "Channel stopped",
+		"channel.fatal":   "Unrecoverable error, channel stopped",
+		"channel.restart": "Channel failed, restarting in {retry_in}",
+
+		"log.locale_unknown": "Unknown message locale {locale}, using {fallback}",
+		"log.catalog_failed": "Failed to load message catalog {path}",
+		"log.file_failed":    "Failed to open log file {path}, logging to standard output",
+
+		"config.load_failed":        "Failed to load configuration",
+		"config.min_play_time":      "Minimum play time set to {min_play_time}",
+		"config.force_bitrate":      "Forced bitrate: {bitrate_kbps} kbps",
+		"config.min_bitrate":        "Minimum bitrate: {bitrate_kbps} kbps",
+		"config.force_keyframe":     "Forcing keyframes every {interval_sec} s",
+		"config.early_end_disabled": "Early end disabled, every file plays to the end",
+		"config.restore_state":      "Restoring state from the previous session is enabled",
+		"config.play_once":          "Playing the directory once, end action: {end_action}",
+		"config.ad_breaks":          "Ad breaks enabled, ad directory: {dir}",
+
+		"catalog.waiting":     "No MP4 files found, checking again in {retry_in}",
+		"catalog.found":       "Found {files} MP4 files to stream",
+		"catalog.file":        "File: {file}, size: {size_mb} MB",
+		"catalog.changed":     "Directory changed, files: {files}, queued in this loop: {queued}",
+		"catalog.added":       "New file ready to play: {file} ({size_mb} MB)",
+		"catalog.removed":     "File removed from directory: {file}",
+		"catalog.uploading":   "New file detected, waiting for upload to finish: {file}",
+		"catalog.read_failed": "Failed to read video directory {dir}",
+
+		"watch.started":     "Watching directory {dir}",
+		"watch.unavailable": "Watching directory {dir} is unavailable, falling back to rescanning",
+		"watch.error":       "Directory watch error in {dir}",
+
+		"playlist.mode":         "Playback mode: {mode}",
+		"playlist.mode_unknown": "Unknown playback mode {mode}, using {fallback}",
+		"playlist.restored":     "Restored playback order (loop #{loop}, position {index} of {total})",
+		"queue.restored":        "Restored operator queue, files: {files}",
+
+		"state.load_failed":        "Failed to load state, starting from the beginning",
+		"state.loaded":             "Loaded stream state: file {file}, position {position}",
+		"state.saved":              "Stream state saved: file {file}, position {position}",
+		"state.save_failed":        "Failed to save state",
+		"state.stale":              "Saved state is older than a week, starting from the beginning",
+		"state.resume":             "Resuming from file #{index}: {file}, position {position}",
+		"state.file_missing":       "File from saved state not found: {file}, starting from the first file",
+		"state.file_renamed":       "File {from} from saved state found by fingerprint as {to}",
+		"state.file_changed":       "Content of {file} has changed, ignoring position {position} and starting it from the beginning",
+		"state.fingerprint_failed": "Failed to fingerprint {file}, state will be matched by name only",
+		"status.save_failed":       "Failed to save status file",
+
+		"loop.start":            "Streaming loop #{loop}",
+		"loop.all_played":       "All files played",
+		"loop.restart":          "All files played, starting over",
+		"loop.nothing_playable": "No file in the loop can go on air, waiting for changes in {dir}",
+
+		"file.start":            "[{index}/{total}] Streaming {file}",
+		"file.resume":           "Resuming {file} from position {position}",
+		"file.seek":             "Seeking {file} to position {position}",
+		"file.position_reached": "Reached start position {position}, sending",
+		"file.open":             "Opening MP4 file {file}",
+		"file.missing":          "File {file} no longer exists, skipping",
+		"file.retry":            "Retry {attempt} of {max_attempts} for {file}",
+		"file.done":             "Finished streaming {file} (duration: {duration})",
+		"file.prepare_next":     "File {file} almost finished (duration: {duration}), preparing the next file",
+		"file.bitrate":          "Stream bitrate: {bitrate_kbps} kbps, sent: {sent_mb} MB",
+		"file.corrupt":          "File {file} is corrupt",
+		"file.attempt_failed":   "Attempt {attempt}: streaming {file} failed",
+		"file.skipped":          "File {file} skipped, moving on to the next file",
+		"file.failed":           "All attempts to stream {file} failed, moving on to the next file",
+		"file.min_play_time":    "Minimum play time reached: {min_play_time}",
+		"file.end_approaching":  "End of file approaching: elapsed {elapsed}, position {position}, ~{remaining} left",
+		"file.early_end":        "Ending the file early after {elapsed} to prepare the next one",
+		"errors.too_many":       "Too many consecutive errors ({errors}), pausing for {pause} and resetting the connection",
+
+		"conn.failed":     "Failed to connect to the RTMP server, retrying in {retry_in} (breaker: {breaker})",
+		"conn.lost":       "Lost connection to the RTMP server, reconnecting in {retry_in} (breaker: {breaker})",
+		"conn.restored":   "Connection to the RTMP server restored",
+		"rtmp.connecting": "Connecting to the RTMP server",
+
+		"stream.start":          "Starting synchronized packet delivery",
+		"stream.video":          "Stream #{index}: video {codec}, {width}x{height}",
+		"stream.audio":          "Stream #{index}: audio {codec}, {sample_rate} Hz, {channels} channels",
+		"stream.other":          "Stream #{index}: {codec}",
+		"stream.detected":       "Detected streams: video={video_index}, audio={audio_index}",
+		"stream.write_header":   "Writing stream header",
+		"stream.first_video_ts": "First video timestamp: {timestamp}",
+		"stream.first_audio_ts": "First audio timestamp: {timestamp}",
+		"stream.skip_until":     "Skipping packets until position {timestamp}",
+		"stream.recalibrate":    "Large delay detected ({delay}), recalibrating",
+		"stream.progress":       "Packets sent: {packets}, bitrate: {bitrate_kbps} kbps, elapsed: {elapsed}",
+		"stream.low_bitrate":    "Current bitrate ({bitrate_kbps} kbps) is below the recommended {min_kbps} kbps",
+		"stream.eof":            "End of file, stream finished",
+		"stream.done":           "Streaming finished: {packets} packets, duration {duration}, bitrate {bitrate_kbps} kbps",
+
+		"repair.missing_moov": "Missing moov atom in {file}, attempting repair ({attempt}/{max_attempts})",
+		"repair.start":        "Repairing MP4 structure of {file}",
+		"repair.analyze":      "Analyzing {file} with ffmpeg",
+		"repair.problems":     "Problems found in {file}",
+		"repair.remux":        "Remuxing {file} with ffmpeg",
+		"repair.saved":        "Repaired MP4 file {file} saved",
+		"repair.done":         "MP4 structure of {file} repaired, reopening",
+		"repair.failed":       "Failed to repair MP4 structure of {file}",
+
+		"ads.break_start":    "Ad break #{event_id}, duration {duration}",
+		"ads.break_end":      "Ad break #{event_id} finished",
+		"ads.break_point":    "Ad break point at position {position}",
+		"ads.break_failed":   "Error during ad break",
+		"ads.resume_content": "Returning to content at position {position}",
+		"ads.clip":           "Ad clip: {file}",
+		"ads.no_clips":       "No ad clips found, break is signalled with cue points only",
+		"ads.dir_failed":     "Failed to read ad directory {dir}",
+		"ads.marker_invalid": "Skipping ad break marker for {file}",
+
+		"override.watching":       "Emergency override directory: {dir}",
+		"override.mkdir_failed":   "Failed to create emergency override directory {dir}",
+		"override.dropped":        "Emergency override dropped into directory: {file}",
+		"override.cut":            "Interrupting {file} at position {position} for emergency override",
+		"override.start":          "Emergency override on air: {file}",
+		"override.done":           "Emergency override {file} finished, duration {duration}",
+		"override.failed":         "Error playing emergency override {file}",
+		"override.resume":         "Resuming {file} from position {position}",
+		"override.conn_failed":    "Connection error during emergency override",
+		"override.archive_failed": "Failed to move played override {file}",
+
+		"interstitial.start":    "Interstitial: {file}",
+		"interstitial.done":     "Interstitial {file} finished, duration {duration}",
+		"interstitial.failed":   "Error playing interstitial {file}",
+		"interstitial.missing":  "Interstitial {file} not found in directory {dir}",
+		"interstitial.same_dir": "Interstitial directory {dir} is the video directory, clips will also play in normal rotation",
+
+		"onair.opened":      "On-air window opened, closes at {next}",
+		"onair.closed":      "Outside on-air hours ({action}), window opens at {next}",
+		"onair.interrupted": "File {file} interrupted at position {position}: on-air window closed",
+		"onair.no_slate":    "No off-air slate configured, disconnecting until the window opens",
+
+		"slate.on_air":         "No content available, slate on air: {file}",
+		"slate.hold":           "Holding the channel on slate: {file}",
+		"slate.connect_failed": "Failed to connect for slate",
+		"slate.failed":         "Failed to play slate {file}",
+
+		"end.exit":     "Playback finished, exiting",
+		"end.wait":     "Playback finished, disconnecting and waiting for new files",
+		"end.no_slate": "No slate file configured, waiting for new files",
+
+		"control.command":        "Operator command: {command}",
+		"control.skip":           "File {file} skipped by operator at position {position}",
+		"control.interrupted":    "File {file} interrupted by operator at position {position}",
+		"control.jump":           "Jumping to {file} by operator command",
+		"control.jump_missing":   "File {file} is not in the current loop, jump cancelled",
+		"control.paused":         "Channel paused, slate on air",
+		"control.paused_offline": "Channel paused and no slate configured: connection closed until resumed",
+		"control.resumed":        "Pause lifted, playback continues",
+		"control.reload":         "Reloading channel with re-read configuration",
+		"control.reload_failed":  "Failed to re-read configuration, restarting channel with the previous one",
+		"control.reconnect":      "Reconnecting to {destination} by operator command",
+
+		"dashboard.started":   "Operator dashboard available at http://{listen}/",
+		"dashboard.failed":    "Operator dashboard stopped",
+		"dashboard.read_only": "Dashboard is exposed on {listen} without dashboard.token: commands are disabled",
+		"dashboard.rejected":  "Rejected dashboard command {path} from {remote}",
+
+		"webhook.started":     "Webhook {target}: events {events}",
+		"webhook.no_url":      "Webhook {name} has no URL, skipped",
+		"webhook.delivered":   "Event {event} delivered to {target}, attempt {attempt}",
+		"webhook.retry":       "Failed to deliver event {event} to {target} (attempt {attempt} of {max_attempts}), retrying in {retry_in}",
+		"webhook.failed":      "Event {event} was not delivered to {target} after {attempt} attempts",
+		"webhook.lagging":     "Receiver {target} cannot keep up with events, catching up from the replay buffer",
+		"webhook.events_lost": "Receiver {target} fell behind the event buffer, events lost: {count}",
+		"webhook.log_failed":  "Failed to open webhook delivery log {path}",
+
+		"epg.generated":    "Program guide updated, programmes: {programmes}",
+		"epg.failed":       "Failed to build the program guide",
+		"epg.write_failed": "Failed to write the program guide to {path}",
+
+		"media.loaded":        "Media catalog {path}: {files} files",
+		"media.load_failed":   "Failed to read media catalog {path}, it will be rebuilt",
+		"media.probed":        "Probed {file}: duration {duration}, keyframes {keyframes}, average GOP {gop_avg}",
+		"media.probe_failed":  "Failed to probe {file}",
+		"media.invalid":       "File {file} failed the probe ({reason})",
+		"media.renamed":       "File {from} renamed to {to}, probe result kept",
+		"media.saved":         "Media catalog {path} saved, {files} files",
+		"media.save_failed":   "Failed to save media catalog {path}",
+		"media.skip_invalid":  "Skipping {file}: it failed the probe ({reason})",
+		"media.seek_keyframe": "Resuming from keyframe {keyframe} instead of position {position}",
+
+		"meta.invalid":       "Failed to read metadata of {file}, it is ignored",
+		"meta.loaded":        "Metadata of {file}: title {title}",
+		"meta.not_available": "Skipping {file}: outside of its metadata air dates ({not_before} - {not_after})",
+		"meta.in_point":      "Starting {file} at its in point {position}",
+		"meta.out_point":     "Reached out point {position}",
+
+		"asrun.started":      "As-run log: directory {dir}",
+		"asrun.write_failed": "Failed to append to the as-run log {path}",
+		"asrun.hash_failed":  "Failed to hash file {file} for the as-run log",
+		"asrun.lagging":      "As-run log cannot keep up with events, catching up from the replay buffer",
+		"asrun.events_lost":  "As-run log fell behind the event buffer, events lost: {count}",
+		"asrun.usage":        "Usage: rtmp-streamer asrun [flags]",
+		"asrun.bad_line":     "Skipped unreadable line {line} in {path}",
+		"asrun.read_failed":  "Failed to read the as-run log",
+		"flag.asrun_from":    "first day to export, YYYY-MM-DD",
+		"flag.asrun_to":      "last day to export, YYYY-MM-DD, defaults to from",
+		"flag.asrun_channel": "export only this channel",
+		"flag.asrun_format":  "export format: csv or json",
+
+		"ctl.started":       "Control socket {socket}",
+		"ctl.server_failed": "Control socket is unavailable",
+		"ctl.usage":         "Usage: rtmp-streamer ctl [flags] status|skip|goto <file>|enqueue <file>|play-next <file>|override <file>|remove <position>|move <position> <new position>|pause|resume|reload",
+		"ctl.failed":        "Cannot reach the process via {socket}",
+		"ctl.error":         "Error: {error}",
+		"ctl.done":          "{command}: done, channel {channel}",
+		"ctl.enqueued":      "Operator queue",
+		"flag.ctl_socket":   "path to the control socket",
+		"flag.ctl_channel":  "channel name (optional with a single channel)",
+		"flag.ctl_json":     "print the response as JSON",
+
+		"sim.usage":             "Usage: rtmp-streamer simulate [flags]",
+		"sim.title":             "Channel {name}: airing from {start} to {end}",
+		"sim.col_start":         "Start",
+		"sim.col_duration":      "Length",
+		"sim.col_kind":          "Kind",
+		"sim.col_file":          "File",
+		"sim.col_notes":         "Notes",
+		"sim.kind_file":         "file",
+		"sim.kind_interstitial": "interstitial",
+		"sim.kind_ad":           "ad",
+		"sim.kind_slate":        "slate",
+		"sim.kind_off_air":      "off air",
+		"sim.kind_gap":          "gap",
+		"sim.flag_early_end":    "early end",
+		"sim.flag_overrun":      "overruns the on-air window",
+		"sim.flag_skipped":      "unreadable, will be skipped",
+		"sim.flag_missing":      "clip not found",
+		"sim.flag_resumed":      "resumed",
+		"sim.flag_no_files":     "video directory is empty",
+		"sim.flag_no_playable":  "no file can go on air",
+		"sim.flag_end":          "play-once finished",
+		"sim.summary":           "Total: content {content}, interstitials {interstitials}, ads {ads}, slate {slate}, off air {off_air}, gaps {gaps}",
+		"sim.problems":          "Window overruns: {overruns}, skipped files: {skipped}, early ends: {early_ends}",
+		"flag.sim_hours":        "how many hours ahead to simulate",
+		"flag.sim_start":        "simulation start in RFC 3339 format, now by default",
+		"flag.sim_channel":      "simulate only this channel",
+		"flag.sim_fresh":        "ignore the saved state",
+		"flag.sim_json":         "print the timeline as JSON",
+
+		"flag.tui":            "full-screen terminal mode (logs go to a file)",
+		"tui.failed":          "Failed to start full-screen mode",
+		"tui.channel":         "channel {name}",
+		"tui.on_air":          "on air",
+		"tui.slate":           "slate",
+		"tui.paused":          "paused",
+		"tui.override":        "override",
+		"tui.off_air":         "off air",
+		"tui.disconnected":    "disconnected",
+		"tui.now_playing":     "Now: {file}  [{index}/{total}]",
+		"tui.nothing_playing": "Nothing playing",
+		"tui.streams":         "Video: {video}   Audio: {audio}",
+		"tui.bitrate":         "Bitrate: {bitrate_kbps} kbps",
+		"tui.destination":     "Destination: {url}  breaker: {breaker}",
+		"tui.failures":        "consecutive failures: {failures}",
+		"tui.up_next":         "Up next",
+		"tui.recent_errors":   "Recent errors",
+		"tui.keys":            "[s] skip  [r] reconnect  [p] pause/resume  [Tab/1-9] channel  [q] quit",
+
+		"staging.started":              "Accepting new files from {dir}, quarantine: {quarantine}",
+		"staging.checking":             "Checking new file: {file}",
+		"staging.accepted":             "File accepted: {file} ({duration}, {video_codec}/{audio_codec})",
+		"staging.mkdir_failed":         "Failed to create incoming directory {dir}",
+		"staging.move_failed":          "Failed to move {file} into the video directory (attempt {attempt})",
+		"staging.renamed":              "{file} already exists in the video directory, accepting the new file as {to}",
+		"staging.video_codec_mismatch": "video codec {actual}, expected {expected}",
+		"staging.audio_codec_mismatch": "audio codec {actual}, expected {expected}",
+		"staging.resolution_mismatch":  "resolution {actual}, expected {expected}",
+		"staging.too_short":            "duration {duration}, minimum {min}",
+		"quarantine.moved":             "File {file} quarantined: {reason} ({details})",
+		"quarantine.failed":            "Failed to move {file} to quarantine",
+		"quarantine.renamed":           "{file} is already in quarantine, quarantining the file as {to}",
+
+		"err.invalid_url":          "invalid RTMP URL",
+		"err.dial":                 "failed to connect to the RTMP server",
+		"err.breaker_open":         "circuit breaker is open, connection attempt deferred",
+		"err.handshake":            "failed to establish the RTMP session",
+		"err.write":                "failed to send RTMP data",
+		"err.demux":                "failed to read the video file",
+		"err.file_corrupt":         "video file is corrupt",
+		"err.no_streams":           "no audio or video streams found",
+		"err.no_moov":              "no moov atom",
+		"err.not_connected":        "not connected to the RTMP server",
+		"err.write_header":         "failed to write header",
+		"err.flush":                "failed to flush the RTMP buffer",
+		"err.write_cue":            "failed to send cue point",
+		"err.write_metadata":       "failed to send stream metadata",
+		"err.meta_out_point":       "out point must be after in point",
+		"err.meta_date":            "invalid date",
+		"err.meta_weight":          "weight must not be negative",
+		"err.timecode":             "invalid timecode",
+		"err.open_clip":            "failed to open clip",
+		"err.clip_streams":         "failed to read clip streams",
+		"err.read_clip_packet":     "failed to read clip packet",
+		"err.write_clip_packet":    "failed to send clip packet",
+		"err.open_file":            "failed to open MP4 file",
+		"err.streams":              "failed to read streams",
+		"err.read_packet":          "failed to read packet",
+		"err.write_initial_packet": "failed to send initial packet",
+		"err.write_packet":         "failed to send packet",
+		"err.ad_break":             "error during ad break",
+		"err.override":             "error during emergency override",
+		"err.onair_time":           "invalid on-air window time, expected HH:MM",
+		"err.onair_day":            "invalid on-air window weekday, expected mon..sun",
+		"err.onair_date":           "invalid exception date, expected YYYY-MM-DD",
+		"err.onair_timezone":       "unknown on-air time zone",
+		"err.onair_action":         "invalid off-air action, expected disconnect or slate",
+		"err.sim_start":            "invalid simulation start, expected RFC 3339",
+		"err.asrun_date":           "invalid date, expected YYYY-MM-DD",
+		"err.asrun_format":         "invalid export format, expected csv or json",
+		"err.ffmpeg_missing":       "repairing MP4 requires ffmpeg, but it was not found",
+		"err.remux":                "failed to repair MP4 file",
+		"err.backup":               "failed to back up the original file",
+		"err.replace":              "failed to replace the original file",
+		"err.state_marshal":        "failed to encode state as JSON",
+		"err.state_write":          "failed to write state file",
+		"err.state_read":           "failed to read state file",
+		"err.state_parse":          "failed to parse state JSON",
+		"err.status_marshal":       "failed to encode status as JSON",
+		"err.status_write":         "failed to write status file",
+		"err.no_video_files":       "no MP4 files found in video directory",
+		"err.channel_panic":        "channel panicked",
+		"err.channel_config":       "invalid channel configuration",
+		"err.channel_name":         "channel name is duplicated or contains a path separator",
+		"err.channel_state_shared": "several channels share one state file",
+		"err.channel_dir_shared":   "several channels share one incoming or override directory",
+		"err.channel_reload":       "channel reload requested by operator",
+		"err.channel_missing":      "channel is missing from the configuration",
+		"err.channel_unknown":      "unknown channel",
+		"err.dashboard_origin":     "command sent from another site's page",
+		"err.dashboard_token":      "dashboard token required (dashboard.token)",
+		"err.command_unknown":      "unknown command",
+		"err.command_file":         "file is not specified",
+		"err.tui_no_terminal":      "standard input is not a terminal",
+		"err.websocket_handshake":  "WebSocket upgrade request expected",
+		"err.websocket_frame":      "WebSocket frame is too large",
+		"err.webhook_status":       "webhook receiver responded with an error",
+		"err.command_file_missing": "file not found in the channel directory",
+		"err.command_jump_missing": "file is not in the current loop",
+		"err.ctl_socket_in_use":    "socket is already used by another process",
+		"err.ctl_not_socket":       "path is taken by a file that is not a socket",
+		"err.ctl_channel_required": "the process runs several channels, specify --channel",
+		"err.queue_position":       "no such position in the queue",
+	},
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	monitorBitrateSamples = 300 // Сколько секундных замеров битрейта хранить для графика
+	monitorEventHistory   = 100 // Сколько последних событий ошибок и переподключений хранить
+	monitorQueueLength    = 10  // Сколько следующих файлов показывать в очереди
+)
+
+// BitrateSample - замер битрейта сессии
+type BitrateSample struct {
+	Time    time.Time `json:"time"`
+	Bitrate int64     `json:"bitrate"`
+}
+
+// MonitorEvent - событие из истории ошибок и переподключений
+type MonitorEvent struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Event   string    `json:"event"`
+	Message string    `json:"message"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// DestinationHealth - состояние соединения с RTMP сервером
+type DestinationHealth struct {
+	URL       string          `json:"url"` // Адрес сервера без ключа потока
+	Connected bool            `json:"connected"`
+	Breaker   BreakerSnapshot `json:"breaker"`
+}
+
+// MonitorSnapshot - состояние канала для панели оператора. Время в секундах.
+type MonitorSnapshot struct {
+	Channel        string            `json:"channel"`
+	NowPlaying     string            `json:"nowPlaying"`
+	Index          int               `json:"index"`
+	Total          int               `json:"total"`
+	Position       float64           `json:"position"`
+	Duration       float64           `json:"duration"`
+	VideoProgress  float64           `json:"videoProgress"` // Прогресс видео потока по таймстампам
+	AudioProgress  float64           `json:"audioProgress"` // Прогресс аудио потока по таймстампам
+	Queue          []string          `json:"queue"`         // Следующие файлы с учетом очереди оператора
+	Enqueued       []string          `json:"enqueued"`      // Очередь оператора, идет раньше обычного порядка
+	Files          []string          `json:"files"`
+	OnSlate        bool              `json:"onSlate"`
+	Override       string            `json:"override,omitempty"` // Экстренная вставка в эфире
+	Paused         bool              `json:"paused"`
+	OffAir         bool              `json:"offAir"` // Вне часов вещания
+	Bitrate        int64             `json:"bitrate"`
+	BitrateHistory []BitrateSample   `json:"bitrateHistory"`
+	Events         []MonitorEvent    `json:"events"`
+	Destination    DestinationHealth `json:"destination"`
+}
+
+// ChannelMonitor собирает живое состояние канала, которое читает панель оператора.
+// Цикл трансляции обновляет его из своей горутины, HTTP обработчики читают снимки.
+type ChannelMonitor struct {
+	mu       sync.Mutex
+	snapshot MonitorSnapshot
+	breaker  *CircuitBreaker
+}
+
+// NewChannelMonitor создает монитор канала
+func NewChannelMonitor(name string) *ChannelMonitor {
+	return &ChannelMonitor{snapshot: MonitorSnapshot{Channel: name}}
+}
+
+// Snapshot возвращает копию текущего состояния
+func (m *ChannelMonitor) Snapshot() MonitorSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := m.snapshot
+	s.Queue = append([]string(nil), s.Queue...)
+	s.Files = append([]string(nil), s.Files...)
+	s.BitrateHistory = append([]BitrateSample(nil), s.BitrateHistory...)
+	s.Events = append([]MonitorEvent(nil), s.Events...)
+	if m.breaker != nil {
+		s.Destination.Breaker = m.breaker.Snapshot()
+	}
+	return s
+}
+
+// SetDestination задает адрес RTMP сервера канала
+func (m *ChannelMonitor) SetDestination(url string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.Destination.URL = url
+}
+
+// SetNowPlaying отмечает начало воспроизведения файла
+func (m *ChannelMonitor) SetNowPlaying(name string, index, total int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.NowPlaying = name
+	m.snapshot.Index = index
+	m.snapshot.Total = total
+	m.snapshot.Duration = duration.Seconds()
+	m.snapshot.Position = 0
+	m.snapshot.VideoProgress = 0
+	m.snapshot.AudioProgress = 0
+}
+
+// SetPlaylist обновляет очередь следующих файлов и список файлов текущего круга
+func (m *ChannelMonitor) SetPlaylist(queue, files []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.Queue = queue
+	m.snapshot.Files = files
+}
+
+// SetPosition обновляет позицию внутри текущего файла
+func (m *ChannelMonitor) SetPosition(position time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.Position = position.Seconds()
+}
+
+// SetStreamProgress обновляет прогресс аудио и видео потоков текущего файла
+func (m *ChannelMonitor) SetStreamProgress(video, audio time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.VideoProgress = video.Seconds()
+	m.snapshot.AudioProgress = audio.Seconds()
+}
+
+// SetSlate отмечает, что в эфире заставка
+func (m *ChannelMonitor) SetSlate(onSlate bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.OnSlate = onSlate
+}
+
+// SetOverride отмечает экстренную вставку в эфире; пустое имя - вставка закончилась
+func (m *ChannelMonitor) SetOverride(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.Override = name
+}
+
+// SetConnected отмечает установку или потерю соединения с RTMP сервером
+func (m *ChannelMonitor) SetConnected(connected bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.Destination.Connected = connected
+}
+
+// SetBreaker задает автомат защиты соединения, состояние которого попадает в снимок
+func (m *ChannelMonitor) SetBreaker(breaker *CircuitBreaker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.breaker = breaker
+}
+
+// AddBitrateSample добавляет замер битрейта в историю для графика
+func (m *ChannelMonitor) AddBitrateSample(bitrate int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.Bitrate = bitrate
+	m.snapshot.BitrateHistory = append(m.snapshot.BitrateHistory, BitrateSample{Time: time.Now(), Bitrate: bitrate})
+	if n := len(m.snapshot.BitrateHistory); n > monitorBitrateSamples {
+		m.snapshot.BitrateHistory = m.snapshot.BitrateHistory[n-monitorBitrateSamples:]
+	}
+}
+
+// RecordEvent добавляет в историю предупреждения, ошибки и восстановление соединения.
+// Вызывается логгером канала для каждой записи.
+func (m *ChannelMonitor) RecordEvent(level slog.Level, code, message string, args []any) {
+	if level < slog.LevelWarn && code != "conn.restored" {
+		return
+	}
+
+	event := MonitorEvent{Time: time.Now(), Level: level.String(), Event: code, Message: message}
+	for i := 0; i+1 < len(args); i += 2 {
+		if key, ok := args[i].(string); ok && key == "error" {
+			event.Error = formatLogValue(args[i+1])
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshot.Events = append(m.snapshot.Events, event)
+	if n := len(m.snapshot.Events); n > monitorEventHistory {
+		m.snapshot.Events = m.snapshot.Events[n-monitorEventHistory:]
+	}
+}
+
+// Ошибки команд оператора
+var (
+	errCommandUnknown = newCodedError("err.command_unknown")
+	errCommandFile    = newCodedError("err.command_file")
+	errFileNotFound   = newCodedError("err.command_file_missing")
+	errJumpNotInLoop  = newCodedError("err.command_jump_missing")
+)
+
+// channelControl хранит команды оператора, которые цикл трансляции забирает между пакетами
+type channelControl struct {
+	mu        sync.Mutex
+	skip      bool
+	jumpTo    string
+	paused    bool
+	reload    bool
+	reconnect bool
+	overrides []OverrideItem // Экстренные вставки, ожидающие эфира
+	offAir    bool           // Вне часов вещания
+	loop      []string       // Порядок текущего круга, к этим файлам возможен переход
+}
+
+// OperatorCommand - команда оператора с аргументами
+type OperatorCommand struct {
+	Name     string `json:"command"`
+	File     string `json:"file,omitempty"`     // Файл для jump, enqueue, play-next и override
+	Position int    `json:"position,omitempty"` // Позиция в очереди с 1 для remove и move
+	To       int    `json:"to,omitempty"`       // Новая позиция для move
+}
+
+// Command выполняет команду оператора: skip, jump, enqueue, play-next, remove, move, override,
+// pause, resume, reconnect, reload. Для enqueue и play-next нужен файл из каталога канала,
+// для jump - из текущего круга, для override - из каталога канала или из проигранных вставок
+// каталога override.
+func (ch *Channel) Command(command OperatorCommand) error {
+	switch command.Name {
+	case "jump", "enqueue", "play-next":
+		if command.File == "" {
+			return errCommandFile
+		}
+		if files := ch.Monitor.Snapshot().Files; len(files) > 0 && !containsString(files, command.File) {
+			return fmt.Errorf("%w: %s", errFileNotFound, command.File)
+		}
+		switch command.Name {
+		case "jump":
+			if !ch.inLoop(command.File) {
+				return fmt.Errorf("%w: %s", errJumpNotInLoop, command.File)
+			}
+			ch.Jump(command.File)
+		case "enqueue":
+			ch.Queue.Add(command.File)
+		default:
+			ch.Queue.PlayNext(command.File)
+		}
+	case "override":
+		if command.File == "" {
+			return errCommandFile
+		}
+		path, err := ch.overridePath(command.File)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, command.File)
+		}
+		ch.Override(OverrideItem{Path: path})
+	case "remove":
+		return ch.Queue.Remove(command.Position)
+	case "move":
+		return ch.Queue.Move(command.Position, command.To)
+	case "skip":
+		ch.Skip()
+	case "pause":
+		ch.Pause()
+	case "resume":
+		ch.Resume()
+	case "reconnect":
+		ch.Reconnect()
+	case "reload":
+		ch.Reload()
+	default:
+		return fmt.Errorf("%w: %s", errCommandUnknown, command.Name)
+	}
+	return nil
+}
+
+// Skip завершает текущий файл и переходит к следующему
+func (ch *Channel) Skip() {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	ch.control.skip = true
+}
+
+// Jump переходит к указанному файлу текущего круга
+func (ch *Channel) Jump(name string) {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	ch.control.jumpTo = name
+}
+
+// Pause ставит канал на паузу: в эфир выходит заставка до вызова Resume
+func (ch *Channel) Pause() {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	ch.control.paused = true
+}
+
+// Resume снимает паузу, воспроизведение продолжается с той же позиции
+func (ch *Channel) Resume() {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	ch.control.paused = false
+}
+
+// Reload перезапускает канал с перечитанной конфигурацией
+func (ch *Channel) Reload() {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	ch.control.reload = true
+}
+
+// Reconnect переустанавливает соединение с RTMP сервером, файл продолжается с той же позиции
+func (ch *Channel) Reconnect() {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	ch.control.reconnect = true
+}
+
+// Paused сообщает, стоит ли канал на паузе
+func (ch *Channel) Paused() bool {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	return ch.control.paused
+}
+
+// OffAir сообщает, что канал вне часов вещания
+func (ch *Channel) OffAir() bool {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	return ch.control.offAir
+}
+
+// setOffAir переводит канал в режим вне эфира и обратно по расписанию вещания
+func (ch *Channel) setOffAir(offAir bool) {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	ch.control.offAir = offAir
+}
+
+// interrupted сообщает, что текущий файл нужно прервать по команде оператора
+func (ch *Channel) interrupted() bool {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	return ch.control.skip || ch.control.jumpTo != "" || ch.control.paused || ch.control.reload || ch.control.reconnect ||
+		ch.control.offAir
+}
+
+// takeSkip забирает команду пропуска файла
+func (ch *Channel) takeSkip() bool {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	skip := ch.control.skip
+	ch.control.skip = false
+	return skip
+}
+
+// setLoop запоминает порядок текущего круга для проверки команды jump
+func (ch *Channel) setLoop(order []string) {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	ch.control.loop = order
+}
+
+// inLoop сообщает, есть ли файл в текущем круге
+func (ch *Channel) inLoop(name string) bool {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	return containsString(ch.control.loop, name)
+}
+
+// takeJump забирает команду перехода к файлу
+func (ch *Channel) takeJump() string {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	name := ch.control.jumpTo
+	ch.control.jumpTo = ""
+	return name
+}
+
+// takeReload забирает команду перезагрузки
+func (ch *Channel) takeReload() bool {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	reload := ch.control.reload
+	ch.control.reload = false
+	return reload
+}
+
+// takeReconnect забирает команду переподключения
+func (ch *Channel) takeReconnect() bool {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	reconnect := ch.control.reconnect
+	ch.control.reconnect = false
+	return reconnect
+}
+
+// reloadPending сообщает о команде перезагрузки, не забирая ее
+func (ch *Channel) reloadPending() bool {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	return ch.control.reload
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChannelCommandJump(t *testing.T) {
+	tests := []struct {
+		name    string
+		loop    []string
+		file    string
+		wantErr error
+	}{
+		{name: "file in loop", loop: []string{"a.mp4", "b.mp4"}, file: "b.mp4"},
+		{name: "file not in loop", loop: []string{"a.mp4"}, file: "b.mp4", wantErr: errJumpNotInLoop},
+		{name: "loop finished", file: "a.mp4", wantErr: errJumpNotInLoop},
+		{name: "no file", loop: []string{"a.mp4"}, wantErr: errCommandFile},
+	}
+	for _, tt := range tests {
+		ch := NewChannel(&Config{Name: "test"}, nil)
+		ch.setLoop(tt.loop)
+		err := ch.Command(OperatorCommand{Name: "jump", File: tt.file})
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: Command() = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		want := tt.file
+		if tt.wantErr != nil {
+			want = ""
+		}
+		if got := ch.takeJump(); got != want {
+			t.Errorf("%s: takeJump() = %q, want %q", tt.name, got, want)
+		}
+	}
+}
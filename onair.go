@@ -0,0 +1,301 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Действия вне часов вещания
+const (
+	OffAirDisconnect = "disconnect" // Закрыть соединение до открытия окна
+	OffAirSlate      = "slate"      // Держать соединение и показывать заставку вне эфира
+)
+
+const (
+	onAirCheckInterval = time.Second    // Как часто проверяется окно вещания
+	onAirSearchDays    = 8              // На сколько суток вперед искать следующее изменение окна
+	onAirDateLayout    = "2006-01-02"   // Формат даты исключения
+	onAirDay           = 24 * time.Hour // Длина суток для окон через полночь
+)
+
+// Ошибки разбора расписания вещания
+var (
+	errOnAirTime     = newCodedError("err.onair_time")
+	errOnAirDay      = newCodedError("err.onair_day")
+	errOnAirDate     = newCodedError("err.onair_date")
+	errOnAirTimezone = newCodedError("err.onair_timezone")
+	errOnAirAction   = newCodedError("err.onair_action")
+)
+
+// OnAirWindow - окно вещания внутри суток. Окно с концом раньше начала
+// продолжается до указанного времени следующих суток.
+type OnAirWindow struct {
+	Days  []string `json:"days,omitempty"` // mon..sun, пусто = каждый день (для исключений не используется)
+	Start string   `json:"start"`          // Начало, ЧЧ:ММ
+	End   string   `json:"end"`            // Конец, ЧЧ:ММ; 24:00 или равный началу = до конца суток
+}
+
+// OnAirException заменяет недельные окна в указанную дату, например в праздник.
+// Без окон канал весь день вне эфира.
+type OnAirException struct {
+	Date    string        `json:"date"` // ГГГГ-ММ-ДД
+	Windows []OnAirWindow `json:"windows"`
+}
+
+// onAirSpan - разобранное окно: смещения от начала суток
+type onAirSpan struct {
+	days       map[time.Weekday]bool // Пусто = каждый день
+	start, end time.Duration
+}
+
+// OnAirSchedule определяет, разрешено ли вещание в данный момент
+type OnAirSchedule struct {
+	Action   string
+	Slate    string
+	location *time.Location
+	windows  []onAirSpan
+	dates    map[string][]onAirSpan
+}
+
+var onAirWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// NewOnAirSchedule разбирает расписание вещания канала. Возвращает nil, если расписание выключено.
+func NewOnAirSchedule(config *Config) (*OnAirSchedule, error) {
+	cfg := config.OnAir
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	s := &OnAirSchedule{Action: cfg.Outside, Slate: cfg.Slate, location: time.Local, dates: make(map[string][]onAirSpan)}
+	if s.Action == "" {
+		s.Action = OffAirDisconnect
+	}
+	if s.Action != OffAirDisconnect && s.Action != OffAirSlate {
+		return nil, fmt.Errorf("%w: %s", errOnAirAction, s.Action)
+	}
+	if s.Slate == "" {
+		s.Slate = config.Slate.File
+	}
+	if cfg.Timezone != "" {
+		location, err := time.LoadLocation(cfg.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", errOnAirTimezone, cfg.Timezone, err)
+		}
+		s.location = location
+	}
+
+	var err error
+	if s.windows, err = parseOnAirWindows(cfg.Windows, true); err != nil {
+		return nil, err
+	}
+	for _, exception := range cfg.Exceptions {
+		if _, err := time.Parse(onAirDateLayout, exception.Date); err != nil {
+			return nil, fmt.Errorf("%w: %s", errOnAirDate, exception.Date)
+		}
+		if s.dates[exception.Date], err = parseOnAirWindows(exception.Windows, false); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// parseOnAirWindows разбирает окна; дни недели учитываются только для недельных окон
+func parseOnAirWindows(windows []OnAirWindow, withDays bool) ([]onAirSpan, error) {
+	var spans []onAirSpan
+	for _, w := range windows {
+		span := onAirSpan{days: make(map[time.Weekday]bool)}
+		var err error
+		if span.start, err = parseClock(w.Start); err != nil {
+			return nil, err
+		}
+		if span.end, err = parseClock(w.End); err != nil {
+			return nil, err
+		}
+		if span.end <= span.start {
+			span.end += onAirDay
+		}
+		if withDays {
+			for _, day := range w.Days {
+				weekday, ok := onAirWeekdays[strings.ToLower(day)]
+				if !ok {
+					return nil, fmt.Errorf("%w: %s", errOnAirDay, day)
+				}
+				span.days[weekday] = true
+			}
+		}
+		spans = append(spans, span)
+	}
+	return spans, nil
+}
+
+// parseClock разбирает время суток ЧЧ:ММ, допускается 24:00
+func parseClock(value string) (time.Duration, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(value, "%d:%d", &h, &m); err != nil || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m > 0) {
+		return 0, fmt.Errorf("%w: %s", errOnAirTime, value)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// spansFor возвращает окна суток day: окна исключения или недельные окна этого дня недели
+func (s *OnAirSchedule) spansFor(day time.Time) []onAirSpan {
+	if spans, ok := s.dates[day.Format(onAirDateLayout)]; ok {
+		return spans
+	}
+	var spans []onAirSpan
+	for _, span := range s.windows {
+		if len(span.days) == 0 || span.days[day.Weekday()] {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// OnAir сообщает, разрешено ли вещание в момент t. Окна задаются по часам
+// часового пояса канала; окно через полночь относится к суткам, в которые оно началось.
+func (s *OnAirSchedule) OnAir(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	t = t.In(s.location)
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	for _, span := range s.spansFor(t) {
+		if clock >= span.start && clock < span.end {
+			return true
+		}
+	}
+	for _, span := range s.spansFor(t.AddDate(0, 0, -1)) {
+		if clock+onAirDay >= span.start && clock+onAirDay < span.end {
+			return true
+		}
+	}
+	return false
+}
+
+// NextChange возвращает ближайший момент, когда состояние окна изменится;
+// ok=false, если в ближайшую неделю изменений нет. Состояние меняется только на границах
+// окон и в полночь, поэтому проверяются только эти моменты, а не каждая минута.
+func (s *OnAirSchedule) NextChange(t time.Time) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	current := s.OnAir(t)
+	for _, next := range s.boundaries(t) {
+		if s.OnAir(next) != current {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// boundaries возвращает по возрастанию границы окон и полуночи после t на onAirSearchDays суток вперед.
+// Окна предыдущих суток тоже учитываются: окно через полночь заканчивается в текущих.
+func (s *OnAirSchedule) boundaries(t time.Time) []time.Time {
+	t = t.In(s.location)
+	var times []time.Time
+	add := func(day time.Time, offset time.Duration) {
+		// time.Date переносит часы сверх 24 на следующие сутки и учитывает переход на летнее время
+		at := time.Date(day.Year(), day.Month(), day.Day(), int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, s.location)
+		if at.After(t) {
+			times = append(times, at)
+		}
+	}
+	for i := -1; i <= onAirSearchDays; i++ {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, s.location)
+		add(day, 0)
+		for _, span := range s.spansFor(day) {
+			add(day, span.start)
+			add(day, span.end)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+// OnAirWatcher переключает канал в режим вне эфира и обратно по расписанию вещания
+type OnAirWatcher struct {
+	schedule *OnAirSchedule
+	ch       *Channel
+	stop     chan struct{}
+}
+
+// NewOnAirWatcher создает наблюдение за расписанием вещания канала
+func NewOnAirWatcher(schedule *OnAirSchedule, ch *Channel) *OnAirWatcher {
+	return &OnAirWatcher{schedule: schedule, ch: ch, stop: make(chan struct{})}
+}
+
+// Run проверяет окно вещания; блокирует до вызова Stop
+func (w *OnAirWatcher) Run() {
+	ticker := time.NewTicker(onAirCheckInterval)
+	defer ticker.Stop()
+
+	first := true
+	for {
+		now := time.Now()
+		onAir := w.schedule.OnAir(now)
+		if first || onAir == w.ch.OffAir() {
+			first = false
+			w.ch.setOffAir(!onAir)
+
+			event := OnAirEvent{OnAir: onAir}
+			nextText := "-"
+			if next, ok := w.schedule.NextChange(now); ok {
+				event.Next = &next
+				nextText = next.In(w.schedule.location).Format("2006-01-02 15:04 MST")
+			}
+			if onAir {
+				w.ch.Log.Info("onair.opened", "next", nextText)
+				w.ch.emit(EventOnAirOpened, event)
+			} else {
+				w.ch.Log.Warn("onair.closed", "next", nextText, "action", w.schedule.Action)
+				w.ch.emit(EventOnAirClosed, event)
+			}
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop останавливает наблюдение
+func (w *OnAirWatcher) Stop() {
+	close(w.stop)
+}
+
+// holdOffAir удерживает канал вне эфира до открытия окна вещания: показывает заставку
+// вне эфира на текущем соединении или закрывает соединение. Позиция прерванного файла
+// к этому моменту уже в состоянии, поэтому после открытия окна файл продолжается с нее.
+func holdOffAir(pub *Publisher, ch *Channel, schedule *OnAirSchedule, sessionBitrate *BitrateCalculator) {
+	if schedule.Action == OffAirSlate && schedule.Slate == "" {
+		ch.Log.Warn("onair.no_slate")
+	}
+
+	for ch.OffAir() && !ch.reloadPending() {
+		if schedule.Action == OffAirSlate && schedule.Slate != "" {
+			if err := pub.Connect(); err == nil {
+				ch.Monitor.SetSlate(true)
+				started := time.Now()
+				played, err := streamClip(schedule.Slate, pub, sessionBitrate, 0)
+				ch.emitClip(ClipSlate, schedule.Slate, started, played, err)
+				ch.Monitor.SetSlate(false)
+				if err == nil {
+					continue
+				}
+				ch.Log.Error("slate.failed", "file", schedule.Slate, "error", err)
+			}
+		}
+		if pub.Connected() {
+			pub.Close()
+		}
+		time.Sleep(onAirCheckInterval)
+	}
+}
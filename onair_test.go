@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestOnAirSchedule собирает расписание из окон и исключений в часовом поясе timezone
+func newTestOnAirSchedule(t *testing.T, timezone string, windows []OnAirWindow, exceptions []OnAirException) *OnAirSchedule {
+	t.Helper()
+	config := &Config{}
+	config.OnAir.Enabled = true
+	config.OnAir.Timezone = timezone
+	config.OnAir.Windows = windows
+	config.OnAir.Exceptions = exceptions
+	schedule, err := NewOnAirSchedule(config)
+	if err != nil {
+		t.Fatalf("NewOnAirSchedule: %v", err)
+	}
+	return schedule
+}
+
+// testOnAirWeek - будни днем, каждую ночь через полночь, суббота до конца суток;
+// пятница 2026-03-06 без эфира, воскресенье 2026-03-08 только в обед
+func testOnAirWeek(t *testing.T) *OnAirSchedule {
+	return newTestOnAirSchedule(t, "UTC",
+		[]OnAirWindow{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "09:00", End: "18:00"},
+			{Start: "22:00", End: "02:00"},
+			{Days: []string{"Sat"}, Start: "10:00", End: "24:00"},
+		},
+		[]OnAirException{
+			{Date: "2026-03-06"},
+			{Date: "2026-03-08", Windows: []OnAirWindow{{Start: "12:00", End: "13:00"}}},
+		})
+}
+
+func testTime(value string) time.Time {
+	at, err := time.Parse("2006-01-02 15:04", value)
+	if err != nil {
+		panic(err)
+	}
+	return at
+}
+
+func TestOnAirScheduleOnAir(t *testing.T) {
+	schedule := testOnAirWeek(t)
+	tests := []struct {
+		at   string
+		want bool
+	}{
+		// Понедельник
+		{"2026-03-02 08:59", false},
+		{"2026-03-02 09:00", true},
+		{"2026-03-02 17:59", true},
+		{"2026-03-02 18:00", false},
+		{"2026-03-02 21:59", false},
+		{"2026-03-02 22:00", true},
+		{"2026-03-03 01:59", true},
+		{"2026-03-03 02:00", false},
+		// Пятница-исключение: ночное окно четверга доигрывается, своих окон нет
+		{"2026-03-06 01:00", true},
+		{"2026-03-06 10:00", false},
+		{"2026-03-06 23:00", false},
+		// Суббота: ночного окна пятницы нет, окно до 24:00
+		{"2026-03-07 00:30", false},
+		{"2026-03-07 10:00", true},
+		{"2026-03-07 23:59", true},
+		// Воскресенье-исключение: ночное окно субботы доигрывается, дальше только обед
+		{"2026-03-08 00:30", true},
+		{"2026-03-08 09:30", false},
+		{"2026-03-08 12:30", true},
+		{"2026-03-08 22:30", false},
+		{"2026-03-09 01:00", false},
+	}
+	for _, tt := range tests {
+		if got := schedule.OnAir(testTime(tt.at)); got != tt.want {
+			t.Errorf("OnAir(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestOnAirScheduleTimezone(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Moscow"); err != nil {
+		t.Skipf("no timezone database: %v", err)
+	}
+	schedule := newTestOnAirSchedule(t, "Europe/Moscow", []OnAirWindow{{Start: "09:00", End: "10:00"}}, nil)
+	tests := []struct {
+		at   string // UTC
+		want bool
+	}{
+		{"2026-03-02 05:59", false},
+		{"2026-03-02 06:30", true},
+		{"2026-03-02 09:30", false},
+	}
+	for _, tt := range tests {
+		if got := schedule.OnAir(testTime(tt.at)); got != tt.want {
+			t.Errorf("OnAir(%s UTC) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestOnAirScheduleNextChange(t *testing.T) {
+	schedule := testOnAirWeek(t)
+	tests := []struct {
+		at   string
+		want string
+	}{
+		{"2026-03-02 08:00", "2026-03-02 09:00"},
+		{"2026-03-02 17:00", "2026-03-02 18:00"},
+		{"2026-03-02 23:00", "2026-03-03 02:00"},
+		{"2026-03-05 23:00", "2026-03-06 02:00"},
+		{"2026-03-06 03:00", "2026-03-07 10:00"},
+		{"2026-03-07 23:00", "2026-03-08 02:00"},
+		{"2026-03-08 02:00", "2026-03-08 12:00"},
+	}
+	for _, tt := range tests {
+		next, ok := schedule.NextChange(testTime(tt.at))
+		if !ok || !next.Equal(testTime(tt.want)) {
+			t.Errorf("NextChange(%s) = %v, %v, want %s", tt.at, next, ok, tt.want)
+		}
+	}
+
+	always := newTestOnAirSchedule(t, "UTC", []OnAirWindow{{Start: "00:00", End: "24:00"}}, nil)
+	if next, ok := always.NextChange(testTime("2026-03-02 12:00")); ok {
+		t.Errorf("NextChange on always-on schedule = %v, want none", next)
+	}
+
+	var disabled *OnAirSchedule
+	if !disabled.OnAir(testTime("2026-03-02 12:00")) {
+		t.Error("disabled schedule is off air")
+	}
+	if _, ok := disabled.NextChange(testTime("2026-03-02 12:00")); ok {
+		t.Error("disabled schedule has a next change")
+	}
+}
+
+func TestNewOnAirScheduleErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(config *Config)
+		wantErr error
+	}{
+		{"bad hour", func(c *Config) { c.OnAir.Windows = []OnAirWindow{{Start: "25:00", End: "26:00"}} }, errOnAirTime},
+		{"bad minute", func(c *Config) { c.OnAir.Windows = []OnAirWindow{{Start: "12:60", End: "13:00"}} }, errOnAirTime},
+		{"after midnight", func(c *Config) { c.OnAir.Windows = []OnAirWindow{{Start: "10:00", End: "24:30"}} }, errOnAirTime},
+		{"not a time", func(c *Config) { c.OnAir.Windows = []OnAirWindow{{Start: "noon", End: "13:00"}} }, errOnAirTime},
+		{"bad day", func(c *Config) {
+			c.OnAir.Windows = []OnAirWindow{{Days: []string{"funday"}, Start: "10:00", End: "11:00"}}
+		}, errOnAirDay},
+		{"bad date", func(c *Config) { c.OnAir.Exceptions = []OnAirException{{Date: "2026-13-01"}} }, errOnAirDate},
+		{"bad exception window", func(c *Config) {
+			c.OnAir.Exceptions = []OnAirException{{Date: "2026-01-01", Windows: []OnAirWindow{{Start: "9", End: "10:00"}}}}
+		}, errOnAirTime},
+		{"bad timezone", func(c *Config) { c.OnAir.Timezone = "Mars/Olympus" }, errOnAirTimezone},
+		{"bad action", func(c *Config) { c.OnAir.Outside = "pause" }, errOnAirAction},
+	}
+	for _, tt := range tests {
+		config := &Config{}
+		config.OnAir.Enabled = true
+		tt.setup(config)
+		if _, err := NewOnAirSchedule(config); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	schedule, err := NewOnAirSchedule(&Config{})
+	if schedule != nil || err != nil {
+		t.Errorf("disabled schedule = %v, %v, want nil, nil", schedule, err)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultOverrideDirectory = "override" // Каталог экстренных вставок по умолчанию
+	overridePlayedDirectory  = "played"   // Подкаталог для проигранных вставок из каталога
+	overridePollInterval     = time.Second
+)
+
+// OverrideItem - экстренная вставка, ожидающая эфира
+type OverrideItem struct {
+	Path    string // Путь к файлу вставки
+	Dropped bool   // Файл положен в каталог вставок и после эфира переносится в played/
+}
+
+// Override ставит экстренную вставку в эфир: текущий файл прерывается на ближайшем
+// ключевом кадре и продолжается с него же после вставки. Повторная постановка
+// того же файла, пока он ожидает эфира, ничего не делает.
+func (ch *Channel) Override(item OverrideItem) {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	for _, pending := range ch.control.overrides {
+		if pending.Path == item.Path {
+			return
+		}
+	}
+	ch.control.overrides = append(ch.control.overrides, item)
+}
+
+// overridePending сообщает, что экстренная вставка ждет эфира
+func (ch *Channel) overridePending() bool {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	return len(ch.control.overrides) > 0
+}
+
+// takeOverride забирает первую ожидающую вставку
+func (ch *Channel) takeOverride() (OverrideItem, bool) {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	if len(ch.control.overrides) == 0 {
+		return OverrideItem{}, false
+	}
+	item := ch.control.overrides[0]
+	ch.control.overrides = ch.control.overrides[1:]
+	return item, true
+}
+
+// returnOverride возвращает вставку в начало ожидающих, например после потери соединения
+func (ch *Channel) returnOverride(item OverrideItem) {
+	ch.control.mu.Lock()
+	defer ch.control.mu.Unlock()
+	ch.control.overrides = append([]OverrideItem{item}, ch.control.overrides...)
+}
+
+// overridePath находит файл для экстренной вставки по команде оператора:
+// в каталоге видео канала или среди проигранных вставок каталога override
+func (ch *Channel) overridePath(name string) (string, error) {
+	name = filepath.Base(name)
+	candidates := []string{
+		filepath.Join(ch.Config().Video.Directory, name),
+		filepath.Join(overrideDirectory(ch.Config()), overridePlayedDirectory, name),
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	return "", errFileNotFound
+}
+
+// overrideDirectory возвращает каталог экстренных вставок канала
+func overrideDirectory(config *Config) string {
+	if config.Override.Directory != "" {
+		return config.Override.Directory
+	}
+	return defaultOverrideDirectory
+}
+
+// runOverrides воспроизводит все ожидающие экстренные вставки на текущем соединении.
+// Таймстампы продолжаются с места прерывания, соединение не переустанавливается.
+// Возвращает время, потраченное на вставки. При ошибке соединения вставка
+// возвращается в ожидающие и выйдет в эфир после переподключения.
+func runOverrides(pub *Publisher, ch *Channel, sessionBitrate *BitrateCalculator) (time.Duration, error) {
+	start := time.Now()
+	if err := pub.Connect(); err != nil {
+		return 0, err
+	}
+
+	interrupted := ch.Monitor.Snapshot()
+	defer ch.Monitor.SetOverride("")
+	for {
+		item, ok := ch.takeOverride()
+		if !ok {
+			return time.Since(start), nil
+		}
+
+		name := filepath.Base(item.Path)
+		ch.Log.Warn("override.start", "file", name, "interrupted", interrupted.NowPlaying, "position", interrupted.Position)
+		ch.emit(EventOverrideStarted, OverrideEvent{File: name, Path: item.Path, Interrupted: interrupted.NowPlaying, Position: interrupted.Position})
+		ch.Monitor.SetOverride(name)
+
+		played, err := streamClip(item.Path, pub, sessionBitrate, 0)
+		if err != nil && (errors.Is(err, ErrWrite) || errors.Is(err, ErrHandshake)) {
+			ch.returnOverride(item)
+			return time.Since(start), err
+		}
+
+		finished := OverrideEvent{File: name, Path: item.Path, Interrupted: interrupted.NowPlaying, Position: interrupted.Position, Duration: played.Seconds()}
+		if err != nil {
+			// Файл вставки не читается: повторять бессмысленно, эфир возвращается к контенту
+			ch.Log.Error("override.failed", "file", name, "error", err)
+			finished.Error = err.Error()
+		} else {
+			ch.Log.Info("override.done", "file", name, "duration", played)
+		}
+		ch.emit(EventOverrideFinished, finished)
+
+		if item.Dropped {
+			archiveOverride(ch, item.Path)
+		}
+	}
+}
+
+// playOverrides воспроизводит ожидающие вставки между файлами, на заставке и паузе.
+// При ошибке соединение закрывается, вставка выйдет в эфир после переподключения.
+func playOverrides(pub *Publisher, ch *Channel, sessionBitrate *BitrateCalculator) {
+	if _, err := runOverrides(pub, ch, sessionBitrate); err != nil {
+		ch.Log.Error("override.conn_failed", "error", err, "retry_in", time.Duration(retryDelay)*time.Second)
+		pub.Close()
+		time.Sleep(time.Duration(retryDelay) * time.Second)
+	}
+}
+
+// archiveOverride переносит проигранную вставку из каталога в played/, чтобы она не повторилась.
+// К имени добавляется время эфира, чтобы повторные вставки с тем же именем не затирали друг друга.
+func archiveOverride(ch *Channel, path string) {
+	playedDir := filepath.Join(filepath.Dir(path), overridePlayedDirectory)
+	if err := os.MkdirAll(playedDir, 0755); err != nil {
+		ch.Log.Error("override.archive_failed", "file", filepath.Base(path), "error", err)
+		return
+	}
+	target := filepath.Join(playedDir, time.Now().Format("20060102-150405")+"-"+filepath.Base(path))
+	if err := os.Rename(path, target); err != nil {
+		ch.Log.Error("override.archive_failed", "file", filepath.Base(path), "error", err)
+	}
+}
+
+// OverrideWatcher ставит в эфир файлы, положенные в каталог экстренных вставок.
+// Файл выходит в эфир после окончания загрузки и переносится в played/.
+type OverrideWatcher struct {
+	Dir string
+
+	ch   *Channel
+	stop chan struct{}
+}
+
+// NewOverrideWatcher создает наблюдение за каталогом экстренных вставок канала
+func NewOverrideWatcher(ch *Channel) *OverrideWatcher {
+	return &OverrideWatcher{Dir: overrideDirectory(ch.Config()), ch: ch, stop: make(chan struct{})}
+}
+
+// Run следит за каталогом; блокирует до вызова Stop
+func (w *OverrideWatcher) Run() {
+	if err := os.MkdirAll(w.Dir, 0755); err != nil {
+		w.ch.Log.Error("override.mkdir_failed", "dir", w.Dir, "error", err)
+		return
+	}
+
+	w.ch.Log.Info("override.watching", "dir", w.Dir)
+	drops := NewDirCatalog(w.Dir, w.ch.Log)
+	defer drops.Close()
+
+	// Файлы, уже поставленные в эфир: они остаются в каталоге до окончания вставки
+	triggered := make(map[string]bool)
+	ticker := time.NewTicker(overridePollInterval)
+	defer ticker.Stop()
+
+	for {
+		present := make(map[string]bool)
+		for _, entry := range drops.Files() {
+			name := entry.Name()
+			present[name] = true
+			if !triggered[name] {
+				triggered[name] = true
+				w.ch.Log.Warn("override.dropped", "file", name)
+				w.ch.Override(OverrideItem{Path: filepath.Join(w.Dir, name), Dropped: true})
+			}
+		}
+		for name := range triggered {
+			if !present[name] {
+				delete(triggered, name)
+			}
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop останавливает наблюдение
+func (w *OverrideWatcher) Stop() {
+	close(w.stop)
+}
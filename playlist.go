@@ -0,0 +1,394 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Режимы воспроизведения плейлиста
+const (
+	PlaybackSequential = "sequential" // По алфавиту
+	PlaybackShuffle    = "shuffle"    // Перемешивание на каждом круге
+	PlaybackWeighted   = "weighted"   // Случайный выбор с учетом весов
+	PlaybackNoRepeat   = "norepeat"   // Случайный выбор без повторов среди последних N файлов
+)
+
+const defaultNoRepeatWindow = 3 // Размер окна без повторов по умолчанию
+
+// PlaylistState содержит состояние плейлиста для сохранения/восстановления
+type PlaylistState struct {
+	Mode     string   `json:"mode"`             // Режим воспроизведения
+	Seed     int64    `json:"seed"`             // Зерно генератора случайных чисел
+	Loop     int      `json:"loop"`             // Номер текущего круга
+	Order    []string `json:"order"`            // Порядок файлов текущего круга
+	Index    int      `json:"index"`            // Позиция в порядке
+	RNGState uint64   `json:"rngState"`         // Состояние генератора случайных чисел
+	Recent   []string `json:"recent,omitempty"` // Последние проигранные файлы (для режима без повторов)
+}
+
+// playlistRNG - простой генератор splitmix64, состояние которого можно сохранить в JSON
+type playlistRNG struct {
+	state uint64
+}
+
+func (r *playlistRNG) next() uint64 {
+	r.state += 0x9E3779B97F4A7C15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+func (r *playlistRNG) intn(n int) int {
+	return int(r.next() % uint64(n))
+}
+
+func (r *playlistRNG) float64() float64 {
+	return float64(r.next()>>11) / (1 << 53)
+}
+
+// Playlist определяет порядок воспроизведения файлов в зависимости от режима
+type Playlist struct {
+	Mode           string
+	Seed           int64
+	NoRepeatWindow int
+	Weights        map[string]float64
+	FileWeights    map[string]float64 // Веса из метаданных файлов; video.weights имеет приоритет
+
+	loop   int
+	order  []string
+	index  int
+	rng    playlistRNG
+	recent []string
+}
+
+// NewPlaylist создает плейлист из конфигурации
+func NewPlaylist(config *Config, log *Logger) *Playlist {
+	mode := strings.ToLower(config.Video.PlaybackMode)
+	switch mode {
+	case PlaybackSequential, PlaybackShuffle, PlaybackWeighted, PlaybackNoRepeat:
+	case "":
+		mode = PlaybackSequential
+	default:
+		log.Warn("playlist.mode_unknown", "mode", config.Video.PlaybackMode, "fallback", PlaybackSequential)
+		mode = PlaybackSequential
+	}
+
+	seed := config.Video.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	window := config.Video.NoRepeatWindow
+	if window <= 0 {
+		window = defaultNoRepeatWindow
+	}
+
+	return &Playlist{
+		Mode:           mode,
+		Seed:           seed,
+		NoRepeatWindow: window,
+		Weights:        config.Video.Weights,
+	}
+}
+
+// Restore продолжает последовательность из сохраненного состояния, если режим не менялся
+func (p *Playlist) Restore(saved *PlaylistState) bool {
+	if saved == nil || saved.Mode != p.Mode {
+		return false
+	}
+
+	p.Seed = saved.Seed
+	p.loop = saved.Loop
+	p.order = append([]string(nil), saved.Order...)
+	p.index = saved.Index
+	p.rng.state = saved.RNGState
+	p.recent = append([]string(nil), saved.Recent...)
+	return true
+}
+
+// State возвращает состояние плейлиста для сохранения
+func (p *Playlist) State() *PlaylistState {
+	return &PlaylistState{
+		Mode:     p.Mode,
+		Seed:     p.Seed,
+		Loop:     p.loop,
+		Order:    append([]string(nil), p.order...),
+		Index:    p.index,
+		RNGState: p.rng.state,
+		Recent:   append([]string(nil), p.recent...),
+	}
+}
+
+// Refresh согласует порядок с текущим содержимым каталога.
+// Если круг закончен, строится порядок следующего круга.
+func (p *Playlist) Refresh(files []string) {
+	if p.LoopDone() {
+		p.newLoop(files)
+		return
+	}
+
+	if p.Mode == PlaybackSequential {
+		// Новые файлы в последовательном режиме попадают в текущий круг
+		current := ""
+		if p.index < len(p.order) {
+			current = p.order[p.index]
+		}
+		p.order = sortedNames(files)
+		p.index = 0
+		for i, name := range p.order {
+			if name >= current {
+				p.index = i
+				break
+			}
+			p.index = i + 1
+		}
+	}
+}
+
+// Update применяет изменения каталога (добавление, удаление, переименование)
+// к оставшейся части круга. Уже проигранная часть круга не меняется.
+func (p *Playlist) Update(files []string) {
+	if p.LoopDone() {
+		// Новый круг будет построен из актуального списка
+		return
+	}
+
+	if p.Mode == PlaybackSequential {
+		p.Refresh(files)
+		return
+	}
+
+	present := make(map[string]bool, len(files))
+	for _, name := range files {
+		present[name] = true
+	}
+	known := make(map[string]bool, len(p.order))
+	for _, name := range p.order {
+		known[name] = true
+	}
+
+	// Удаленные и переименованные файлы убираем из очереди
+	upcoming := make([]string, 0, len(p.order)-p.index)
+	for _, name := range p.order[p.index:] {
+		if present[name] {
+			upcoming = append(upcoming, name)
+		}
+	}
+
+	// В режиме перемешивания новые файлы попадают в текущий круг на случайные позиции,
+	// в случайных режимах они станут доступны со следующего круга
+	if p.Mode == PlaybackShuffle {
+		for _, name := range sortedNames(files) {
+			if known[name] {
+				continue
+			}
+			pos := p.rng.intn(len(upcoming) + 1)
+			upcoming = append(upcoming, "")
+			copy(upcoming[pos+1:], upcoming[pos:])
+			upcoming[pos] = name
+		}
+	}
+
+	p.order = append(p.order[:p.index:p.index], upcoming...)
+}
+
+// newLoop строит порядок воспроизведения для нового круга
+func (p *Playlist) newLoop(files []string) {
+	if p.order != nil {
+		p.loop++
+	}
+	p.index = 0
+
+	names := sortedNames(files)
+	if len(names) == 0 {
+		p.order = nil
+		return
+	}
+
+	switch p.Mode {
+	case PlaybackShuffle:
+		// Порядок каждого круга однозначно определяется зерном и номером круга
+		p.rng.state = uint64(p.Seed) + uint64(p.loop)*0x9E3779B97F4A7C15
+		for i := len(names) - 1; i > 0; i-- {
+			j := p.rng.intn(i + 1)
+			names[i], names[j] = names[j], names[i]
+		}
+		p.order = names
+
+	case PlaybackWeighted:
+		p.seedOnce()
+		order := make([]string, len(names))
+		for i := range order {
+			order[i] = p.pickWeighted(names)
+		}
+		p.order = order
+
+	case PlaybackNoRepeat:
+		p.seedOnce()
+		order := make([]string, len(names))
+		for i := range order {
+			order[i] = p.pickNoRepeat(names)
+		}
+		p.order = order
+
+	default:
+		p.order = names
+	}
+}
+
+// seedOnce инициализирует генератор зерном, если он еще не использовался
+func (p *Playlist) seedOnce() {
+	if p.rng.state == 0 {
+		p.rng.state = uint64(p.Seed)
+	}
+}
+
+// weight возвращает вес файла из video.weights или из метаданных файла, по умолчанию 1
+func (p *Playlist) weight(name string) float64 {
+	if w, ok := p.Weights[name]; ok {
+		return w
+	}
+	if w, ok := p.FileWeights[name]; ok {
+		return w
+	}
+	return 1
+}
+
+// pickWeighted выбирает файл случайно пропорционально весам
+func (p *Playlist) pickWeighted(names []string) string {
+	var total float64
+	for _, name := range names {
+		if w := p.weight(name); w > 0 {
+			total += w
+		}
+	}
+	if total <= 0 {
+		return names[p.rng.intn(len(names))]
+	}
+
+	target := p.rng.float64() * total
+	for _, name := range names {
+		w := p.weight(name)
+		if w <= 0 {
+			continue
+		}
+		if target < w {
+			return name
+		}
+		target -= w
+	}
+	return names[len(names)-1]
+}
+
+// pickNoRepeat выбирает случайный файл, не проигранный среди последних N
+func (p *Playlist) pickNoRepeat(names []string) string {
+	window := p.NoRepeatWindow
+	if window >= len(names) {
+		window = len(names) - 1
+	}
+
+	recent := make(map[string]bool)
+	for i := len(p.recent) - 1; i >= 0 && len(recent) < window; i-- {
+		recent[p.recent[i]] = true
+	}
+
+	var candidates []string
+	for _, name := range names {
+		if !recent[name] {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = names
+	}
+
+	name := candidates[p.rng.intn(len(candidates))]
+	p.recent = append(p.recent, name)
+	if len(p.recent) > p.NoRepeatWindow {
+		p.recent = p.recent[len(p.recent)-p.NoRepeatWindow:]
+	}
+	return name
+}
+
+// Current возвращает текущий файл круга
+func (p *Playlist) Current() (string, bool) {
+	if p.index >= len(p.order) {
+		return "", false
+	}
+	return p.order[p.index], true
+}
+
+// Index возвращает позицию текущего файла в круге
+func (p *Playlist) Index() int {
+	return p.index
+}
+
+// Len возвращает количество элементов в текущем круге
+func (p *Playlist) Len() int {
+	return len(p.order)
+}
+
+// Played возвращает файлы текущего круга, которые уже проиграны
+func (p *Playlist) Played() []string {
+	return append([]string(nil), p.order[:p.index]...)
+}
+
+// Upcoming возвращает до n файлов, которые будут проиграны после текущего в этом круге
+func (p *Playlist) Upcoming(n int) []string {
+	if p.index+1 >= len(p.order) {
+		return nil
+	}
+	upcoming := p.order[p.index+1:]
+	if len(upcoming) > n {
+		upcoming = upcoming[:n]
+	}
+	return append([]string(nil), upcoming...)
+}
+
+// Seek переходит к указанному файлу текущего круга
+func (p *Playlist) Seek(name string) bool {
+	for i := p.index; i < len(p.order); i++ {
+		if p.order[i] == name {
+			p.index = i
+			return true
+		}
+	}
+	for i := 0; i < p.index && i < len(p.order); i++ {
+		if p.order[i] == name {
+			p.index = i
+			return true
+		}
+	}
+	return false
+}
+
+// Advance переходит к следующему файлу
+func (p *Playlist) Advance() {
+	if p.index < len(p.order) {
+		p.index++
+	}
+}
+
+// LoopDone сообщает, что все файлы текущего круга проиграны
+func (p *Playlist) LoopDone() bool {
+	return p.index >= len(p.order)
+}
+
+// sortedNames возвращает отсортированную копию списка имен
+func sortedNames(files []string) []string {
+	names := append([]string(nil), files...)
+	sort.Strings(names)
+	return names
+}
+
+// fileNames возвращает имена файлов из записей каталога
+func fileNames(entries []os.DirEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
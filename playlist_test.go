@@ -0,0 +1,230 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// playLoops проигрывает loops кругов и возвращает файлы в порядке эфира
+func playLoops(p *Playlist, files []string, loops int) []string {
+	var played []string
+	for i := 0; i < loops; i++ {
+		p.Refresh(files)
+		for !p.LoopDone() {
+			name, _ := p.Current()
+			played = append(played, name)
+			p.Advance()
+		}
+	}
+	return played
+}
+
+func TestPlaylistSequential(t *testing.T) {
+	p := &Playlist{Mode: PlaybackSequential}
+	got := playLoops(p, []string{"c.mp4", "a.mp4", "b.mp4"}, 2)
+	want := []string{"a.mp4", "b.mp4", "c.mp4", "a.mp4", "b.mp4", "c.mp4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+}
+
+func TestPlaylistSequentialRefreshKeepsPosition(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		current string
+	}{
+		{name: "file added before current", files: []string{"a.mp4", "b.mp4", "c.mp4", "d.mp4"}, current: "c.mp4"},
+		{name: "file added after current", files: []string{"a.mp4", "c.mp4", "d.mp4", "e.mp4"}, current: "c.mp4"},
+		{name: "current removed", files: []string{"a.mp4", "b.mp4", "d.mp4"}, current: "d.mp4"},
+	}
+	for _, tt := range tests {
+		p := &Playlist{Mode: PlaybackSequential}
+		p.Refresh([]string{"a.mp4", "c.mp4", "d.mp4"})
+		p.Seek("c.mp4")
+		p.Refresh(tt.files)
+		if got, _ := p.Current(); got != tt.current {
+			t.Errorf("%s: current = %q, want %q", tt.name, got, tt.current)
+		}
+	}
+}
+
+func TestPlaylistShuffle(t *testing.T) {
+	files := []string{"a.mp4", "b.mp4", "c.mp4", "d.mp4", "e.mp4", "f.mp4"}
+	first := playLoops(&Playlist{Mode: PlaybackShuffle, Seed: 42}, files, 3)
+	second := playLoops(&Playlist{Mode: PlaybackShuffle, Seed: 42}, files, 3)
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("same seed gave different orders: %v and %v", first, second)
+	}
+
+	// Каждый круг - перестановка всех файлов
+	for loop := 0; loop < 3; loop++ {
+		order := append([]string(nil), first[loop*len(files):(loop+1)*len(files)]...)
+		sort.Strings(order)
+		if !reflect.DeepEqual(order, files) {
+			t.Errorf("loop %d is not a permutation: %v", loop, first[loop*len(files):(loop+1)*len(files)])
+		}
+	}
+
+	other := playLoops(&Playlist{Mode: PlaybackShuffle, Seed: 7}, files, 3)
+	if reflect.DeepEqual(first, other) {
+		t.Errorf("different seeds gave the same order %v", first)
+	}
+}
+
+func TestPlaylistShuffleUpdate(t *testing.T) {
+	p := &Playlist{Mode: PlaybackShuffle, Seed: 1}
+	p.Refresh([]string{"a.mp4", "b.mp4", "c.mp4", "d.mp4"})
+	p.Advance()
+	played := p.Played()
+	removed, _ := p.Current()
+
+	var files []string
+	for _, name := range []string{"a.mp4", "b.mp4", "c.mp4", "d.mp4"} {
+		if name != removed {
+			files = append(files, name)
+		}
+	}
+	files = append(files, "new.mp4")
+	p.Update(files)
+
+	if !reflect.DeepEqual(p.Played(), played) {
+		t.Errorf("played part changed: %v, want %v", p.Played(), played)
+	}
+	upcoming := append([]string(nil), p.State().Order[p.Index():]...)
+	sort.Strings(upcoming)
+	var want []string
+	for _, name := range files {
+		if name != played[0] {
+			want = append(want, name)
+		}
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(upcoming, want) {
+		t.Errorf("upcoming = %v, want %v", upcoming, want)
+	}
+}
+
+func TestPlaylistWeighted(t *testing.T) {
+	tests := []struct {
+		name        string
+		weights     map[string]float64
+		fileWeights map[string]float64
+		want        map[string]float64 // Ожидаемая доля эфира
+	}{
+		{
+			name:    "config weights",
+			weights: map[string]float64{"a.mp4": 3, "b.mp4": 1, "c.mp4": 0},
+			want:    map[string]float64{"a.mp4": 0.75, "b.mp4": 0.25},
+		},
+		{
+			name:        "sidecar weights",
+			fileWeights: map[string]float64{"a.mp4": 1, "b.mp4": 0, "c.mp4": 1},
+			want:        map[string]float64{"a.mp4": 0.5, "c.mp4": 0.5},
+		},
+		{
+			name:        "config weights take priority",
+			weights:     map[string]float64{"a.mp4": 0},
+			fileWeights: map[string]float64{"a.mp4": 5, "b.mp4": 1, "c.mp4": 1},
+			want:        map[string]float64{"b.mp4": 0.5, "c.mp4": 0.5},
+		},
+	}
+	files := []string{"a.mp4", "b.mp4", "c.mp4"}
+	for _, tt := range tests {
+		p := &Playlist{Mode: PlaybackWeighted, Seed: 3, Weights: tt.weights, FileWeights: tt.fileWeights}
+		played := playLoops(p, files, 2000)
+		counts := make(map[string]float64)
+		for _, name := range played {
+			counts[name]++
+		}
+		for _, name := range files {
+			share := counts[name] / float64(len(played))
+			if diff := share - tt.want[name]; diff > 0.03 || diff < -0.03 {
+				t.Errorf("%s: share of %s = %.3f, want %.3f", tt.name, name, share, tt.want[name])
+			}
+		}
+	}
+}
+
+func TestPlaylistNoRepeat(t *testing.T) {
+	tests := []struct {
+		files  int
+		window int
+		gap    int // Минимальное расстояние между повторами одного файла
+	}{
+		{files: 5, window: 3, gap: 4},
+		{files: 3, window: 3, gap: 3}, // Окно не больше числа файлов минус один
+		{files: 2, window: 1, gap: 2},
+	}
+	for _, tt := range tests {
+		var files []string
+		for i := 0; i < tt.files; i++ {
+			files = append(files, string(rune('a'+i))+".mp4")
+		}
+		p := &Playlist{Mode: PlaybackNoRepeat, Seed: 9, NoRepeatWindow: tt.window}
+		played := playLoops(p, files, 50)
+		last := make(map[string]int)
+		for i, name := range played {
+			if prev, ok := last[name]; ok && i-prev < tt.gap {
+				t.Errorf("files=%d window=%d: %s repeated after %d items", tt.files, tt.window, name, i-prev)
+				break
+			}
+			last[name] = i
+		}
+	}
+}
+
+func TestPlaylistRestore(t *testing.T) {
+	files := []string{"a.mp4", "b.mp4", "c.mp4", "d.mp4"}
+	for _, mode := range []string{PlaybackSequential, PlaybackShuffle, PlaybackWeighted, PlaybackNoRepeat} {
+		original := &Playlist{Mode: mode, Seed: 11, NoRepeatWindow: 2}
+		playLoops(original, files, 2)
+		original.Refresh(files)
+		original.Advance()
+		saved := original.State()
+
+		restored := &Playlist{Mode: mode, Seed: 99, NoRepeatWindow: 2}
+		if !restored.Restore(saved) {
+			t.Fatalf("%s: Restore returned false", mode)
+		}
+		want := playLoops(original, files, 3)
+		got := playLoops(restored, files, 3)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: restored order %v, want %v", mode, got, want)
+		}
+	}
+
+	p := &Playlist{Mode: PlaybackShuffle}
+	if p.Restore(&PlaylistState{Mode: PlaybackSequential}) {
+		t.Error("Restore accepted state of another mode")
+	}
+	if p.Restore(nil) {
+		t.Error("Restore accepted nil state")
+	}
+}
+
+func TestPlaylistSeek(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      int
+		target    string
+		wantOK    bool
+		wantIndex int
+	}{
+		{name: "forward", from: 0, target: "c.mp4", wantOK: true, wantIndex: 2},
+		{name: "backward", from: 3, target: "b.mp4", wantOK: true, wantIndex: 1},
+		{name: "current", from: 1, target: "b.mp4", wantOK: true, wantIndex: 1},
+		{name: "missing", from: 2, target: "x.mp4", wantOK: false, wantIndex: 2},
+	}
+	for _, tt := range tests {
+		p := &Playlist{Mode: PlaybackSequential}
+		p.Refresh([]string{"a.mp4", "b.mp4", "c.mp4", "d.mp4"})
+		for i := 0; i < tt.from; i++ {
+			p.Advance()
+		}
+		if ok := p.Seek(tt.target); ok != tt.wantOK || p.Index() != tt.wantIndex {
+			t.Errorf("%s: Seek(%q) = %v, index %d, want %v, index %d", tt.name, tt.target, ok, p.Index(), tt.wantOK, tt.wantIndex)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/nareix/joy4/av"
+	"github.com/nareix/joy4/format/flv/flvio"
+	"github.com/nareix/joy4/format/rtmp"
+)
+
+const (
+	segmentGap        = 40 * time.Millisecond // Зазор между сегментами при сшивке таймстампов
+	amf0DataMsgTypeID = 18                    // Тип RTMP-сообщения AMF0 Data
+	dataMsgChunkID    = 5                     // Chunk stream ID для data-сообщений (как в joy4)
+	rtmpHeaderLength  = 12                    // Длина заголовка чанка типа 0
+	rtmpExtTSLength   = 4                     // Длина расширенного таймстампа
+	rtmpMaxTimestamp  = 0xFFFFFF              // Максимальный таймстамп без расширения
+)
+
+// errNotConnected возвращается при записи без установленного соединения
+var errNotConnected = newClassError("err.not_connected", ErrWrite)
+
+// Publisher держит RTMP-соединение и сшивает таймстампы нескольких источников,
+// чтобы файлы, рекламные ролики и заставки шли одним непрерывным потоком
+type Publisher struct {
+	URL string // Адрес RTMP сервера
+	log *Logger
+
+	OnConnection func(connected bool) // Вызывается при установке и закрытии соединения
+	OnRestored   func()               // Вызывается, когда сервер принял публикацию после ошибок соединения
+	Breaker      *CircuitBreaker      // Автомат защиты соединения, может отсутствовать
+
+	conn         *rtmp.Conn
+	streams      []av.CodecData
+	segmentStart time.Duration // Выходной таймстамп начала текущего сегмента
+	segmentBase  time.Duration // Входной таймстамп первого пакета сегмента, -1 пока не известен
+	lastTS       time.Duration // Последний записанный выходной таймстамп
+	written      bool          // Были ли записаны пакеты в текущем соединении
+
+	cueEventID  int           // Счетчик идентификаторов рекламных пауз
+	pendingCue  bool          // Ожидается отправка cue point возврата к контенту
+	cueInAt     time.Duration // Выходной таймстамп, на котором нужно отправить cue point возврата
+	cueInParams flvio.AMFMap  // Параметры отложенного cue point возврата
+}
+
+// NewPublisher создает публикатор для указанного RTMP URL
+func NewPublisher(url string, log *Logger) *Publisher {
+	return &Publisher{URL: url, log: log, segmentBase: -1}
+}
+
+// Connected сообщает, установлено ли соединение
+func (p *Publisher) Connected() bool {
+	return p.conn != nil
+}
+
+// Connect устанавливает соединение с RTMP сервером, если оно еще не установлено.
+// Пока автомат защиты разомкнут, попытка не выполняется и возвращается errBreakerOpen.
+// Успех попытки автомат защиты получает только после рукопожатия и публикации в WriteHeader.
+func (p *Publisher) Connect() error {
+	if p.conn != nil {
+		return nil
+	}
+
+	if u, err := rtmp.ParseURL(p.URL); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	} else if u.Scheme != "rtmp" || u.Host == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidURL, p.URL)
+	}
+
+	if p.Breaker != nil {
+		if err := p.Breaker.Allow(); err != nil {
+			return err
+		}
+	}
+
+	p.log.Debug("rtmp.connecting", "destination", p.URL)
+	conn, err := rtmp.DialTimeout(p.URL, reconnectTimeout)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrDial, err)
+		if p.Breaker != nil {
+			p.Breaker.Failure(err)
+		}
+		return err
+	}
+
+	p.conn = conn
+	p.streams = nil
+	p.segmentStart = 0
+	p.segmentBase = -1
+	p.lastTS = 0
+	p.written = false
+	p.pendingCue = false
+	if p.OnConnection != nil {
+		p.OnConnection(true)
+	}
+	return nil
+}
+
+// Close закрывает текущее соединение. Вызывается и автоматически при ошибке записи,
+// чтобы следующий Connect установил новое соединение.
+func (p *Publisher) Close() {
+	if p.conn == nil {
+		return
+	}
+	p.conn.WriteTrailer()
+	p.conn.Close()
+	p.conn = nil
+	// Соединение закрыто до публикации: пробная попытка автомата защиты не завершилась
+	if p.streams == nil && p.Breaker != nil {
+		p.Breaker.Abort()
+	}
+	if p.OnConnection != nil {
+		p.OnConnection(false)
+	}
+}
+
+// WriteHeader отправляет заголовок потоков и начинает новый сегмент.
+// Таймстампы следующего сегмента продолжаются с места окончания предыдущего.
+// Результат первого заголовка на соединении отмечается в автомате защиты.
+func (p *Publisher) WriteHeader(streams []av.CodecData) error {
+	if p.conn == nil {
+		return errNotConnected
+	}
+
+	if err := p.conn.WriteHeader(streams); err != nil {
+		p.Close()
+		// Первый заголовок на соединении выполняет рукопожатие и команду publish
+		if p.streams == nil {
+			err = fmt.Errorf("%w: %w", ErrHandshake, err)
+			if p.Breaker != nil {
+				p.Breaker.Failure(err)
+			}
+			return err
+		}
+		return fmt.Errorf("%w: %s: %w", ErrWrite, T("err.write_header"), err)
+	}
+
+	// Соединение считается установленным, только когда сервер принял публикацию
+	if p.streams == nil && p.Breaker != nil {
+		restored := p.Breaker.Snapshot().Failures > 0
+		p.Breaker.Success()
+		if restored && p.OnRestored != nil {
+			p.OnRestored()
+		}
+	}
+
+	p.streams = streams
+	p.BeginSegment()
+	return nil
+}
+
+// BeginSegment начинает новый сегмент без повторной отправки заголовка,
+// например при продолжении файла после вставки
+func (p *Publisher) BeginSegment() {
+	if p.written {
+		p.segmentStart = p.lastTS + segmentGap
+	}
+	p.segmentBase = -1
+}
+
+// WritePacket пересчитывает таймстамп пакета относительно начала сегмента и отправляет его
+func (p *Publisher) WritePacket(pkt av.Packet) error {
+	if p.conn == nil {
+		return errNotConnected
+	}
+
+	if p.segmentBase < 0 {
+		p.segmentBase = pkt.Time
+	}
+
+	outTS := p.segmentStart + pkt.Time - p.segmentBase
+	if outTS < p.segmentStart {
+		outTS = p.segmentStart
+	}
+	pkt.Time = outTS
+
+	if err := p.conn.WritePacket(pkt); err != nil {
+		p.Close()
+		return fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+
+	if outTS > p.lastTS {
+		p.lastTS = outTS
+	}
+	p.written = true
+
+	// Отложенный cue point возврата к контенту
+	if p.pendingCue && p.lastTS >= p.cueInAt {
+		p.pendingCue = false
+		if err := p.WriteCuePoint("splice_insert", p.cueInParams); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Position возвращает текущий выходной таймстамп потока
+func (p *Publisher) Position() time.Duration {
+	return p.lastTS
+}
+
+// NextCueEventID возвращает новый идентификатор рекламной паузы
+func (p *Publisher) NextCueEventID() int {
+	p.cueEventID++
+	return p.cueEventID
+}
+
+// ScheduleCuePoint откладывает отправку cue point до момента, когда поток дойдет до offset от текущей позиции
+func (p *Publisher) ScheduleCuePoint(offset time.Duration, params flvio.AMFMap) {
+	p.pendingCue = true
+	p.cueInAt = p.lastTS + offset
+	p.cueInParams = params
+}
+
+// CancelScheduledCuePoint отменяет отложенный cue point и возвращает его параметры
+func (p *Publisher) CancelScheduledCuePoint() (flvio.AMFMap, bool) {
+	if !p.pendingCue {
+		return nil, false
+	}
+	p.pendingCue = false
+	return p.cueInParams, true
+}
+
+// WriteCuePoint отправляет AMF0 сообщение onCuePoint с текущим таймстампом потока
+func (p *Publisher) WriteCuePoint(name string, params flvio.AMFMap) error {
+	cue := flvio.AMFMap{
+		"name":       name,
+		"time":       p.lastTS.Seconds(),
+		"type":       "event",
+		"parameters": params,
+	}
+	return p.writeData([]interface{}{"onCuePoint", cue}, "err.write_cue")
+}
+
+// WriteMetadata отправляет AMF0 сообщение onMetaData с названием и описанием текущего файла
+func (p *Publisher) WriteMetadata(title, description string) error {
+	params := flvio.AMFMap{"title": title}
+	if description != "" {
+		params["description"] = description
+	}
+	return p.writeData([]interface{}{"onMetaData", params}, "err.write_metadata")
+}
+
+// writeData отправляет AMF0 data-сообщение с текущим таймстампом потока.
+// joy4 не дает записывать произвольные data-сообщения, поэтому буфер соединения
+// сбрасывается и сообщение пишется напрямую в сокет.
+func (p *Publisher) writeData(args []interface{}, errKey string) error {
+	if p.conn == nil {
+		return errNotConnected
+	}
+
+	if err := p.conn.WriteTrailer(); err != nil {
+		p.Close()
+		return fmt.Errorf("%w: %s: %w", ErrWrite, T("err.flush"), err)
+	}
+
+	size := 0
+	for _, arg := range args {
+		size += flvio.LenAMF0Val(arg)
+	}
+
+	ts := uint32(p.lastTS / time.Millisecond)
+	headerLength := rtmpHeaderLength
+	if ts > rtmpMaxTimestamp {
+		headerLength += rtmpExtTSLength
+	}
+
+	b := make([]byte, headerLength+size)
+	b[0] = dataMsgChunkID
+	putU24BE(b[1:], minU32(ts, rtmpMaxTimestamp))
+	putU24BE(b[4:], uint32(size))
+	b[7] = amf0DataMsgTypeID
+	msgsid := p.streamID()
+	b[8], b[9], b[10], b[11] = byte(msgsid), byte(msgsid>>8), byte(msgsid>>16), byte(msgsid>>24)
+	if ts > rtmpMaxTimestamp {
+		b[12], b[13], b[14], b[15] = byte(ts>>24), byte(ts>>16), byte(ts>>8), byte(ts)
+	}
+
+	n := headerLength
+	for _, arg := range args {
+		n += flvio.FillAMF0Val(b[n:], arg)
+	}
+
+	if _, err := p.conn.NetConn().Write(b[:n]); err != nil {
+		p.Close()
+		return fmt.Errorf("%w: %s: %w", ErrWrite, T(errKey), err)
+	}
+	return nil
+}
+
+// streamID возвращает идентификатор RTMP-потока публикации, выданный сервером.
+// Поле не экспортируется joy4, поэтому читаем его через reflect.
+func (p *Publisher) streamID() uint32 {
+	field := reflect.ValueOf(p.conn).Elem().FieldByName("avmsgsid")
+	if !field.IsValid() {
+		return 1
+	}
+	return uint32(field.Uint())
+}
+
+func putU24BE(b []byte, v uint32) {
+	b[0] = byte(v >> 16)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v)
+}
+
+func minU32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// errQueuePosition - позиция вне очереди
+var errQueuePosition = newCodedError("err.queue_position")
+
+// UpNextQueue - очередь оператора перед обычным порядком плейлиста. Файлы из очереди
+// играются раньше обычного порядка и не сдвигают его. Очередь сохраняется в файле
+// состояния канала и переживает перезапуск процесса.
+//
+// Позиции в методах считаются с 1, как их видит оператор. Файл, который сейчас играет
+// из очереди, в позиции не входит: он убирается из очереди при начале воспроизведения.
+type UpNextQueue struct {
+	mu      sync.Mutex
+	items   []string      // Ожидающие файлы
+	current string        // Файл из очереди, который сейчас играет (или прерван и будет продолжен)
+	changed chan struct{} // Сигнал для внеочередного сохранения состояния
+}
+
+// NewUpNextQueue создает пустую очередь
+func NewUpNextQueue() *UpNextQueue {
+	return &UpNextQueue{changed: make(chan struct{}, 1)}
+}
+
+// Add ставит файл в конец очереди
+func (q *UpNextQueue) Add(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(q.items, name)
+	q.notify()
+}
+
+// PlayNext ставит файл в начало очереди: он сыграет сразу после текущего файла
+func (q *UpNextQueue) PlayNext(name string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append([]string{name}, q.items...)
+	q.notify()
+}
+
+// Remove убирает файл на позиции position
+func (q *UpNextQueue) Remove(position int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if position < 1 || position > len(q.items) {
+		return fmt.Errorf("%w: %d", errQueuePosition, position)
+	}
+	q.items = append(q.items[:position-1], q.items[position:]...)
+	q.notify()
+	return nil
+}
+
+// Move переносит файл с позиции from на позицию to
+func (q *UpNextQueue) Move(from, to int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if from < 1 || from > len(q.items) {
+		return fmt.Errorf("%w: %d", errQueuePosition, from)
+	}
+	if to < 1 || to > len(q.items) {
+		return fmt.Errorf("%w: %d", errQueuePosition, to)
+	}
+	name := q.items[from-1]
+	q.items = append(q.items[:from-1], q.items[from:]...)
+	q.items = append(q.items[:to-1], append([]string{name}, q.items[to-1:]...)...)
+	q.notify()
+	return nil
+}
+
+// Items возвращает копию ожидающих файлов
+func (q *UpNextQueue) Items() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]string(nil), q.items...)
+}
+
+// Pending сообщает, есть ли в очереди файлы для воспроизведения, включая прерванный
+func (q *UpNextQueue) Pending() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items) > 0 || q.current != ""
+}
+
+// Next возвращает файл очереди для воспроизведения: прерванный файл очереди
+// или первый ожидающий, который при этом убирается из ожидающих
+func (q *UpNextQueue) Next() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.current == "" {
+		if len(q.items) == 0 {
+			return "", false
+		}
+		q.current = q.items[0]
+		q.items = q.items[1:]
+		q.notify()
+	}
+	return q.current, true
+}
+
+// Done отмечает, что файл очереди доигран или пропущен
+func (q *UpNextQueue) Done() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.current = ""
+	q.notify()
+}
+
+// State возвращает ожидающие файлы и играющий файл очереди для сохранения
+func (q *UpNextQueue) State() ([]string, string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]string(nil), q.items...), q.current
+}
+
+// Restore восстанавливает очередь из сохраненного состояния. Очередь в памяти
+// важнее сохраненной: после перезапуска канала супервизором она новее файла состояния.
+func (q *UpNextQueue) Restore(items []string, current string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) > 0 || q.current != "" || (len(items) == 0 && current == "") {
+		return false
+	}
+	q.items = append([]string(nil), items...)
+	q.current = current
+	return true
+}
+
+// Changed сигнализирует об изменении очереди
+func (q *UpNextQueue) Changed() <-chan struct{} {
+	return q.changed
+}
+
+// notify сообщает об изменении очереди, не дожидаясь читателя
+func (q *UpNextQueue) notify() {
+	select {
+	case q.changed <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newTestQueue создает очередь с файлами a..e
+func newTestQueue() *UpNextQueue {
+	q := NewUpNextQueue()
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		q.Add(name)
+	}
+	return q
+}
+
+func TestUpNextQueueMove(t *testing.T) {
+	tests := []struct {
+		from, to int
+		want     []string
+		wantErr  bool
+	}{
+		{from: 1, to: 3, want: []string{"b", "c", "a", "d", "e"}},
+		{from: 4, to: 2, want: []string{"a", "d", "b", "c", "e"}},
+		{from: 1, to: 5, want: []string{"b", "c", "d", "e", "a"}},
+		{from: 5, to: 1, want: []string{"e", "a", "b", "c", "d"}},
+		{from: 3, to: 3, want: []string{"a", "b", "c", "d", "e"}},
+		{from: 0, to: 2, wantErr: true},
+		{from: 6, to: 2, wantErr: true},
+		{from: 2, to: 0, wantErr: true},
+		{from: 2, to: 6, wantErr: true},
+	}
+	for _, tt := range tests {
+		q := newTestQueue()
+		err := q.Move(tt.from, tt.to)
+		if tt.wantErr {
+			if !errors.Is(err, errQueuePosition) {
+				t.Errorf("Move(%d, %d) error = %v, want errQueuePosition", tt.from, tt.to, err)
+			}
+			if got := q.Items(); !reflect.DeepEqual(got, []string{"a", "b", "c", "d", "e"}) {
+				t.Errorf("Move(%d, %d) changed queue on error: %v", tt.from, tt.to, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Move(%d, %d) unexpected error: %v", tt.from, tt.to, err)
+			continue
+		}
+		if got := q.Items(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Move(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestUpNextQueueRemove(t *testing.T) {
+	tests := []struct {
+		position int
+		want     []string
+		wantErr  bool
+	}{
+		{position: 1, want: []string{"b", "c", "d", "e"}},
+		{position: 3, want: []string{"a", "b", "d", "e"}},
+		{position: 5, want: []string{"a", "b", "c", "d"}},
+		{position: 0, wantErr: true},
+		{position: 6, wantErr: true},
+	}
+	for _, tt := range tests {
+		q := newTestQueue()
+		err := q.Remove(tt.position)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Remove(%d) error = %v, wantErr %v", tt.position, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(q.Items(), tt.want) {
+			t.Errorf("Remove(%d) = %v, want %v", tt.position, q.Items(), tt.want)
+		}
+	}
+}
+
+func TestUpNextQueueNext(t *testing.T) {
+	q := NewUpNextQueue()
+	q.Add("a")
+	q.PlayNext("b")
+
+	if name, ok := q.Next(); !ok || name != "b" {
+		t.Fatalf("Next() = %q, %v, want b", name, ok)
+	}
+	// Прерванный файл очереди продолжается до вызова Done
+	if name, _ := q.Next(); name != "b" {
+		t.Errorf("Next() before Done = %q, want b", name)
+	}
+	items, current := q.State()
+	if !reflect.DeepEqual(items, []string{"a"}) || current != "b" {
+		t.Errorf("State() = %v, %q, want [a], b", items, current)
+	}
+
+	q.Done()
+	if name, _ := q.Next(); name != "a" {
+		t.Errorf("Next() after Done = %q, want a", name)
+	}
+	if !q.Pending() {
+		t.Error("Pending() = false while a queued file is playing")
+	}
+	q.Done()
+	if name, ok := q.Next(); ok {
+		t.Errorf("Next() on empty queue = %q, want none", name)
+	}
+	if q.Pending() {
+		t.Error("Pending() = true on empty queue")
+	}
+}
+
+func TestUpNextQueueRestore(t *testing.T) {
+	tests := []struct {
+		name    string
+		queued  []string
+		items   []string
+		current string
+		want    bool
+	}{
+		{name: "empty queue", items: []string{"a", "b"}, current: "c", want: true},
+		{name: "only current", current: "c", want: true},
+		{name: "nothing saved", want: false},
+		{name: "queue in memory is newer", queued: []string{"x"}, items: []string{"a"}, want: false},
+	}
+	for _, tt := range tests {
+		q := NewUpNextQueue()
+		for _, name := range tt.queued {
+			q.Add(name)
+		}
+		if got := q.Restore(tt.items, tt.current); got != tt.want {
+			t.Errorf("%s: Restore() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		if !tt.want {
+			continue
+		}
+		items, current := q.State()
+		if !reflect.DeepEqual(items, tt.items) || current != tt.current {
+			t.Errorf("%s: State() = %v, %q, want %v, %q", tt.name, items, current, tt.items, tt.current)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Расширения файлов метаданных: video_1.mp4.json, video_1.mp4.yaml или video_1.mp4.yml.
+// Если рядом с файлом лежат несколько, используется первый по этому списку.
+var sidecarExtensions = []string{".json", ".yaml", ".yml"}
+
+// Форматы дат notBefore и notAfter
+const (
+	metaDateLayout = "2006-01-02" // Сутки по местному времени
+)
+
+// FileMeta - метаданные файла из файла рядом с ним. Пустые поля не меняют поведение канала.
+type FileMeta struct {
+	Title       string   `json:"title" yaml:"title"`             // Название для потока, журнала эфира и программы передач
+	Description string   `json:"description" yaml:"description"` // Описание для метаданных потока
+	In          string   `json:"in" yaml:"in"`                   // Точка начала (ЧЧ:ММ:СС, ММ:СС или секунды)
+	Out         string   `json:"out" yaml:"out"`                 // Точка окончания, пусто = до конца файла
+	Tags        []string `json:"tags" yaml:"tags"`               // Метки: событие file.started, журнал эфира и категории программы передач
+	Weight      *float64 `json:"weight" yaml:"weight"`           // Вес в режиме weighted, если не задан в video.weights
+	MinPlayTime int      `json:"minPlayTime" yaml:"minPlayTime"` // Минимальное время воспроизведения в секундах, 0 = из настроек
+	NotBefore   string   `json:"notBefore" yaml:"notBefore"`     // Не играть раньше: ГГГГ-ММ-ДД или RFC3339
+	NotAfter    string   `json:"notAfter" yaml:"notAfter"`       // Не играть позже: ГГГГ-ММ-ДД (включительно) или RFC3339
+
+	Path      string        `json:"-" yaml:"-"` // Файл метаданных
+	InPoint   time.Duration `json:"-" yaml:"-"`
+	OutPoint  time.Duration `json:"-" yaml:"-"`
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+// loadFileMeta читает метаданные файла videoPath. Без файла метаданных возвращает nil.
+func loadFileMeta(videoPath string) (*FileMeta, error) {
+	for _, ext := range sidecarExtensions {
+		path := videoPath + ext
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		meta := &FileMeta{Path: path}
+		if ext == ".json" {
+			err = json.Unmarshal(data, meta)
+		} else {
+			err = yaml.Unmarshal(data, meta)
+		}
+		if err == nil {
+			err = meta.parse()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		return meta, nil
+	}
+	return nil, nil
+}
+
+// parse разбирает таймкоды и даты и проверяет значения
+func (m *FileMeta) parse() error {
+	if m.Weight != nil && *m.Weight < 0 {
+		return fmt.Errorf("%s: %v", T("err.meta_weight"), *m.Weight)
+	}
+	var tags []string
+	for _, tag := range m.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	m.Tags = tags
+
+	var err error
+	if m.In != "" {
+		if m.InPoint, err = parseTimecode(strings.TrimSpace(m.In)); err != nil {
+			return err
+		}
+	}
+	if m.Out != "" {
+		if m.OutPoint, err = parseTimecode(strings.TrimSpace(m.Out)); err != nil {
+			return err
+		}
+		if m.OutPoint <= m.InPoint {
+			return fmt.Errorf("%s: %s", T("err.meta_out_point"), m.Out)
+		}
+	}
+	if m.NotBefore != "" {
+		if m.notBefore, err = parseMetaDate(m.NotBefore, false); err != nil {
+			return err
+		}
+	}
+	if m.NotAfter != "" {
+		if m.notAfter, err = parseMetaDate(m.NotAfter, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseMetaDate разбирает дату; дата без времени в notAfter означает конец этих суток
+func parseMetaDate(value string, endOfDay bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.ParseInLocation(metaDateLayout, value, time.Local); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%s: %s", T("err.meta_date"), value)
+}
+
+// Available сообщает, можно ли играть файл в момент now
+func (m *FileMeta) Available(now time.Time) bool {
+	if m == nil {
+		return true
+	}
+	if !m.notBefore.IsZero() && now.Before(m.notBefore) {
+		return false
+	}
+	if !m.notAfter.IsZero() && !now.Before(m.notAfter) {
+		return false
+	}
+	return true
+}
+
+// TitleOr возвращает название из метаданных или fallback, если его нет
+func (m *FileMeta) TitleOr(fallback string) string {
+	if m == nil || m.Title == "" {
+		return fallback
+	}
+	return m.Title
+}
+
+// TagList возвращает метки файла, nil если метаданных нет
+func (m *FileMeta) TagList() []string {
+	if m == nil {
+		return nil
+	}
+	return m.Tags
+}
+
+// sidecarStamp возвращает размер и время изменения файла метаданных, пусто если его нет
+func sidecarStamp(videoPath string) string {
+	for _, ext := range sidecarExtensions {
+		if info, err := os.Stat(videoPath + ext); err == nil {
+			return fmt.Sprintf("%s:%d:%d", ext, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return ""
+}
+
+// sidecarVideo возвращает имя видеофайла для имени файла метаданных
+func sidecarVideo(name string) (string, bool) {
+	for _, ext := range sidecarExtensions {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			video := name[:len(name)-len(ext)]
+			if isVideoFile(video) {
+				return video, true
+			}
+		}
+	}
+	return "", false
+}
+
+// excludeUnavailable убирает из списка файлы, которые по метаданным нельзя играть в момент now
+func excludeUnavailable(files []os.DirEntry, meta func(name string) *FileMeta, now time.Time) []os.DirEntry {
+	var result []os.DirEntry
+	for _, file := range files {
+		if meta(file.Name()).Available(now) {
+			result = append(result, file)
+		}
+	}
+	return result
+}
+
+// metaWeights собирает веса файлов из метаданных для режима weighted
+func metaWeights(files []os.DirEntry, meta func(name string) *FileMeta) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, file := range files {
+		if m := meta(file.Name()); m != nil && m.Weight != nil {
+			weights[file.Name()] = *m.Weight
+		}
+	}
+	return weights
+}
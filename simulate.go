@@ -0,0 +1,660 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	defaultSimulateHours = 24               // Горизонт симуляции по умолчанию
+	earlyEndMinElapsed   = 30 * time.Second // Раньше этого раннее завершение не срабатывает (см. streamPacketsSync)
+	loopRestartPause     = time.Second      // Пауза перед новым кругом в Run
+)
+
+// Типы элементов симулированного эфира
+const (
+	SimFile         = "file"         // Файл каталога
+	SimInterstitial = "interstitial" // Служебный ролик
+	SimAd           = "ad"           // Рекламная пауза с локальными роликами
+	SimSlate        = "slate"        // Заставка
+	SimOffAir       = "off_air"      // Вне часов вещания
+	SimGap          = "gap"          // Эфир пуст: нет контента или соединение закрыто
+)
+
+// Отметки элементов симулированного эфира
+const (
+	SimFlagEarlyEnd   = "early_end"   // Файл завершится раньше конца
+	SimFlagOverrun    = "overrun"     // Элемент не помещается до закрытия окна вещания
+	SimFlagSkipped    = "skipped"     // Файл не читается и будет пропущен
+	SimFlagMissing    = "missing"     // Служебный ролик не найден
+	SimFlagResumed    = "resumed"     // Файл продолжается с сохраненной позиции
+	SimFlagNoFiles    = "no_files"    // Каталог видео пуст
+	SimFlagNoPlayable = "no_playable" // Ни один файл каталога не подходит для эфира
+	SimFlagEnd        = "end"         // Однократное проигрывание закончилось
+)
+
+// Ключи каталога сообщений для типов и отметок в таблице
+var (
+	simKindLabels = map[string]string{
+		SimFile: "sim.kind_file", SimInterstitial: "sim.kind_interstitial", SimAd: "sim.kind_ad",
+		SimSlate: "sim.kind_slate", SimOffAir: "sim.kind_off_air", SimGap: "sim.kind_gap",
+	}
+	simFlagLabels = map[string]string{
+		SimFlagEarlyEnd: "sim.flag_early_end", SimFlagOverrun: "sim.flag_overrun", SimFlagSkipped: "sim.flag_skipped",
+		SimFlagMissing: "sim.flag_missing", SimFlagResumed: "sim.flag_resumed", SimFlagNoFiles: "sim.flag_no_files",
+		SimFlagEnd: "sim.flag_end", SimFlagNoPlayable: "sim.flag_no_playable",
+	}
+)
+
+// SimulatedItem - элемент симулированного эфира; длительности и позиции в секундах
+type SimulatedItem struct {
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+	Kind     string    `json:"kind"`
+	File     string    `json:"file,omitempty"`
+	Title    string    `json:"title,omitempty"`    // Название файла из его метаданных
+	Tags     []string  `json:"tags,omitempty"`     // Метки файла из его метаданных
+	Position float64   `json:"position,omitempty"` // Позиция начала внутри файла
+	Flags    []string  `json:"flags,omitempty"`
+}
+
+// SimulationSummary - итоги симуляции в секундах и количествах
+type SimulationSummary struct {
+	Content       float64 `json:"content"`
+	Interstitials float64 `json:"interstitials"`
+	Ads           float64 `json:"ads"`
+	Slate         float64 `json:"slate"`
+	OffAir        float64 `json:"offAir"`
+	Gaps          float64 `json:"gaps"`
+	Overruns      int     `json:"overruns"`
+	Skipped       int     `json:"skipped"`
+	EarlyEnds     int     `json:"earlyEnds"`
+}
+
+// Simulation - расписание эфира канала на заданный срок
+type Simulation struct {
+	Channel string            `json:"channel"`
+	Start   time.Time         `json:"start"`
+	End     time.Time         `json:"end"`
+	Items   []SimulatedItem   `json:"items"`
+	Summary SimulationSummary `json:"summary"`
+}
+
+// simulator проигрывает логику выбора файлов канала по модельным часам, без сети.
+// Используются те же плейлист, очередь оператора, служебные ролики, рекламные паузы
+// и часы вещания, что и в Channel.Run; длительности и ключевые кадры берутся из каталога
+// медиафайлов, а для файлов без записи в нем - из заголовков файлов.
+type simulator struct {
+	config        *Config
+	clock         time.Time
+	end           time.Time
+	minPlay       time.Duration
+	ads           *AdScheduler
+	interstitials *Interstitials
+	schedule      *OnAirSchedule
+	media         *MediaCatalog
+	durations     map[string]time.Duration
+	metas         map[string]*FileMeta // Метаданные файлов каталога по имени
+	live          bool                 // Канал работает, файл из состояния уже в эфире
+	result        *Simulation
+}
+
+// simulateChannel строит расписание эфира канала на период [start, end).
+// Если state задано, симуляция начинается с его файла, позиции, порядка и очереди оператора.
+// live=true - канал работает и файл из состояния уже в эфире: он доигрывается раньше
+// очереди оператора и без служебных роликов перед ним, как в работающем Channel.Run.
+// media может отсутствовать, тогда длительности берутся из заголовков файлов.
+func simulateChannel(config *Config, start, end time.Time, state *StreamState, live bool, media *MediaCatalog) (*Simulation, error) {
+	schedule, err := NewOnAirSchedule(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := NewChannel(config, nil)
+	s := &simulator{
+		config:        config,
+		clock:         start,
+		end:           end,
+		minPlay:       minPlayTime,
+		ads:           NewAdScheduler(config, ch.Log),
+		interstitials: NewInterstitials(config, ch.Log),
+		schedule:      schedule,
+		media:         media,
+		durations:     make(map[string]time.Duration),
+		metas:         make(map[string]*FileMeta),
+		live:          live,
+		result:        &Simulation{Channel: config.Name, Start: start, End: end},
+	}
+	if config.Settings.MinPlayTime > 0 {
+		s.minPlay = time.Duration(config.Settings.MinPlayTime) * time.Second
+	}
+	s.interstitials.now = func() time.Time { return s.clock }
+	s.interstitials.lastStationID = start
+
+	playlist := NewPlaylist(config, ch.Log)
+	if state != nil {
+		playlist.Restore(state.Playlist)
+		ch.Queue.Restore(state.Queue, state.QueueCurrent)
+	}
+
+	s.run(ch, playlist, state)
+	s.summarize()
+	return s.result, nil
+}
+
+// run повторяет цикл выбора файлов из Channel.Run
+func (s *simulator) run(ch *Channel, playlist *Playlist, state *StreamState) {
+	config := s.config
+	videoDir := config.Video.Directory
+	all, err := listVideoFiles(videoDir)
+	if err != nil {
+		ch.Log.Warn("catalog.read_failed", "dir", videoDir, "error", err)
+	}
+
+	played := make(map[string]bool)
+	if !config.Video.LoopMode {
+		for _, name := range playlist.Played() {
+			played[name] = true
+		}
+	}
+
+	// Позиция продолжения: из состояния или после прерывания закрытием окна вещания
+	var resumeFile, seekFile, liveFile string
+	var resumePos time.Duration
+	if state != nil {
+		resumeFile, resumePos = state.CurrentFile, state.Position
+		if state.CurrentFile != state.QueueCurrent {
+			seekFile = state.CurrentFile
+		}
+		if s.live {
+			liveFile = state.CurrentFile
+		}
+	}
+
+	for s.clock.Before(s.end) {
+		if s.waitOffAir() {
+			continue
+		}
+
+		files := all
+		if !config.Video.LoopMode && len(played) > 0 {
+			files = excludePlayed(all, played)
+			// Как в Channel.Run: очередь оператора выходит в эфир и после того, как каталог проигран
+			if len(files) == 0 && playlist.LoopDone() && !ch.Queue.Pending() {
+				s.endAction()
+				return
+			}
+		}
+		files = excludeUnavailable(files, s.meta, s.clock)
+		playlist.FileWeights = metaWeights(files, s.meta)
+		if len(files) == 0 && !ch.Queue.Pending() {
+			// Каталог в симуляции не меняется: до конца периода в эфире заставка или пустота
+			s.fill(SimFlagNoFiles)
+			return
+		}
+		if len(files) > 0 {
+			playlist.Refresh(fileNames(files))
+		}
+		fromStart := playlist.Index() == 0
+		attempted := false // В этом круге хотя бы один файл дошел до попытки эфира
+		if seekFile != "" {
+			// Как в Channel.Run: порядок продолжается с файла из состояния, если он еще есть
+			if name, ok := playlist.Current(); !(ok && name == seekFile) && !playlist.Seek(seekFile) {
+				resumeFile = ""
+			}
+			seekFile = ""
+		}
+
+		for s.clock.Before(s.end) {
+			if s.waitOffAir() {
+				continue
+			}
+
+			// Файл в эфире работающего канала доигрывается раньше очереди оператора,
+			// а очередь играет и после конца круга
+			name, ok := playlist.Current()
+			queued, fromQueue := "", false
+			if liveFile == "" || liveFile != name {
+				queued, fromQueue = ch.Queue.Next()
+			}
+			if !ok && !fromQueue {
+				break
+			}
+			if fromQueue {
+				name = queued
+			}
+			onAir := liveFile != "" && liveFile == name
+			liveFile = ""
+			// Как finishFile в Channel.Run: проигранный и пропущенный файлы одинаково
+			// завершают круг; true - круг закончен
+			finish := func() bool {
+				if fromQueue {
+					ch.Queue.Done()
+					return false
+				}
+				played[name] = true
+				playlist.Advance()
+				if !playlist.LoopDone() {
+					return false
+				}
+				if config.Video.LoopMode {
+					s.clock = s.clock.Add(loopRestartPause)
+				}
+				return true
+			}
+			meta := s.meta(name)
+			if findDirEntry(all, name) == nil || !meta.Available(s.clock) {
+				if finish() {
+					break
+				}
+				continue
+			}
+
+			startPos := time.Duration(0)
+			if name == resumeFile {
+				startPos = resumePos
+				resumeFile = ""
+			}
+			resumed := startPos > 0
+			if meta != nil && startPos < meta.InPoint {
+				startPos = meta.InPoint
+			}
+
+			path := filepath.Join(videoDir, name)
+			entry := s.media.Lookup(path)
+			if !entry.Playable() {
+				// Как в Channel.Run: файл, не прошедший пробу, пропускается без попыток
+				s.add(SimGap, name, 0, 0, SimFlagSkipped)
+				if finish() {
+					break
+				}
+				continue
+			}
+			attempted = true
+			duration := s.duration(path)
+			if duration <= 0 {
+				// Все попытки не удадутся: между ними и после них заставка или пустота
+				s.add(SimGap, name, time.Duration(maxRetries-1)*retryDelay*time.Second, 0, SimFlagSkipped)
+				s.addSlate()
+				if finish() {
+					break
+				}
+				continue
+			}
+			if meta != nil && meta.OutPoint > 0 && meta.OutPoint < duration {
+				duration = meta.OutPoint
+			}
+
+			if !resumed && !onAir {
+				s.playClips(s.interstitials.Before(name))
+			}
+			if cutAt, cut := s.playFile(name, startPos, duration, entry, meta); cut {
+				resumeFile, resumePos = name, cutAt
+				continue
+			}
+			s.playClips(s.interstitials.After(name))
+			if breakDuration, ok := s.ads.BreakAfterFile(name); ok && s.ads.InsertAds {
+				s.add(SimAd, "", breakDuration, 0)
+			}
+
+			if finish() {
+				break
+			}
+		}
+
+		// Ни один файл круга не дошел до эфира; каталог в симуляции не меняется, поэтому
+		// и следующие круги будут пропущены: до конца периода в эфире заставка или пустота
+		if fromStart && !attempted && config.Video.LoopMode {
+			s.fill(SimFlagNoPlayable)
+			return
+		}
+	}
+}
+
+// playFile добавляет файл с рекламными паузами внутри него. Файл прерывается
+// ранним завершением или закрытием окна вещания; во втором случае возвращается
+// позиция, с которой файл продолжится.
+// duration уже ограничена точкой окончания из метаданных.
+func (s *simulator) playFile(name string, startPos, duration time.Duration, entry *MediaEntry, meta *FileMeta) (time.Duration, bool) {
+	end := duration
+	earlyEnd := false
+	minPlay := s.minPlay
+	if meta != nil && meta.MinPlayTime > 0 {
+		minPlay = time.Duration(meta.MinPlayTime) * time.Second
+	}
+	if !s.config.Settings.DisableEarlyEnd && (entry.Duration() > 0 || (meta != nil && meta.OutPoint > 0)) {
+		// Длительность известна из каталога или точки окончания: файл завершается на первом
+		// ключевом кадре за preloadNextFileTime до конца, но не раньше минимального времени
+		after := duration - preloadNextFileTime
+		if earliest := startPos + minPlay; earliest > after {
+			after = earliest
+		}
+		at, ok := after, true
+		if entry.Duration() > 0 {
+			at, ok = entry.KeyframeAfter(after)
+		}
+		if ok && at > preloadNextFileTime && at < end {
+			end = at
+			earlyEnd = true
+		}
+	} else if !s.config.Settings.DisableEarlyEnd {
+		// Раннее завершение срабатывает, когда прошло и минимальное время, и 30 секунд эфира файла
+		after := minPlay
+		if after < earlyEndMinElapsed {
+			after = earlyEndMinElapsed
+		}
+		if startPos+after < end {
+			end = startPos + after
+			earlyEnd = true
+		}
+	}
+
+	var flags []string
+	if startPos > 0 && (meta == nil || startPos != meta.InPoint) {
+		flags = append(flags, SimFlagResumed)
+	}
+	pos := startPos
+	for _, adBreak := range s.ads.BreaksInFile(name) {
+		if !s.ads.InsertAds || adBreak.At <= pos || adBreak.At >= end {
+			continue
+		}
+		if cutAt, cut := s.playSegment(name, pos, adBreak.At, flags); cut {
+			return cutAt, true
+		}
+		s.add(SimAd, name, adBreak.Duration, 0)
+		pos, flags = adBreak.At, nil
+	}
+	if earlyEnd {
+		flags = append(flags, SimFlagEarlyEnd)
+	}
+	return s.playSegment(name, pos, end, flags)
+}
+
+// playSegment добавляет часть файла от from до to, если окно вещания не закроется раньше
+func (s *simulator) playSegment(name string, from, to time.Duration, flags []string) (time.Duration, bool) {
+	if closeAt, ok := s.windowCloses(to - from); ok {
+		aired := closeAt.Sub(s.clock)
+		s.add(SimFile, name, aired, from, append(flags, SimFlagOverrun)...)
+		return from + aired, true
+	}
+	s.add(SimFile, name, to-from, from, flags...)
+	return 0, false
+}
+
+// playClips добавляет служебные ролики. Ролики не прерываются закрытием окна вещания,
+// поэтому ролик, выходящий за окно, отмечается как превышение.
+func (s *simulator) playClips(clips []string) {
+	for _, clip := range clips {
+		duration := s.duration(filepath.Join(s.interstitials.Directory, clip))
+		if duration <= 0 {
+			s.add(SimInterstitial, clip, 0, 0, SimFlagMissing)
+			continue
+		}
+		var flags []string
+		if _, ok := s.windowCloses(duration); ok {
+			flags = append(flags, SimFlagOverrun)
+		}
+		s.add(SimInterstitial, clip, duration, 0, flags...)
+	}
+}
+
+// waitOffAir добавляет период вне часов вещания до открытия окна
+func (s *simulator) waitOffAir() bool {
+	if s.schedule.OnAir(s.clock) {
+		return false
+	}
+	until := s.end
+	if next, ok := s.schedule.NextChange(s.clock); ok && next.Before(s.end) {
+		until = next
+	}
+	s.add(SimOffAir, "", until.Sub(s.clock), 0)
+	return true
+}
+
+// windowCloses сообщает, закроется ли окно вещания раньше, чем пройдет length
+func (s *simulator) windowCloses(length time.Duration) (time.Time, bool) {
+	if s.schedule == nil {
+		return time.Time{}, false
+	}
+	next, ok := s.schedule.NextChange(s.clock)
+	if ok && next.Before(s.clock.Add(length)) {
+		return next, true
+	}
+	return time.Time{}, false
+}
+
+// endAction добавляет действие после однократного проигрывания
+func (s *simulator) endAction() {
+	switch s.config.Video.EndAction {
+	case EndActionSlate:
+		if s.config.Slate.File != "" {
+			s.add(SimSlate, s.config.Slate.File, s.end.Sub(s.clock), 0, SimFlagEnd)
+			return
+		}
+		s.add(SimGap, "", s.end.Sub(s.clock), 0, SimFlagEnd)
+	case EndActionWait:
+		s.add(SimGap, "", s.end.Sub(s.clock), 0, SimFlagEnd)
+	default:
+		s.add(SimGap, "", 0, 0, SimFlagEnd)
+	}
+}
+
+// fill заполняет остаток периода заставкой или пустотой
+func (s *simulator) fill(flag string) {
+	kind := SimGap
+	if s.config.Slate.File != "" && s.duration(s.config.Slate.File) > 0 {
+		kind = SimSlate
+	}
+	s.add(kind, s.config.Slate.File, s.end.Sub(s.clock), 0, flag)
+}
+
+// addSlate добавляет заставку после неудачного файла, если она настроена
+func (s *simulator) addSlate() {
+	if s.config.Slate.File == "" {
+		return
+	}
+	if duration := s.duration(s.config.Slate.File); duration > 0 {
+		s.add(SimSlate, s.config.Slate.File, duration, 0)
+	}
+}
+
+// duration возвращает длительность файла из каталога медиафайлов или по заголовку MP4, 0 если файл не читается
+func (s *simulator) duration(path string) time.Duration {
+	if d, ok := s.durations[path]; ok {
+		return d
+	}
+	d := s.media.Duration(path)
+	s.durations[path] = d
+	return d
+}
+
+// meta возвращает метаданные файла каталога видео, nil если их нет
+func (s *simulator) meta(name string) *FileMeta {
+	if meta, ok := s.metas[name]; ok {
+		return meta
+	}
+	meta, err := loadFileMeta(filepath.Join(s.config.Video.Directory, name))
+	if err != nil {
+		logWarn("meta.invalid", "file", name, "error", err)
+	}
+	s.metas[name] = meta
+	return meta
+}
+
+// add добавляет элемент эфира и сдвигает модельные часы
+func (s *simulator) add(kind, file string, duration, position time.Duration, flags ...string) {
+	item := SimulatedItem{
+		Start:    s.clock,
+		Duration: duration.Seconds(),
+		Kind:     kind,
+		File:     file,
+		Position: position.Seconds(),
+		Flags:    flags,
+	}
+	if kind == SimFile {
+		item.Title = s.meta(file).TitleOr("")
+		item.Tags = s.meta(file).TagList()
+	}
+	s.result.Items = append(s.result.Items, item)
+	s.clock = s.clock.Add(duration)
+}
+
+// summarize подсчитывает итоги по элементам
+func (s *simulator) summarize() {
+	sum := &s.result.Summary
+	for _, item := range s.result.Items {
+		switch item.Kind {
+		case SimFile:
+			sum.Content += item.Duration
+		case SimInterstitial:
+			sum.Interstitials += item.Duration
+		case SimAd:
+			sum.Ads += item.Duration
+		case SimSlate:
+			sum.Slate += item.Duration
+		case SimOffAir:
+			sum.OffAir += item.Duration
+		case SimGap:
+			sum.Gaps += item.Duration
+		}
+		for _, flag := range item.Flags {
+			switch flag {
+			case SimFlagOverrun:
+				sum.Overruns++
+			case SimFlagSkipped:
+				sum.Skipped++
+			case SimFlagEarlyEnd:
+				sum.EarlyEnds++
+			}
+		}
+	}
+}
+
+// runSimulate выполняет подкоманду simulate и возвращает код завершения процесса.
+//
+//	rtmp-streamer simulate [--hours N] [--start время] [--channel имя] [--fresh] [--json]
+func runSimulate(args []string) int {
+	config, err := loadConfig(configFilePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", T("config.load_failed"), err)
+		return 1
+	}
+	setupLocale(config)
+	// В выводе симуляции остаются только предупреждения о конфигурации и файлах
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	hours := fs.Float64("hours", defaultSimulateHours, T("flag.sim_hours"))
+	startText := fs.String("start", "", T("flag.sim_start"))
+	channel := fs.String("channel", "", T("flag.sim_channel"))
+	fresh := fs.Bool("fresh", false, T("flag.sim_fresh"))
+	asJSON := fs.Bool("json", false, T("flag.sim_json"))
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), T("sim.usage"))
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *hours <= 0 {
+		fs.Usage()
+		return 2
+	}
+
+	start := time.Now()
+	if *startText != "" {
+		if start, err = time.Parse(time.RFC3339, *startText); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", T("err.sim_start"), *startText)
+			return 2
+		}
+	}
+	end := start.Add(time.Duration(*hours * float64(time.Hour)))
+
+	configs, err := channelConfigs(config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", T("config.load_failed"), err)
+		return 1
+	}
+	// Индекс только читается: пробы выполняет работающий процесс
+	var media *MediaCatalog
+	if config.Media.Enabled {
+		media = NewMediaCatalog(config)
+	}
+	var simulations []*Simulation
+	for _, channelConfig := range configs {
+		if *channel != "" && channelConfig.Name != *channel {
+			continue
+		}
+		var state *StreamState
+		if channelConfig.Settings.RestoreState && !*fresh {
+			ch := NewChannel(channelConfig, nil)
+			ch.Media = media
+			if state, err = loadStreamState(ch); err != nil {
+				ch.Log.Warn("state.load_failed", "error", err)
+			}
+			if files, err := listVideoFiles(channelConfig.Video.Directory); err == nil {
+				restoreStateFile(ch, state, channelConfig.Video.Directory, files)
+			}
+		}
+		simulation, err := simulateChannel(channelConfig, start, end, state, false, media)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", channelConfig.Name, err)
+			return 1
+		}
+		simulations = append(simulations, simulation)
+	}
+	if len(simulations) == 0 {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", T("err.channel_unknown"), *channel)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(simulations)
+		return 0
+	}
+	for i, simulation := range simulations {
+		if i > 0 {
+			fmt.Println()
+		}
+		printSimulation(os.Stdout, simulation)
+	}
+	return 0
+}
+
+// printSimulation выводит расписание эфира таблицей
+func printSimulation(w io.Writer, simulation *Simulation) {
+	fmt.Fprintln(w, T("sim.title", "name", simulation.Channel,
+		"start", simulation.Start.Format("2006-01-02 15:04"), "end", simulation.End.Format("2006-01-02 15:04")))
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", T("sim.col_start"), T("sim.col_duration"), T("sim.col_kind"),
+		T("sim.col_file"), T("sim.col_notes"))
+	for _, item := range simulation.Items {
+		file := item.File
+		if item.Position > 0 {
+			file += " @" + tuiTime(item.Position)
+		}
+		var notes []string
+		for _, flag := range item.Flags {
+			notes = append(notes, T(simFlagLabels[flag]))
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", item.Start.Format("01-02 15:04:05"), tuiTime(item.Duration),
+			T(simKindLabels[item.Kind]), file, strings.Join(notes, ", "))
+	}
+	table.Flush()
+
+	sum := simulation.Summary
+	fmt.Fprintln(w, T("sim.summary", "content", tuiTime(sum.Content), "interstitials", tuiTime(sum.Interstitials),
+		"ads", tuiTime(sum.Ads), "slate", tuiTime(sum.Slate), "off_air", tuiTime(sum.OffAir), "gaps", tuiTime(sum.Gaps)))
+	fmt.Fprintln(w, T("sim.problems", "overruns", sum.Overruns, "skipped", sum.Skipped, "early_ends", sum.EarlyEnds))
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testSimulateStart = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+// testVideo - файл каталога видео для симуляции
+type testVideo struct {
+	name      string
+	duration  time.Duration
+	keyframes time.Duration // Интервал ключевых кадров, 0 = без индекса
+	invalid   bool          // Файл не прошел пробу
+	sidecar   string        // Метаданные файла в JSON
+}
+
+// newTestSimulation создает каталог видео и каталог медиафайлов с заданными длительностями
+func newTestSimulation(t *testing.T, videos []testVideo) (*Config, *MediaCatalog) {
+	t.Helper()
+	dir := t.TempDir()
+	media := &MediaCatalog{entries: make(map[string]*MediaEntry)}
+	for _, video := range videos {
+		path := filepath.Join(dir, video.name)
+		if err := os.WriteFile(path, []byte(video.name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if video.sidecar != "" {
+			if err := os.WriteFile(path+".json", []byte(video.sidecar), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := &MediaEntry{Path: mediaPath(path), Size: info.Size(), ModTime: info.ModTime(), Valid: !video.invalid}
+		if video.invalid {
+			entry.Reason = RejectDemuxFailed
+		} else {
+			entry.Probe = &ProbeResult{Duration: video.duration}
+		}
+		for at := time.Duration(0); video.keyframes > 0 && at < video.duration; at += video.keyframes {
+			entry.Keyframes = append(entry.Keyframes, at)
+		}
+		media.entries[entry.Path] = entry
+	}
+
+	config := &Config{Name: "test"}
+	config.Video.Directory = dir
+	config.Video.LoopMode = true
+	config.Settings.DisableEarlyEnd = true
+	return config, media
+}
+
+// describeSimulation записывает элементы эфира строками: смещение, тип, файл, длительность@позиция, отметки
+func describeSimulation(simulation *Simulation) []string {
+	var items []string
+	for _, item := range simulation.Items {
+		file := item.File
+		if file != "" {
+			file = filepath.Base(file)
+		}
+		items = append(items, fmt.Sprintf("%v %s %s %gs@%gs %v",
+			item.Start.Sub(simulation.Start), item.Kind, file, item.Duration, item.Position, item.Flags))
+	}
+	return items
+}
+
+// simulateWithin выполняет симуляцию от testSimulateStart; зависшая симуляция проваливает тест
+func simulateWithin(t *testing.T, config *Config, length time.Duration, state *StreamState, media *MediaCatalog) (*Simulation, error) {
+	t.Helper()
+	type result struct {
+		simulation *Simulation
+		err        error
+	}
+	done := make(chan result, 1)
+	go func() {
+		simulation, err := simulateChannel(config, testSimulateStart, testSimulateStart.Add(length), state, false, media)
+		done <- result{simulation, err}
+	}()
+	select {
+	case r := <-done:
+		return r.simulation, r.err
+	case <-time.After(10 * time.Second):
+		t.Fatalf("simulateChannel did not return within 10s")
+		return nil, nil
+	}
+}
+
+func TestSimulateChannel(t *testing.T) {
+	twoFiles := []testVideo{{name: "a.mp4", duration: time.Minute}, {name: "b.mp4", duration: 90 * time.Second}}
+	tests := []struct {
+		name   string
+		videos []testVideo
+		setup  func(config *Config)
+		state  *StreamState
+		length time.Duration
+		want   []string
+	}{
+		{
+			name:   "loop with pause between loops",
+			videos: twoFiles,
+			length: 5 * time.Minute,
+			want: []string{
+				"0s file a.mp4 60s@0s []",
+				"1m0s file b.mp4 90s@0s []",
+				"2m31s file a.mp4 60s@0s []",
+				"3m31s file b.mp4 90s@0s []",
+			},
+		},
+		{
+			name:   "early end on keyframe",
+			videos: []testVideo{{name: "a.mp4", duration: 2 * time.Minute, keyframes: 4 * time.Second}},
+			setup:  func(config *Config) { config.Settings.DisableEarlyEnd = false },
+			length: 200 * time.Second,
+			want: []string{
+				"0s file a.mp4 116s@0s [early_end]",
+				"1m57s file a.mp4 116s@0s [early_end]",
+			},
+		},
+		{
+			name:   "play once then slate",
+			videos: twoFiles,
+			setup: func(config *Config) {
+				config.Video.LoopMode = false
+				config.Video.EndAction = EndActionSlate
+				config.Slate.File = "slate.mp4"
+			},
+			length: 10 * time.Minute,
+			want: []string{
+				"0s file a.mp4 60s@0s []",
+				"1m0s file b.mp4 90s@0s []",
+				"2m30s slate slate.mp4 450s@0s [end]",
+			},
+		},
+		{
+			name:   "resume from state",
+			videos: twoFiles,
+			state: &StreamState{CurrentFile: "b.mp4", Position: 30 * time.Second,
+				Playlist: &PlaylistState{Mode: PlaybackSequential, Order: []string{"a.mp4", "b.mp4"}, Index: 1}},
+			length: 100 * time.Second,
+			want: []string{
+				"0s file b.mp4 60s@30s [resumed]",
+				"1m1s file a.mp4 60s@0s []",
+			},
+		},
+		{
+			name:   "operator queue before playlist",
+			videos: twoFiles,
+			state:  &StreamState{Queue: []string{"b.mp4"}},
+			length: 3 * time.Minute,
+			want: []string{
+				"0s file b.mp4 90s@0s []",
+				"1m30s file a.mp4 60s@0s []",
+				"2m30s file b.mp4 90s@0s []",
+			},
+		},
+		{
+			name:   "operator queue after play once",
+			videos: twoFiles,
+			setup: func(config *Config) {
+				config.Video.LoopMode = false
+				config.Video.EndAction = EndActionWait
+			},
+			state: &StreamState{Queue: []string{"a.mp4"},
+				Playlist: &PlaylistState{Mode: PlaybackSequential, Order: []string{"a.mp4", "b.mp4"}, Index: 2}},
+			length: 5 * time.Minute,
+			want: []string{
+				"0s file a.mp4 60s@0s []",
+				"1m0s gap  240s@0s [end]",
+			},
+		},
+		{
+			name:   "on-air window cuts file",
+			videos: []testVideo{{name: "a.mp4", duration: 90 * time.Second}},
+			setup: func(config *Config) {
+				config.OnAir.Enabled = true
+				config.OnAir.Timezone = "UTC"
+				config.OnAir.Windows = []OnAirWindow{{Start: "00:01", End: "00:03"}}
+			},
+			length: 4 * time.Minute,
+			want: []string{
+				"0s off_air  60s@0s []",
+				"1m0s file a.mp4 90s@0s []",
+				"2m31s file a.mp4 29s@0s [overrun]",
+				"3m0s off_air  60s@0s []",
+			},
+		},
+		{
+			name:   "unplayable file skipped",
+			videos: []testVideo{{name: "a.mp4", invalid: true}, {name: "b.mp4", duration: time.Minute}},
+			length: 100 * time.Second,
+			want: []string{
+				"0s gap a.mp4 0s@0s [skipped]",
+				"0s file b.mp4 60s@0s []",
+				"1m1s gap a.mp4 0s@0s [skipped]",
+				"1m1s file b.mp4 60s@0s []",
+			},
+		},
+		{
+			name:   "every file unplayable",
+			videos: []testVideo{{name: "a.mp4", invalid: true}, {name: "b.mp4", invalid: true}},
+			length: time.Hour,
+			want: []string{
+				"0s gap a.mp4 0s@0s [skipped]",
+				"0s gap b.mp4 0s@0s [skipped]",
+				"1s gap  3599s@0s [no_playable]",
+			},
+		},
+		{
+			name:   "every file unplayable, play once",
+			videos: []testVideo{{name: "a.mp4", invalid: true}, {name: "b.mp4", invalid: true}},
+			setup: func(config *Config) {
+				config.Video.LoopMode = false
+				config.Video.EndAction = EndActionWait
+			},
+			length: time.Hour,
+			want: []string{
+				"0s gap a.mp4 0s@0s [skipped]",
+				"0s gap b.mp4 0s@0s [skipped]",
+				"0s gap  3600s@0s [end]",
+			},
+		},
+		{
+			name:   "empty directory",
+			length: time.Hour,
+			want:   []string{"0s gap  3600s@0s [no_files]"},
+		},
+		{
+			name:   "empty directory with slate",
+			videos: []testVideo{{name: "slate.mp4", duration: 10 * time.Second}},
+			setup: func(config *Config) {
+				config.Slate.File = filepath.Join(config.Video.Directory, "slate.mp4")
+				config.Video.Directory = filepath.Join(config.Video.Directory, "videos")
+				os.Mkdir(config.Video.Directory, 0o755)
+			},
+			length: time.Hour,
+			want:   []string{"0s slate slate.mp4 3600s@0s [no_files]"},
+		},
+	}
+
+	for _, tt := range tests {
+		config, media := newTestSimulation(t, tt.videos)
+		if tt.setup != nil {
+			tt.setup(config)
+		}
+		simulation, err := simulateWithin(t, config, tt.length, tt.state, media)
+		if err != nil {
+			t.Errorf("%s: simulateChannel: %v", tt.name, err)
+			continue
+		}
+		if got := describeSimulation(simulation); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\n got  %q\n want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSimulateChannelMetadata(t *testing.T) {
+	config, media := newTestSimulation(t, []testVideo{
+		{name: "a.mp4", duration: time.Minute, sidecar: `{"title": "Новости", "tags": ["news", "live"], "in": "10", "out": "50"}`},
+		{name: "b.mp4", duration: time.Minute, sidecar: `{"notAfter": "2026-03-01"}`},
+	})
+	simulation, err := simulateWithin(t, config, 45*time.Second, nil, media)
+	if err != nil {
+		t.Fatalf("simulateChannel: %v", err)
+	}
+
+	want := []string{"0s file a.mp4 40s@10s []", "41s file a.mp4 40s@10s []"}
+	if got := describeSimulation(simulation); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	item := simulation.Items[0]
+	if item.Title != "Новости" || !reflect.DeepEqual(item.Tags, []string{"news", "live"}) {
+		t.Errorf("title %q, tags %v, want Новости, [news live]", item.Title, item.Tags)
+	}
+	if simulation.Summary.Content != 80 {
+		t.Errorf("summary content = %g, want 80", simulation.Summary.Content)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"time"
+)
+
+// playSlate воспроизводит заставку один раз (или до maxDuration) на текущем соединении,
+// чтобы канал оставался в эфире, пока нет доступного контента.
+// Таймстампы продолжаются с места окончания предыдущего сегмента.
+// Возвращает false, если заставка не настроена или не может быть воспроизведена.
+func playSlate(pub *Publisher, ch *Channel, sessionBitrate *BitrateCalculator, maxDuration time.Duration) bool {
+	config := ch.Config()
+	if config.Slate.File == "" {
+		return false
+	}
+
+	if err := pub.Connect(); err != nil {
+		ch.Log.Error("slate.connect_failed", "error", err)
+		return false
+	}
+
+	ch.Log.Info("slate.on_air", "file", config.Slate.File)
+	ch.Monitor.SetSlate(true)
+	defer ch.Monitor.SetSlate(false)
+	started := time.Now()
+	played, err := streamClip(config.Slate.File, pub, sessionBitrate, maxDuration)
+	ch.emitClip(ClipSlate, config.Slate.File, started, played, err)
+	if err != nil {
+		ch.Log.Error("slate.failed", "file", config.Slate.File, "error", err)
+		return false
+	}
+	return played > 0
+}
+
+// holdOnSlate повторяет заставку на текущем соединении, пропуская в эфир экстренные вставки,
+// пока не появятся новые файлы (hasFiles), не будет запрошена перезагрузка или не закроется окно вещания.
+// При ошибке соединение переустанавливается, заставка продолжается.
+func holdOnSlate(pub *Publisher, ch *Channel, sessionBitrate *BitrateCalculator, hasFiles func() bool) {
+	ch.Log.Info("slate.hold", "file", ch.Config().Slate.File)
+
+	for !ch.reloadPending() && !ch.OffAir() && !hasFiles() {
+		if ch.overridePending() {
+			playOverrides(pub, ch, sessionBitrate)
+			continue
+		}
+		if !playSlate(pub, ch, sessionBitrate, 0) {
+			time.Sleep(time.Duration(retryDelay) * time.Second)
+		}
+	}
+}
+
+// holdWhilePaused удерживает эфир на заставке, пока оператор не снимет паузу.
+// Без заставки соединение закрывается до снятия паузы. Экстренная вставка
+// прерывает удержание и выходит в эфир, после нее пауза продолжается.
+func holdWhilePaused(pub *Publisher, ch *Channel, sessionBitrate *BitrateCalculator) {
+	if ch.Config().Slate.File != "" {
+		ch.Log.Info("control.paused")
+	} else {
+		ch.Log.Warn("control.paused_offline")
+	}
+
+	for ch.Paused() && !ch.reloadPending() && !ch.overridePending() && !ch.OffAir() {
+		if !playSlate(pub, ch, sessionBitrate, 0) {
+			pub.Close()
+			time.Sleep(time.Second)
+		}
+	}
+	if !ch.Paused() {
+		ch.Log.Info("control.resumed")
+	}
+}
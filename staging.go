@@ -0,0 +1,425 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nareix/joy4/av"
+	"github.com/nareix/joy4/av/avutil"
+	"github.com/nareix/joy4/format/mp4/mp4io"
+)
+
+const (
+	stagingPollInterval        = 2 * time.Second // Интервал проверки входящего каталога
+	stagingRetryMax            = 5 * time.Minute // Максимальная пауза перед повтором переноса файла
+	stagingMaxMoveFailures     = 3               // Неудачных переносов в каталог видео до карантина
+	defaultIncomingDirectory   = "incoming"      // Каталог для загрузки новых файлов по умолчанию
+	defaultQuarantineDirectory = "quarantine"    // Каталог для отбракованных файлов по умолчанию
+)
+
+// Причины отбраковки файла (стабильные коды для отчета)
+const (
+	RejectOpenFailed    = "open_failed"     // Файл не открывается
+	RejectNoMoov        = "no_moov"         // Отсутствует атом moov (файл не догружен или поврежден)
+	RejectDemuxFailed   = "demux_failed"    // Ошибка чтения пакетов
+	RejectNoStreams     = "no_streams"      // Нет аудио и видео потоков
+	RejectCodecMismatch = "codec_mismatch"  // Кодеки не соответствуют профилю канала
+	RejectTooShort      = "too_short"       // Длительность меньше минимальной
+	RejectPlayback      = "playback_failed" // Файл не удалось воспроизвести
+	RejectMoveFailed    = "move_failed"     // Файл не удалось перенести в каталог видео
+)
+
+// ProbeResult содержит сведения о файле, полученные полным чтением его пакетов
+type ProbeResult struct {
+	VideoCodec string        `json:"videoCodec,omitempty"`
+	AudioCodec string        `json:"audioCodec,omitempty"`
+	Width      int           `json:"width,omitempty"`
+	Height     int           `json:"height,omitempty"`
+	SampleRate int           `json:"sampleRate,omitempty"`
+	Channels   int           `json:"channels,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	Packets    int           `json:"packets"`
+	Keyframes  int           `json:"keyframes"`
+
+	KeyframeTimes []time.Duration `json:"-"` // Позиции ключевых кадров от первого видеопакета, для каталога медиафайлов
+}
+
+// ValidationError описывает причину отбраковки файла
+type ValidationError struct {
+	Reason  string // Код причины
+	Details string // Подробности
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Reason, e.Details)
+}
+
+// Is относит структурные ошибки файла к классу ErrFileCorrupt
+func (e *ValidationError) Is(target error) bool {
+	if target != ErrFileCorrupt {
+		return false
+	}
+	switch e.Reason {
+	case RejectOpenFailed, RejectNoMoov, RejectDemuxFailed, RejectNoStreams:
+		return true
+	}
+	return false
+}
+
+// QuarantineReport - JSON отчет, который кладется рядом с отбракованным файлом
+type QuarantineReport struct {
+	File    string       `json:"file"`
+	Source  string       `json:"source"`
+	Reason  string       `json:"reason"`
+	Details string       `json:"details"`
+	Time    time.Time    `json:"time"`
+	Probe   *ProbeResult `json:"probe,omitempty"`
+}
+
+// probeVideoFile открывает файл и читает все пакеты, чтобы убедиться, что он целиком демультиплексируется
+func probeVideoFile(path string) (*ProbeResult, error) {
+	file, err := avutil.Open(path)
+	if err != nil {
+		return nil, &ValidationError{Reason: RejectOpenFailed, Details: err.Error()}
+	}
+	defer file.Close()
+
+	streams, err := file.Streams()
+	if err != nil {
+		if isMissingMoov(err) {
+			return nil, &ValidationError{Reason: RejectNoMoov, Details: err.Error()}
+		}
+		return nil, &ValidationError{Reason: RejectDemuxFailed, Details: err.Error()}
+	}
+
+	result := &ProbeResult{}
+	videoIdx, audioIdx := -1, -1
+	for i, stream := range streams {
+		if video, ok := stream.(av.VideoCodecData); ok && stream.Type().IsVideo() {
+			videoIdx = i
+			result.VideoCodec = stream.Type().String()
+			result.Width = video.Width()
+			result.Height = video.Height()
+		} else if audio, ok := stream.(av.AudioCodecData); ok && stream.Type().IsAudio() {
+			audioIdx = i
+			result.AudioCodec = stream.Type().String()
+			result.SampleRate = audio.SampleRate()
+			result.Channels = audio.ChannelLayout().Count()
+		}
+	}
+	if videoIdx == -1 && audioIdx == -1 {
+		return result, &ValidationError{Reason: RejectNoStreams, Details: T("err.no_streams")}
+	}
+
+	var firstTS, firstVideoTS time.Duration = -1, -1
+	for {
+		pkt, err := file.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return result, &ValidationError{Reason: RejectDemuxFailed, Details: err.Error()}
+		}
+
+		result.Packets++
+		if int(pkt.Idx) == videoIdx {
+			if firstVideoTS < 0 {
+				firstVideoTS = pkt.Time
+			}
+			if pkt.IsKeyFrame {
+				result.Keyframes++
+				result.KeyframeTimes = append(result.KeyframeTimes, pkt.Time-firstVideoTS)
+			}
+		}
+		if firstTS < 0 {
+			firstTS = pkt.Time
+		}
+		if pos := pkt.Time - firstTS; pos > result.Duration {
+			result.Duration = pos
+		}
+	}
+
+	return result, nil
+}
+
+// mp4Duration быстро читает длительность из заголовка moov, не читая пакеты.
+// Возвращает 0, если длительность определить не удалось.
+func mp4Duration(path string) time.Duration {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	atoms, err := mp4io.ReadFileAtoms(f)
+	if err != nil {
+		return 0
+	}
+	for _, atom := range atoms {
+		if movie, ok := atom.(*mp4io.Movie); ok && movie.Header != nil && movie.Header.TimeScale > 0 {
+			return time.Duration(movie.Header.Duration) * time.Second / time.Duration(movie.Header.TimeScale)
+		}
+	}
+	return 0
+}
+
+// StagingProfile - требования канала к файлам
+type StagingProfile struct {
+	VideoCodec  string
+	AudioCodec  string
+	Width       int
+	Height      int
+	MinDuration time.Duration
+}
+
+// Check проверяет результат пробы на соответствие профилю
+func (p StagingProfile) Check(probe *ProbeResult) error {
+	if p.VideoCodec != "" && !strings.EqualFold(probe.VideoCodec, p.VideoCodec) {
+		return &ValidationError{Reason: RejectCodecMismatch,
+			Details: T("staging.video_codec_mismatch", "actual", probe.VideoCodec, "expected", p.VideoCodec)}
+	}
+	if p.AudioCodec != "" && !strings.EqualFold(probe.AudioCodec, p.AudioCodec) {
+		return &ValidationError{Reason: RejectCodecMismatch,
+			Details: T("staging.audio_codec_mismatch", "actual", probe.AudioCodec, "expected", p.AudioCodec)}
+	}
+	if p.Width > 0 && p.Height > 0 && (probe.Width != p.Width || probe.Height != p.Height) {
+		return &ValidationError{Reason: RejectCodecMismatch,
+			Details: T("staging.resolution_mismatch",
+				"actual", fmt.Sprintf("%dx%d", probe.Width, probe.Height), "expected", fmt.Sprintf("%dx%d", p.Width, p.Height))}
+	}
+	if p.MinDuration > 0 && probe.Duration < p.MinDuration {
+		return &ValidationError{Reason: RejectTooShort,
+			Details: T("staging.too_short", "duration", probe.Duration, "min", p.MinDuration)}
+	}
+	return nil
+}
+
+// Stager принимает файлы из входящего каталога: дожидается окончания загрузки,
+// проверяет их и переносит в каталог видео или в карантин
+type Stager struct {
+	IncomingDir   string
+	QuarantineDir string
+	VideoDir      string
+	Profile       StagingProfile
+
+	incoming *DirCatalog
+	retries  map[string]*stagingRetry // Файлы, которые не удалось перенести, по имени
+	log      *Logger
+	stop     chan struct{}
+}
+
+// stagingRetry - неудачные попытки перенести файл и время следующей попытки
+type stagingRetry struct {
+	failures int
+	next     time.Time
+}
+
+// NewStager создает конвейер приема файлов из конфигурации
+func NewStager(config *Config, log *Logger) *Stager {
+	s := &Stager{
+		IncomingDir:   incomingDirectory(config),
+		QuarantineDir: quarantineDirectory(config),
+		VideoDir:      config.Video.Directory,
+		Profile: StagingProfile{
+			VideoCodec:  config.Staging.VideoCodec,
+			AudioCodec:  config.Staging.AudioCodec,
+			Width:       config.Staging.Width,
+			Height:      config.Staging.Height,
+			MinDuration: time.Duration(config.Staging.MinDuration) * time.Second,
+		},
+		retries: make(map[string]*stagingRetry),
+		log:     log,
+		stop:    make(chan struct{}),
+	}
+	return s
+}
+
+// incomingDirectory возвращает входящий каталог канала
+func incomingDirectory(config *Config) string {
+	if config.Staging.IncomingDirectory != "" {
+		return config.Staging.IncomingDirectory
+	}
+	return defaultIncomingDirectory
+}
+
+// quarantineDirectory возвращает каталог карантина канала
+func quarantineDirectory(config *Config) string {
+	if config.Staging.QuarantineDirectory != "" {
+		return config.Staging.QuarantineDirectory
+	}
+	return defaultQuarantineDirectory
+}
+
+// Run запускает прием файлов; блокирует до вызова Stop
+func (s *Stager) Run() {
+	if err := os.MkdirAll(s.IncomingDir, 0755); err != nil {
+		s.log.Error("staging.mkdir_failed", "dir", s.IncomingDir, "error", err)
+		return
+	}
+
+	s.log.Info("staging.started", "dir", s.IncomingDir, "quarantine", s.QuarantineDir)
+	s.incoming = NewDirCatalog(s.IncomingDir, s.log)
+	defer s.incoming.Close()
+
+	ticker := time.NewTicker(stagingPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, entry := range s.incoming.Files() {
+			s.process(entry.Name())
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop останавливает прием файлов
+func (s *Stager) Stop() {
+	close(s.stop)
+}
+
+// process проверяет загруженный файл и переносит его в каталог видео или в карантин
+func (s *Stager) process(name string) {
+	source := filepath.Join(s.IncomingDir, name)
+	if _, err := os.Stat(source); err != nil {
+		// Файл уже перенесен, событие еще не обработано
+		delete(s.retries, name)
+		return
+	}
+	if retry := s.retries[name]; retry != nil && time.Now().Before(retry.next) {
+		return
+	}
+	s.log.Info("staging.checking", "file", name)
+
+	probe, err := probeVideoFile(source)
+	if err == nil {
+		err = s.Profile.Check(probe)
+	}
+	if err != nil {
+		s.quarantine(name, source, err, probe)
+		return
+	}
+
+	// Файл с тем же именем уже в эфире: новый принимается под свободным именем, а не заменяет его
+	target, renamed := freeFileName(s.VideoDir, name)
+	if renamed {
+		s.log.Warn("staging.renamed", "file", name, "to", filepath.Base(target))
+	}
+	if err := os.Rename(source, target); err != nil {
+		failures := s.fail(name)
+		s.log.Error("staging.move_failed", "file", name, "attempt", failures, "error", err)
+		if failures >= stagingMaxMoveFailures {
+			s.quarantine(name, source, &ValidationError{Reason: RejectMoveFailed, Details: err.Error()}, probe)
+		}
+		return
+	}
+	delete(s.retries, name)
+	s.log.Info("staging.accepted", "file", filepath.Base(target), "duration", probe.Duration,
+		"video_codec", probe.VideoCodec, "audio_codec", probe.AudioCodec)
+}
+
+// quarantine помещает файл в карантин; если это не удалось, повтор откладывается
+func (s *Stager) quarantine(name, source string, reason error, probe *ProbeResult) {
+	if err := quarantineFile(source, s.QuarantineDir, reason, probe, s.log); err != nil {
+		s.log.Error("quarantine.failed", "file", name, "attempt", s.fail(name), "error", err)
+		return
+	}
+	delete(s.retries, name)
+}
+
+// fail отмечает неудачную попытку для файла и откладывает следующую с удвоением паузы.
+// Возвращает число неудачных попыток подряд.
+func (s *Stager) fail(name string) int {
+	retry := s.retries[name]
+	if retry == nil {
+		retry = &stagingRetry{}
+		s.retries[name] = retry
+	}
+	retry.failures++
+	delay := stagingPollInterval
+	for i := 0; i < retry.failures && delay < stagingRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > stagingRetryMax {
+		delay = stagingRetryMax
+	}
+	retry.next = time.Now().Add(delay)
+	return retry.failures
+}
+
+// freeFileName возвращает путь для файла name в каталоге dir, не занятый другим файлом:
+// при совпадении к имени добавляется номер. renamed сообщает, что имя изменено.
+func freeFileName(dir, name string) (path string, renamed bool) {
+	path = filepath.Join(dir, name)
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return path, false
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s_%d%s", base, i, ext))
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path, true
+		}
+	}
+}
+
+// quarantineFile перемещает файл в карантин и записывает рядом JSON отчет с причиной.
+// Если в карантине уже есть файл с тем же именем, файл помещается под свободным именем с номером.
+func quarantineFile(source, quarantineDir string, reason error, probe *ProbeResult, log *Logger) error {
+	if err := os.MkdirAll(quarantineDir, 0755); err != nil {
+		return err
+	}
+
+	name := filepath.Base(source)
+	report := QuarantineReport{
+		File:    name,
+		Source:  source,
+		Reason:  RejectPlayback,
+		Details: reason.Error(),
+		Time:    time.Now(),
+		Probe:   probe,
+	}
+	var verr *ValidationError
+	switch {
+	case errors.As(reason, &verr):
+		report.Reason = verr.Reason
+		report.Details = verr.Details
+	case isMissingMoov(reason):
+		report.Reason = RejectNoMoov
+	case errors.Is(reason, ErrNoStreams):
+		report.Reason = RejectNoStreams
+	case errors.Is(reason, ErrFileCorrupt):
+		report.Reason = RejectDemuxFailed
+	}
+
+	// Отбракованный ранее файл с тем же именем и его отчет не перезаписываются
+	target, renamed := freeFileName(quarantineDir, name)
+	if renamed {
+		log.Warn("quarantine.renamed", "file", name, "to", filepath.Base(target))
+	}
+	if err := os.Rename(source, target); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(target+".json", data, 0644); err != nil {
+		return err
+	}
+
+	log.Warn("quarantine.moved", "file", name, "reason", report.Reason, "details", report.Details)
+	return nil
+}
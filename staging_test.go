@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQuarantineFileKeepsEarlierCopies(t *testing.T) {
+	dir := t.TempDir()
+	quarantineDir := filepath.Join(dir, "quarantine")
+	log := NewLogger("staging", "test")
+
+	for _, content := range []string{"first", "second", "third"} {
+		source := filepath.Join(dir, "news.mp4")
+		if err := os.WriteFile(source, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := quarantineFile(source, quarantineDir, ErrNoStreams, nil, log); err != nil {
+			t.Fatalf("quarantineFile(%s): %v", content, err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "news.mp4", content: "first"},
+		{name: "news_2.mp4", content: "second"},
+		{name: "news_3.mp4", content: "third"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(quarantineDir, tt.name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(data) != tt.content {
+			t.Errorf("%s: content %q, want %q", tt.name, data, tt.content)
+		}
+		data, err = os.ReadFile(path + ".json")
+		if err != nil {
+			t.Errorf("%s: report: %v", tt.name, err)
+			continue
+		}
+		var report QuarantineReport
+		if err := json.Unmarshal(data, &report); err != nil || report.Reason != RejectNoStreams {
+			t.Errorf("%s: report reason %q (%v), want %s", tt.name, report.Reason, err, RejectNoStreams)
+		}
+	}
+}
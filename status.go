@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultStatusFilePath = "stream_status.json" // Путь к файлу статуса по умолчанию
+
+// StatusSnapshot - текущий статус трансляции для внешних инструментов
+type StatusSnapshot struct {
+	Channel     string          `json:"channel"`
+	CurrentFile string          `json:"currentFile"`
+	Position    time.Duration   `json:"position"`
+	FileIndex   int             `json:"fileIndex"`
+	Bitrate     int64           `json:"bitrate"`
+	Breaker     BreakerSnapshot `json:"breaker"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// writeStatusFile записывает статус трансляции в JSON файл
+func writeStatusFile(path string, status StatusSnapshot) error {
+	status.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("%s: %w", T("err.status_marshal"), err)
+	}
+
+	// Запись через временный файл, чтобы читатели не увидели неполный JSON
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("%s: %w", T("err.status_write"), err)
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,379 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	tuiRefreshInterval = 500 * time.Millisecond // Интервал перерисовки экрана
+	defaultTUILogFile  = "streamer.log"         // Файл логов в режиме --tui, если logging.file не задан
+)
+
+// Управляющие последовательности терминала
+const (
+	ansiAltScreenOn  = "\x1b[?1049h"
+	ansiAltScreenOff = "\x1b[?1049l"
+	ansiCursorHide   = "\x1b[?25l"
+	ansiCursorShow   = "\x1b[?25h"
+	ansiHome         = "\x1b[H"
+	ansiClearLine    = "\x1b[K"
+	ansiClearBelow   = "\x1b[J"
+	ansiBold         = "\x1b[1m"
+	ansiDim          = "\x1b[2m"
+	ansiRed          = "\x1b[31m"
+	ansiGreen        = "\x1b[32m"
+	ansiYellow       = "\x1b[33m"
+	ansiReset        = "\x1b[0m"
+)
+
+// errNoTerminal возвращается, если полноэкранный режим запущен без терминала
+var errNoTerminal = newCodedError("err.tui_no_terminal")
+
+// sparkBlocks - символы для графика битрейта, от меньшего к большему
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// TerminalUI - полноэкранный режим для работы по SSH: состояние выбранного канала
+// обновляется на месте, а управление выполняется клавишами
+type TerminalUI struct {
+	channels []*Channel
+
+	mu       sync.Mutex
+	selected int // Индекс канала на экране
+}
+
+// NewTerminalUI создает полноэкранный интерфейс для каналов процесса
+func NewTerminalUI(channels []*Channel) *TerminalUI {
+	return &TerminalUI{channels: channels}
+}
+
+// Run захватывает терминал и перерисовывает экран, пока пользователь не выйдет
+// или не остановятся все каналы (закрытие stopped). Терминал восстанавливается при выходе.
+func (u *TerminalUI) Run(stopped <-chan struct{}) error {
+	if err := checkTerminal(); err != nil {
+		return err
+	}
+	fd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+
+	fmt.Print(ansiAltScreenOn + ansiCursorHide)
+	defer fmt.Print(ansiCursorShow + ansiAltScreenOff)
+
+	quit := make(chan struct{})
+	go u.readKeys(quit)
+
+	ticker := time.NewTicker(tuiRefreshInterval)
+	defer ticker.Stop()
+	for {
+		u.render()
+		select {
+		case <-quit:
+			return nil
+		case <-stopped:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkTerminal проверяет, что ввод и вывод подключены к терминалу
+func checkTerminal() error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errNoTerminal
+	}
+	return nil
+}
+
+// readKeys читает клавиши и выполняет команды; закрывает quit по команде выхода
+func (u *TerminalUI) readKeys(quit chan struct{}) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(quit)
+			return
+		}
+		for _, key := range buf[:n] {
+			if !u.handleKey(key) {
+				close(quit)
+				return
+			}
+		}
+		u.render()
+	}
+}
+
+// handleKey выполняет команду клавиши. Возвращает false по команде выхода.
+func (u *TerminalUI) handleKey(key byte) bool {
+	u.mu.Lock()
+	ch := u.channels[u.selected]
+	u.mu.Unlock()
+
+	switch key {
+	case 'q', 'Q', 3: // 3 - Ctrl+C, в raw режиме он не превращается в сигнал
+		return false
+	case 's', 'S':
+		ch.Log.Info("control.command", "command", "skip", "remote", "tui")
+		ch.Skip()
+	case 'r', 'R':
+		ch.Log.Info("control.command", "command", "reconnect", "remote", "tui")
+		ch.Reconnect()
+	case 'p', 'P':
+		command := "pause"
+		if ch.Paused() {
+			command = "resume"
+		}
+		ch.Log.Info("control.command", "command", command, "remote", "tui")
+		if command == "pause" {
+			ch.Pause()
+		} else {
+			ch.Resume()
+		}
+	case '\t', 'n', 'N':
+		u.mu.Lock()
+		u.selected = (u.selected + 1) % len(u.channels)
+		u.mu.Unlock()
+	default:
+		if key >= '1' && key <= '9' && int(key-'1') < len(u.channels) {
+			u.mu.Lock()
+			u.selected = int(key - '1')
+			u.mu.Unlock()
+		}
+	}
+	return true
+}
+
+// render перерисовывает экран для выбранного канала
+func (u *TerminalUI) render() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	ch := u.channels[u.selected]
+	status := ch.Status()
+	var lines []string
+
+	// Заголовок и состояние эфира
+	title := ansiBold + "MP4 RTMP Streamer" + ansiReset + "  " + T("tui.channel", "name", status.Channel)
+	if len(u.channels) > 1 {
+		title += fmt.Sprintf(" (%d/%d)", u.selected+1, len(u.channels))
+	}
+	lines = append(lines, title+"  "+tuiStateBadge(status), "")
+
+	// Текущий файл и позиция
+	if status.NowPlaying != "" {
+		lines = append(lines, T("tui.now_playing", "file", ansiBold+status.NowPlaying+ansiReset,
+			"index", status.Index+1, "total", status.Total))
+	} else {
+		lines = append(lines, ansiDim+T("tui.nothing_playing")+ansiReset)
+	}
+	position := tuiTime(status.Position)
+	if status.Duration > 0 {
+		position += " / " + tuiTime(status.Duration)
+	}
+	barWidth := width - utf8.RuneCountInString(position) - 3
+	lines = append(lines, position+"  "+tuiProgressBar(status.Position, status.Duration, barWidth))
+	lines = append(lines, T("tui.streams", "video", tuiTime(status.VideoProgress), "audio", tuiTime(status.AudioProgress)), "")
+
+	// Битрейт
+	label := T("tui.bitrate", "bitrate_kbps", status.Bitrate/1000) + "  "
+	lines = append(lines, label+tuiSparkline(status.BitrateHistory, width-utf8.RuneCountInString(label)))
+
+	// Назначение
+	breaker := status.Destination.Breaker
+	destination := T("tui.destination", "url", status.Destination.URL, "breaker", breaker.State)
+	if breaker.Failures > 0 {
+		destination += "  " + T("tui.failures", "failures", breaker.Failures)
+	}
+	lines = append(lines, destination, "")
+
+	// Очередь и ошибки делят оставшуюся высоту
+	const footerLines = 2
+	free := height - len(lines) - footerLines - 2
+	queueRows := free / 2
+	if queueRows > len(status.Queue) {
+		queueRows = len(status.Queue)
+	}
+	if queueRows < 1 {
+		queueRows = 1
+	}
+	eventRows := free - queueRows
+	if eventRows < 1 {
+		eventRows = 1
+	}
+
+	lines = append(lines, ansiBold+T("tui.up_next")+ansiReset)
+	if len(status.Queue) == 0 {
+		lines = append(lines, "  "+ansiDim+"—"+ansiReset)
+	}
+	for i, name := range status.Queue {
+		if i >= queueRows {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %d. %s", i+1, name))
+	}
+
+	lines = append(lines, ansiBold+T("tui.recent_errors")+ansiReset)
+	if len(status.Events) == 0 {
+		lines = append(lines, "  "+ansiDim+"—"+ansiReset)
+	}
+	for i := len(status.Events) - 1; i >= 0 && len(status.Events)-i <= eventRows; i-- {
+		event := status.Events[i]
+		color := ansiYellow
+		if event.Level == "ERROR" {
+			color = ansiRed
+		} else if event.Event == "conn.restored" {
+			color = ansiGreen
+		}
+		text := event.Message
+		if event.Error != "" {
+			text += ": " + event.Error
+		}
+		lines = append(lines, "  "+ansiDim+event.Time.Format("15:04:05")+ansiReset+" "+color+text+ansiReset)
+	}
+
+	// Подсказка по клавишам прижата к нижнему краю
+	if len(lines) > height-footerLines && height > footerLines {
+		lines = lines[:height-footerLines]
+	}
+	for len(lines) < height-footerLines {
+		lines = append(lines, "")
+	}
+	lines = append(lines, "", ansiDim+T("tui.keys")+ansiReset)
+
+	var b strings.Builder
+	b.WriteString(ansiHome)
+	for i, line := range lines {
+		if i >= height {
+			break
+		}
+		b.WriteString(tuiTruncate(line, width))
+		b.WriteString(ansiReset + ansiClearLine)
+		if i < height-1 && i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	b.WriteString(ansiClearBelow)
+	os.Stdout.WriteString(b.String())
+}
+
+// tuiStateBadge возвращает цветную метку состояния эфира
+func tuiStateBadge(status MonitorSnapshot) string {
+	label, color := channelStateLabel(status)
+	return color + "● " + label + ansiReset
+}
+
+// channelStateLabel возвращает состояние эфира канала и цвет для терминала
+func channelStateLabel(status MonitorSnapshot) (string, string) {
+	switch {
+	case status.OffAir:
+		return T("tui.off_air"), ansiYellow
+	case !status.Destination.Connected:
+		return T("tui.disconnected"), ansiRed
+	case status.Override != "":
+		return T("tui.override"), ansiRed
+	case status.Paused:
+		return T("tui.paused"), ansiYellow
+	case status.OnSlate:
+		return T("tui.slate"), ansiYellow
+	default:
+		return T("tui.on_air"), ansiGreen
+	}
+}
+
+// tuiTime форматирует секунды как [ч:]мм:сс
+func tuiTime(seconds float64) string {
+	total := int(seconds)
+	if total < 0 {
+		total = 0
+	}
+	h, m, s := total/3600, total%3600/60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
+
+// tuiProgressBar рисует полосу прогресса заданной ширины
+func tuiProgressBar(position, duration float64, width int) string {
+	if width < 3 {
+		return ""
+	}
+	inner := width - 2
+	filled := 0
+	if duration > 0 {
+		filled = int(position / duration * float64(inner))
+	}
+	if filled > inner {
+		filled = inner
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", inner-filled) + "]"
+}
+
+// tuiSparkline рисует последние замеры битрейта блочными символами, не шире width
+func tuiSparkline(samples []BitrateSample, width int) string {
+	if width <= 0 || len(samples) == 0 {
+		return ""
+	}
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+
+	var max int64 = 1
+	for _, s := range samples {
+		if s.Bitrate > max {
+			max = s.Bitrate
+		}
+	}
+
+	spark := make([]rune, len(samples))
+	for i, s := range samples {
+		level := int(s.Bitrate * int64(len(sparkBlocks)-1) / max)
+		spark[i] = sparkBlocks[level]
+	}
+	return string(spark)
+}
+
+// tuiTruncate обрезает строку до ширины экрана, не считая управляющие последовательности
+func tuiTruncate(line string, width int) string {
+	var b strings.Builder
+	visible := 0
+	for i := 0; i < len(line); {
+		if line[i] == '\x1b' {
+			// Последовательность ESC [ ... буква копируется целиком
+			j := i + 1
+			for j < len(line) && !(line[j] >= 'A' && line[j] <= 'Z' || line[j] >= 'a' && line[j] <= 'z') {
+				j++
+			}
+			if j < len(line) {
+				j++
+			}
+			b.WriteString(line[i:j])
+			i = j
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if visible >= width {
+			i += size
+			continue
+		}
+		b.WriteRune(r)
+		visible++
+		i += size
+	}
+	return b.String()
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	uploadSettleTime   = 3 * time.Second // Сколько файл должен не меняться, чтобы считаться загруженным
+	uploadPollInterval = 1 * time.Second // Интервал проверки загружаемых файлов
+)
+
+// pendingFile - файл, который еще загружается в каталог
+type pendingFile struct {
+	size      int64     // Размер при последней проверке
+	lastEvent time.Time // Время последнего события записи
+}
+
+// DirCatalog ведет актуальный список MP4 файлов каталога по событиям файловой системы.
+// Новые файлы попадают в список только после окончания загрузки: размер не меняется
+// и событий записи не было в течение uploadSettleTime. fsnotify не дает переносимого
+// события IN_CLOSE_WRITE, поэтому окончание записи определяется по паузе в событиях.
+// Вместе с файлами загружаются их метаданные (см. FileMeta); изменение метаданных
+// тоже меняет версию списка.
+type DirCatalog struct {
+	Dir string
+
+	mu      sync.Mutex
+	ready   map[string]fs.FileInfo
+	meta    map[string]*FileMeta
+	stamps  map[string]string // Размер и время изменения загруженных файлов метаданных
+	pending map[string]*pendingFile
+	version int
+
+	watcher *fsnotify.Watcher
+	log     *Logger
+}
+
+// NewDirCatalog сканирует каталог и запускает наблюдение за ним.
+// Если наблюдение недоступно, каталог пересканируется при каждом запросе списка.
+func NewDirCatalog(dir string, log *Logger) *DirCatalog {
+	c := &DirCatalog{
+		Dir:     dir,
+		log:     log,
+		ready:   make(map[string]fs.FileInfo),
+		meta:    make(map[string]*FileMeta),
+		stamps:  make(map[string]string),
+		pending: make(map[string]*pendingFile),
+	}
+
+	for _, entry := range scanVideoDirectory(dir, log) {
+		if info, err := entry.Info(); err == nil {
+			c.ready[entry.Name()] = info
+			c.loadMeta(entry.Name())
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		c.log.Warn("watch.unavailable", "dir", dir, "error", err)
+		return c
+	}
+	if err := watcher.Add(dir); err != nil {
+		c.log.Warn("watch.unavailable", "dir", dir, "error", err)
+		watcher.Close()
+		return c
+	}
+
+	c.watcher = watcher
+	go c.watch()
+	c.log.Info("watch.started", "dir", dir)
+	return c
+}
+
+// Close останавливает наблюдение за каталогом
+func (c *DirCatalog) Close() {
+	if c.watcher != nil {
+		c.watcher.Close()
+	}
+}
+
+// isVideoFile проверяет, что имя относится к MP4 файлу (временные файлы rsync начинаются с точки)
+func isVideoFile(name string) bool {
+	return !strings.HasPrefix(name, ".") && strings.HasSuffix(strings.ToLower(name), ".mp4")
+}
+
+// watch обрабатывает события файловой системы и проверяет загружаемые файлы
+func (c *DirCatalog) watch() {
+	ticker := time.NewTicker(uploadPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			c.handleEvent(event)
+
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			c.log.Warn("watch.error", "dir", c.Dir, "error", err)
+
+		case <-ticker.C:
+			c.promoteSettled()
+		}
+	}
+}
+
+// handleEvent применяет событие файловой системы к списку
+func (c *DirCatalog) handleEvent(event fsnotify.Event) {
+	name := filepath.Base(event.Name)
+	if video, ok := sidecarVideo(name); ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if _, ready := c.ready[video]; ready {
+			c.loadMeta(video)
+			c.version++
+		}
+		return
+	}
+	if !isVideoFile(name) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename):
+		// При переименовании старое имя удаляется, новое приходит отдельным событием Create
+		if _, ok := c.ready[name]; ok {
+			delete(c.ready, name)
+			delete(c.meta, name)
+			delete(c.stamps, name)
+			c.version++
+			c.log.Info("catalog.removed", "file", name)
+		}
+		delete(c.pending, name)
+
+	case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
+		info, err := os.Stat(event.Name)
+		if err != nil || info.IsDir() {
+			return
+		}
+		if _, ok := c.ready[name]; ok && event.Has(fsnotify.Write) {
+			// Готовый файл перезаписывается - снова ждем окончания загрузки
+			delete(c.ready, name)
+			c.version++
+		}
+		p, ok := c.pending[name]
+		if !ok {
+			p = &pendingFile{size: -1}
+			c.pending[name] = p
+			c.log.Info("catalog.uploading", "file", name)
+		}
+		p.lastEvent = time.Now()
+	}
+}
+
+// promoteSettled переносит в список файлы, загрузка которых закончилась
+func (c *DirCatalog) promoteSettled() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for name, p := range c.pending {
+		info, err := os.Stat(filepath.Join(c.Dir, name))
+		if err != nil {
+			delete(c.pending, name)
+			continue
+		}
+
+		size := info.Size()
+		if size != p.size {
+			p.size = size
+			p.lastEvent = time.Now()
+			continue
+		}
+		if size == 0 || time.Since(p.lastEvent) < uploadSettleTime {
+			continue
+		}
+
+		delete(c.pending, name)
+		c.ready[name] = info
+		c.loadMeta(name)
+		c.version++
+		c.log.Info("catalog.added", "file", name, "size_mb", float64(size)/(1024*1024))
+	}
+}
+
+// Version возвращает номер версии списка, который увеличивается при каждом изменении
+func (c *DirCatalog) Version() int {
+	if c.watcher == nil {
+		c.rescan()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// rescan пересканирует каталог, если наблюдение недоступно
+func (c *DirCatalog) rescan() {
+	entries, err := listVideoFiles(c.Dir)
+	if err != nil {
+		c.log.Error("catalog.read_failed", "dir", c.Dir, "error", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := make(map[string]fs.FileInfo)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			current[entry.Name()] = info
+		}
+	}
+
+	changed := len(current) != len(c.ready)
+	for name := range current {
+		if _, ok := c.ready[name]; !ok {
+			changed = true
+		}
+		// Без наблюдения изменения метаданных видны только при пересканировании
+		if sidecarStamp(filepath.Join(c.Dir, name)) != c.stamps[name] {
+			c.loadMeta(name)
+			changed = true
+		}
+	}
+	for name := range c.ready {
+		if _, ok := current[name]; !ok {
+			delete(c.meta, name)
+			delete(c.stamps, name)
+		}
+	}
+	c.ready = current
+	if changed {
+		c.version++
+	}
+}
+
+// loadMeta загружает метаданные файла; вызывается под c.mu
+func (c *DirCatalog) loadMeta(name string) {
+	path := filepath.Join(c.Dir, name)
+	c.stamps[name] = sidecarStamp(path)
+	meta, err := loadFileMeta(path)
+	if err != nil {
+		c.log.Warn("meta.invalid", "file", name, "error", err)
+	}
+	if meta == nil {
+		delete(c.meta, name)
+		return
+	}
+	c.meta[name] = meta
+	c.log.Debug("meta.loaded", "file", name, "title", meta.Title)
+}
+
+// Meta возвращает метаданные файла, nil если их нет
+func (c *DirCatalog) Meta(name string) *FileMeta {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.meta[name]
+}
+
+// Files возвращает отсортированный список готовых к воспроизведению файлов
+func (c *DirCatalog) Files() []os.DirEntry {
+	if c.watcher == nil {
+		c.rescan()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := make([]os.DirEntry, 0, len(c.ready))
+	for _, info := range c.ready {
+		files = append(files, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
+	return files
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Значения по умолчанию для вебхуков
+const (
+	defaultWebhookLogPath     = "webhooks.log"   // Журнал доставки вебхуков
+	defaultWebhookMaxAttempts = 5                // Попыток доставки одного события
+	defaultWebhookTimeout     = 10 * time.Second // Таймаут одного запроса
+	webhookRetryInitial       = 2 * time.Second  // Первая задержка повтора
+	webhookRetryMax           = 5 * time.Minute  // Максимальная задержка повтора
+)
+
+// errWebhookStatus - получатель ответил кодом, отличным от 2xx
+var errWebhookStatus = newCodedError("err.webhook_status")
+
+// WebhookTarget - получатель вебхуков из конфигурации
+type WebhookTarget struct {
+	Name        string   `json:"name"`        // Имя для логов, по умолчанию хост получателя
+	URL         string   `json:"url"`         // Адрес, на который отправляется POST с событием
+	Secret      string   `json:"secret"`      // Ключ подписи HMAC-SHA256, пусто = без подписи
+	Events      []string `json:"events"`      // Типы событий, пусто = все
+	Channels    []string `json:"channels"`    // Имена каналов, пусто = все
+	MaxAttempts int      `json:"maxAttempts"` // Попыток доставки одного события, 0 = 5
+	Timeout     float64  `json:"timeout"`     // Таймаут запроса в секундах, 0 = 10
+}
+
+// WebhookPayload - тело запроса вебхука. Поля file, position и error заполняются
+// для всех событий, где они есть, чтобы получателю не разбирать data по типам.
+type WebhookPayload struct {
+	ID       uint64    `json:"id"` // ID события, одинаковый во всех попытках доставки
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Channel  string    `json:"channel"`
+	File     string    `json:"file,omitempty"`
+	Position float64   `json:"position"` // Позиция в файле в секундах
+	Error    string    `json:"error,omitempty"`
+	Data     any       `json:"data,omitempty"` // Полные данные события, как в потоке событий
+}
+
+// WebhookDelivery - запись журнала доставки
+type WebhookDelivery struct {
+	Time     time.Time `json:"time"`
+	Target   string    `json:"target"`
+	EventID  uint64    `json:"eventId"`
+	Event    string    `json:"event"`
+	Channel  string    `json:"channel"`
+	Attempt  int       `json:"attempt"`
+	Status   int       `json:"status,omitempty"` // HTTP код ответа, 0 если ответа не было
+	Duration float64   `json:"duration"`         // Время запроса в секундах
+	Error    string    `json:"error,omitempty"`
+	Result   string    `json:"result"` // delivered, retry или failed
+}
+
+// WebhookDispatcher доставляет события шины получателям вебхуков. Каждый получатель
+// обслуживается своей горутиной с собственной подпиской, поэтому медленный или
+// недоступный получатель не задерживает ни каналы, ни других получателей.
+type WebhookDispatcher struct {
+	targets []WebhookTarget
+
+	logMu   sync.Mutex
+	logFile *os.File // Журнал доставки в формате JSON Lines, nil = только логи
+}
+
+// NewWebhookDispatcher создает рассылку для получателей из конфигурации.
+// Записи без адреса пропускаются.
+func NewWebhookDispatcher(targets []WebhookTarget, deliveryLog string) *WebhookDispatcher {
+	d := &WebhookDispatcher{}
+	for _, target := range targets {
+		if target.URL == "" {
+			logWarn("webhook.no_url", "name", target.Name)
+			continue
+		}
+		if target.Name == "" {
+			target.Name = target.URL
+			if u, err := url.Parse(target.URL); err == nil && u.Host != "" {
+				// В пути адреса часто содержится токен, поэтому в логи попадает только хост
+				target.Name = u.Host
+			}
+		}
+		if target.MaxAttempts <= 0 {
+			target.MaxAttempts = defaultWebhookMaxAttempts
+		}
+		d.targets = append(d.targets, target)
+	}
+
+	if len(d.targets) > 0 && deliveryLog != "" {
+		file, err := os.OpenFile(deliveryLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logWarn("webhook.log_failed", "path", deliveryLog, "error", err)
+		} else {
+			d.logFile = file
+		}
+	}
+	return d
+}
+
+// Start подписывает получателей на шину событий
+func (d *WebhookDispatcher) Start(bus *EventBus) {
+	for _, target := range d.targets {
+		events := "*"
+		if len(target.Events) > 0 {
+			events = strings.Join(target.Events, ",")
+		}
+		logInfo("webhook.started", "target", target.Name, "events", events)
+		go d.run(bus, target)
+	}
+}
+
+// run доставляет события одному получателю по порядку. Пока идут повторы, новые события
+// копятся в подписке; при ее переполнении шина отключает подписку, и получатель
+// догоняет пропущенное из буфера повторной отправки.
+func (d *WebhookDispatcher) run(bus *EventBus, target WebhookTarget) {
+	client := &http.Client{Timeout: defaultWebhookTimeout}
+	if target.Timeout > 0 {
+		client.Timeout = time.Duration(target.Timeout * float64(time.Second))
+	}
+
+	var lastID uint64
+	for {
+		sub := bus.Subscribe(lastID)
+		for event := range sub.C {
+			if lastID > 0 && event.ID > lastID+1 {
+				logError("webhook.events_lost", "target", target.Name, "count", event.ID-lastID-1)
+			}
+			lastID = event.ID
+			if target.match(event) {
+				d.deliver(client, target, event)
+			}
+		}
+		logWarn("webhook.lagging", "target", target.Name)
+	}
+}
+
+// match проверяет, подписан ли получатель на событие
+func (t WebhookTarget) match(event Event) bool {
+	if len(t.Channels) > 0 && !containsString(t.Channels, event.Channel) {
+		return false
+	}
+	return len(t.Events) == 0 || containsString(t.Events, event.Type)
+}
+
+// deliver отправляет событие с повторами и экспоненциальной задержкой
+func (d *WebhookDispatcher) deliver(client *http.Client, target WebhookTarget, event Event) {
+	body, err := json.Marshal(webhookPayload(event))
+	if err != nil {
+		logError("webhook.failed", "target", target.Name, "event", event.Type, "channel", event.Channel, "attempt", 0, "error", err)
+		return
+	}
+
+	backoff := Backoff{Initial: webhookRetryInitial, Max: webhookRetryMax, Multiplier: 2, Jitter: 0.2}
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		status, err := target.post(client, event, body, attempt)
+		record := WebhookDelivery{Time: started, Target: target.Name, EventID: event.ID, Event: event.Type,
+			Channel: event.Channel, Attempt: attempt, Status: status, Duration: time.Since(started).Seconds()}
+
+		switch {
+		case err == nil:
+			record.Result = "delivered"
+			d.record(record)
+			logDebug("webhook.delivered", "target", target.Name, "event", event.Type, "channel", event.Channel, "attempt", attempt)
+			return
+
+		case attempt >= target.MaxAttempts || !webhookRetryable(status):
+			record.Error, record.Result = err.Error(), "failed"
+			d.record(record)
+			logError("webhook.failed", "target", target.Name, "event", event.Type, "channel", event.Channel,
+				"attempt", attempt, "error", err)
+			return
+
+		default:
+			record.Error, record.Result = err.Error(), "retry"
+			d.record(record)
+			delay := backoff.Next()
+			logWarn("webhook.retry", "target", target.Name, "event", event.Type, "channel", event.Channel,
+				"attempt", attempt, "max_attempts", target.MaxAttempts, "error", err, "retry_in", delay)
+			time.Sleep(delay)
+		}
+	}
+}
+
+// post выполняет один запрос. Возвращает HTTP код ответа (0, если ответа не было).
+func (t WebhookTarget) post(client *http.Client, event Event, body []byte, attempt int) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mp4-rtmp-streamer")
+	req.Header.Set("X-Streamer-Event", event.Type)
+	req.Header.Set("X-Streamer-Delivery", strconv.FormatUint(event.ID, 10))
+	req.Header.Set("X-Streamer-Attempt", strconv.Itoa(attempt))
+	if t.Secret != "" {
+		req.Header.Set("X-Streamer-Signature", "sha256="+webhookSignature(t.Secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Ошибка клиента содержит полный адрес, а в нем может быть токен
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("%w: %s", errWebhookStatus, resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookSignature вычисляет HMAC-SHA256 тела запроса в шестнадцатеричном виде
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryable определяет, имеет ли смысл повторять запрос: сетевые ошибки,
+// таймауты, перегрузка и ошибки сервера повторяются, отказ получателя (4xx) - нет
+func webhookRetryable(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// webhookPayload переносит файл, позицию и ошибку из данных события в общие поля
+func webhookPayload(event Event) WebhookPayload {
+	payload := WebhookPayload{ID: event.ID, Type: event.Type, Time: event.Time, Channel: event.Channel, Data: event.Data}
+	switch data := event.Data.(type) {
+	case FileStartedEvent:
+		payload.File, payload.Position = data.File, data.Position
+	case FileFinishedEvent:
+		payload.File, payload.Position, payload.Error = data.File, data.Position, data.Error
+	case EarlyEndEvent:
+		payload.File, payload.Position = data.File, data.Position
+	case RetryEvent:
+		payload.File = data.File
+	case ReconnectEvent:
+		payload.File, payload.Position, payload.Error = data.File, data.Position, data.Error
+	case StateSavedEvent:
+		payload.File, payload.Position = data.File, data.Position
+	case BitrateWarningEvent:
+		payload.File = data.File
+	case OverrideEvent:
+		payload.File, payload.Position, payload.Error = data.File, data.Position, data.Error
+	case ClipPlayedEvent:
+		payload.File, payload.Error = data.File, data.Error
+	}
+	return payload
+}
+
+// record дописывает попытку доставки в журнал
+func (d *WebhookDispatcher) record(delivery WebhookDelivery) {
+	if d.logFile == nil {
+		return
+	}
+	line, err := json.Marshal(delivery)
+	if err != nil {
+		return
+	}
+	d.logMu.Lock()
+	defer d.logMu.Unlock()
+	d.logFile.Write(append(line, '\n'))
+}
+
+// containsString проверяет наличие строки в списке
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}